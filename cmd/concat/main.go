@@ -3,54 +3,251 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/nessaee/concat/internal/app"
+	"github.com/nessaee/concat/internal/archive"
 	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/infra"
 	"github.com/spf13/cobra"
 )
 
 var (
-	cfg config.Config
+	cfg      config.Config
+	serveCfg config.ServeConfig
 )
 
 func main() {
 	rootCmd := &cobra.Command{
-		Use:   "concat",
+		Use:   "concat [archive | path...]",
 		Short: "Concatenates project files for LLM context",
 		Long: `Project Concatenator v0.1.4
 Concatenates project files and copies the result to the clipboard or a file.
-Designed for easily grabbing project context for LLMs.`,
+Designed for easily grabbing project context for LLMs.
+
+Reads the current directory, or the given .tar, .tar.gz, .tgz or .zip archive.
+
+Paths restrict the output to those files and directories. A file may be
+narrowed to line ranges or to the declaration of a symbol (found with go/ast
+for Go and heuristics for other languages), emitted with a partial marker:
+
+  concat internal/auth/ server.go:120-260 server.go:10-20,40-
+  concat server.go#HandleLogin server.go#Server.Close app.py#App.run
+
+With paths, file type flags are optional and narrow the selection further.`,
+		Args: cobra.ArbitraryArgs,
 		Run: func(cmd *cobra.Command, args []string) {
-			if len(cfg.Extensions) == 0 {
-				// Fail if no extensions provided, matching original script behavior
-				fmt.Println("Error: You must specify at least one file type to include with -p.")
-				cmd.Usage()
+			if len(args) > 1 || len(args) == 1 && !archive.IsArchive(args[0]) {
+				cleanExtensions()
+				checkPaths(args)
+
+				if err := app.RunPaths(&cfg, args); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				return
+			}
+			validate(cmd)
+			setSource(args)
+
+			run := app.Run
+			if cfg.Watch {
+				run = app.Watch
+			}
+			if err := run(&cfg); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
+		},
+	}
 
-			// Clean extensions immediately upon receiving flags
-			for i, ext := range cfg.Extensions {
-				// Trim dot if user included it (e.g. .go -> go)
-				if len(ext) > 0 && ext[0] == '.' {
-					cfg.Extensions[i] = ext[1:]
-				}
+	treeCmd := &cobra.Command{
+		Use:   "tree [archive]",
+		Short: "Print only the directory tree, without file contents",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			validate(cmd)
+			setSource(args)
+
+			if err := app.RunTree(&cfg); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	rootCmd.AddCommand(treeCmd)
+	for _, c := range []*cobra.Command{rootCmd, treeCmd} {
+		c.Flags().StringVar(&cfg.Rev, "rev", "", "Read files as of this git revision (commit, tag or branch) instead of the working tree.")
+	}
+	rootCmd.Flags().BoolVar(&cfg.Stats, "stats", false, "Report the largest files, totals by extension and directory, and skipped files after the run.")
+	rootCmd.Flags().StringVar(&cfg.StatsFormat, "stats-format", app.StatsFormatText, "Format of the --stats report: 'text' or 'json'.")
+	rootCmd.Flags().StringVar(&cfg.StatsFile, "stats-file", "", "Write the --stats report to this file instead of stderr.")
+	rootCmd.Flags().IntVar(&cfg.StatsTop, "stats-top", 10, "Number of largest files listed by --stats.")
+
+	var pickSaved bool
+	pickCmd := &cobra.Command{
+		Use:   "pick",
+		Short: "Choose the files to concatenate in an interactive tree",
+		Long: `Choose the files to concatenate in an interactive tree
+
+Shows the files selected by the file type flags with checkboxes and running
+token totals. Keys: space selects a file or directory, left/right collapse
+and expand, / searches, a selects all, s saves the selection to
+.concat/config.json, enter concatenates the selection and q quits.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			validate(cmd)
+
+			if err := app.Pick(&cfg, pickSaved); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	pickCmd.Flags().BoolVar(&pickSaved, "saved", false, "Concatenate the saved selection without showing the picker.")
+	rootCmd.AddCommand(pickCmd)
+
+	bundleCmd := &cobra.Command{
+		Use:   "bundle [name]",
+		Short: "Concatenate a named bundle from .concat/bundles/<name>.yaml",
+		Long: `Concatenate a named bundle from .concat/bundles/<name>.yaml
+
+A bundle manifest lists paths, directories or globs (relative to the project
+root), each optionally emitted as an outline (skeleton: true) or restricted
+to line ranges (lines: 120-260), along with a description that precedes the
+files and the file order (manifest, or any --order strategy):
+
+  description: Login, session and token refresh.
+  files:
+    - internal/auth/
+    - path: internal/server/routes.go
+      lines: 120-260
+    - path: "internal/db/**/*.go"
+      skeleton: true
+
+Without a name, lists the bundles. File type flags are optional and narrow
+the bundle further.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cleanExtensions()
+
+			var err error
+			if len(args) == 0 {
+				err = app.ListBundles()
+			} else {
+				err = app.RunBundle(&cfg, args[0])
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	rootCmd.AddCommand(bundleCmd)
+
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect or clear the on-disk content cache",
+	}
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:   "stats",
+		Short: "Show the cache location, size and contents",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := app.RunCacheStats(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
 			}
+		},
+	})
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Remove all cached content",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := app.RunCacheClear(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	})
+	rootCmd.AddCommand(cacheCmd)
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve bundles, trees and optimization over a local HTTP API",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := app.Serve(&cfg, &serveCfg); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	serveCmd.Flags().StringVar(&serveCfg.Addr, "addr", "127.0.0.1:7878", "Address to listen on.")
+	serveCmd.Flags().StringSliceVar(&serveCfg.AllowRoots, "allow-root", []string{}, "Directory requests may read from (default: the current directory). Can be used multiple times.")
+	serveCmd.Flags().Int64Var(&serveCfg.MaxBodyBytes, "max-body", 10<<20, "Maximum request body size in bytes.")
+	serveCmd.Flags().DurationVar(&serveCfg.Timeout, "timeout", time.Minute, "Time limit for reading a request and for producing a bundle.")
+	rootCmd.AddCommand(serveCmd)
+
+	mcpCmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Serve project context to agents over the Model Context Protocol (stdio)",
+		Long: `Serve project context to agents over the Model Context Protocol (stdio)
+
+Tools: list_files, read_files, get_tree, bundle and optimize.
+Resources: the files selected by the file type flags (-p, -l, --auto),
+or every non-ignored file if none are given.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cleanExtensions()
 
-			if err := app.Run(&cfg); err != nil {
+			if err := app.ServeMCP(&cfg, &serveCfg); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
 		},
 	}
+	mcpCmd.Flags().StringSliceVar(&serveCfg.AllowRoots, "allow-root", []string{}, "Directory tools may read from (default: the current directory). Can be used multiple times.")
+	mcpCmd.Flags().DurationVar(&serveCfg.Timeout, "timeout", time.Minute, "Time limit for producing a bundle.")
+	rootCmd.AddCommand(mcpCmd)
 
 	// Flags
 	rootCmd.PersistentFlags().StringSliceVarP(&cfg.Extensions, "pattern", "p", []string{}, "Include files with this extension (e.g., 'py', 'js'). Can be used multiple times.")
 	rootCmd.PersistentFlags().StringSliceVarP(&cfg.IgnorePatterns, "ignore", "i", []string{}, "Ignore files or directories matching this pattern. Can be used multiple times.")
-	rootCmd.PersistentFlags().StringVarP(&cfg.Output, "output", "o", "", "Output to a file instead of the clipboard.")
+	rootCmd.PersistentFlags().StringVarP(&cfg.Output, "output", "o", "", "Write the output to a file (atomically; gzip-compressed if it ends in .gz). Combines with --clipboard and --stdout.")
+	rootCmd.PersistentFlags().BoolVar(&cfg.Append, "append", false, "Append to the -o file instead of replacing it.")
+	rootCmd.PersistentFlags().BoolVar(&cfg.Clipboard, "clipboard", false, "Copy the output to the clipboard, also when writing to -o or --stdout.")
 	rootCmd.PersistentFlags().BoolVarP(&cfg.IncludeTree, "tree", "t", false, "Include a directory tree structure at the top of the output.")
+	rootCmd.PersistentFlags().StringVar(&cfg.TreeMode, "tree-mode", "included", "Files shown in the tree: 'included', 'full' (all non-ignored files) or 'both' (full, marking included files with *).")
+	rootCmd.PersistentFlags().BoolVar(&cfg.TreeStats, "tree-stats", false, "Annotate tree entries with size, estimated tokens and file counts.")
+	rootCmd.PersistentFlags().IntVar(&cfg.TreeDepth, "tree-depth", 0, "Limit the tree to N levels (0 = unlimited).")
+	rootCmd.PersistentFlags().BoolVar(&cfg.TreeDirsOnly, "tree-dirs-only", false, "Show only directories in the tree.")
+	rootCmd.PersistentFlags().IntVar(&cfg.TreeFanout, "tree-fanout", 0, "Collapse directories with more than N entries (0 = unlimited).")
+	rootCmd.PersistentFlags().BoolVar(&cfg.Skeleton, "skeleton", false, "Emit code outlines (signatures, types, doc comments) with function bodies elided.")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.Focus, "focus", []string{}, "Include this file or directory in full, its imports as outlines and nothing else. Can be used multiple times.")
+	rootCmd.PersistentFlags().IntVar(&cfg.Budget, "budget", 0, "Token budget for --focus; the most distant dependencies are dropped first (0 = unlimited).")
+	rootCmd.PersistentFlags().StringVar(&cfg.Order, "order", "path", "File order: path, dependency, reverse-dependency, mtime, size, priority or history.")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.Priority, "priority", []string{}, "Pattern ranking for --order priority; earlier patterns come first. Can be used multiple times.")
+	rootCmd.PersistentFlags().BoolVar(&cfg.RepoMap, "repo-map", false, "Prepend an index of top-level symbols (types, functions, classes) per file with line numbers.")
+	rootCmd.PersistentFlags().Float64Var(&cfg.RepoMapFraction, "repo-map-fraction", 0.1, "Maximum size of the repo map as a fraction of the estimated output tokens (0 = unlimited).")
+	rootCmd.PersistentFlags().StringVar(&cfg.Prompt, "prompt", "", "Wrap the files in a task template: review, explain, write-tests, refactor, or .concat/prompts/<name>.md.")
+	rootCmd.PersistentFlags().StringVar(&cfg.PromptFile, "prompt-file", "", "Wrap the files in the template in this file; {{.Body}} marks where the files go.")
+	rootCmd.PersistentFlags().StringVar(&cfg.Prefix, "prefix", "", "Text written before the files (after the header).")
+	rootCmd.PersistentFlags().StringVar(&cfg.Suffix, "suffix", "", "Text written after the files.")
+	rootCmd.PersistentFlags().StringVar(&cfg.DiffBase, "diff-base", "", "Revision the change is reviewed against, available to prompt templates as {{.DiffBase}}.")
+	rootCmd.PersistentFlags().StringVar(&cfg.Header, "header", app.HeaderMinimal, "Document header: 'none', 'minimal' (project name and time) or 'full' (adds git branch, commit, dirty state and remote).")
+	rootCmd.PersistentFlags().BoolVar(&cfg.Deterministic, "deterministic", false, "Produce byte-identical output for identical inputs: no timestamps (unless SOURCE_DATE_EPOCH is set), and file orders that depend on modification times or uncommitted changes are rejected.")
+	rootCmd.PersistentFlags().BoolVar(&cfg.CacheFriendly, "cache-friendly", false, "Keep the output prefix stable for LLM prompt caching: order files from least to most recently changed (git history) and leave volatile fields out of the header.")
+	rootCmd.PersistentFlags().BoolVar(&cfg.CacheBreakpoints, "cache-breakpoints", false, "Mark the end of the files unchanged since their last commit with <cache_breakpoint/> (XML output).")
+	rootCmd.PersistentFlags().BoolVar(&cfg.Watch, "watch", false, "Keep the output file (-o) up to date as files change.")
+	rootCmd.PersistentFlags().Int64Var(&cfg.MaxFileSize, "max-file-size", 0, "Skip files larger than N bytes (0 = unlimited).")
+	rootCmd.PersistentFlags().BoolVar(&cfg.NoCache, "no-cache", false, "Re-outline every file instead of reusing cached outlines from earlier runs.")
 	rootCmd.PersistentFlags().BoolVarP(&cfg.UseXML, "xml", "x", false, "Format output in XML (<file path='...'>) instead of Markdown.")
-	rootCmd.PersistentFlags().BoolVarP(&cfg.PrintToStdout, "stdout", "s", false, "Print output to stdout instead of clipboard.")
+	rootCmd.PersistentFlags().BoolVarP(&cfg.PrintToStdout, "stdout", "s", false, "Print output to stdout. Without -o, --clipboard or --stdout, output goes to stdout when it is a pipe and to the clipboard otherwise.")
+	rootCmd.PersistentFlags().StringVar(&cfg.ClipboardBackend, "clipboard-backend", infra.BackendAuto, "Clipboard to copy to: auto, osc52 (terminal escape, works over SSH), wl-copy, xclip, xsel, pbcopy, windows or file (a temporary file).")
 	rootCmd.PersistentFlags().BoolVarP(&cfg.ExcludeTests, "no-tests", "n", false, "Exclude test files (e.g., _test.go, .spec.ts).")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.TestPatterns, "test-pattern", []string{}, "Treat files matching this pattern as tests (e.g., '__tests__/', 'spec/', '*_it.go'). Can be used multiple times.")
+	rootCmd.PersistentFlags().BoolVar(&cfg.OnlyTests, "only-tests", false, "Include only test files.")
+	rootCmd.PersistentFlags().BoolVar(&cfg.PairTests, "pair-tests", false, "Include each source file's test counterpart (e.g., foo.go -> foo_test.go), even if tests are excluded.")
+	rootCmd.PersistentFlags().BoolVar(&cfg.FollowSymlinks, "follow-symlinks", false, "Follow directory symlinks and allow symlink targets outside the project root.")
+	rootCmd.PersistentFlags().StringSliceVarP(&cfg.Languages, "lang", "l", []string{}, "Include a language preset (go, typescript, javascript, python, rust, java, ruby). Can be used multiple times.")
+	rootCmd.PersistentFlags().BoolVar(&cfg.AutoDetect, "auto", false, "Detect the languages present in the project and include their presets.")
 
 	// Version flag is automatic with Cobra if we set Version field, but let's leave it for now.
 
@@ -59,3 +256,65 @@ Designed for easily grabbing project context for LLMs.`,
 		os.Exit(1)
 	}
 }
+
+// validate checks and normalizes the shared flags, exiting on error
+func validate(cmd *cobra.Command) {
+	if len(cfg.Extensions) == 0 && len(cfg.Languages) == 0 && !cfg.AutoDetect {
+		// Fail if no extensions provided, matching original script behavior
+		fmt.Println("Error: You must specify at least one file type to include with -p, -l or --auto.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cleanExtensions()
+}
+
+// setSource selects the archive given as the positional argument and checks
+// it against --rev and --watch, exiting on error
+func setSource(args []string) {
+	if len(args) > 0 {
+		if !archive.IsArchive(args[0]) {
+			fmt.Fprintf(os.Stderr, "Error: unsupported input %s: expected a .tar, .tar.gz, .tgz or .zip archive\n", args[0])
+			os.Exit(1)
+		}
+		cfg.Archive = args[0]
+	}
+
+	switch {
+	case cfg.Archive != "" && cfg.Rev != "":
+		fmt.Fprintln(os.Stderr, "Error: --rev cannot be used with an archive")
+		os.Exit(1)
+	case cfg.Watch && cfg.Archive != "":
+		fmt.Fprintln(os.Stderr, "Error: --watch cannot be used with an archive")
+		os.Exit(1)
+	case cfg.Watch && cfg.Rev != "":
+		fmt.Fprintln(os.Stderr, "Error: --watch cannot be used with --rev")
+		os.Exit(1)
+	}
+}
+
+// checkPaths rejects archives and --watch alongside path arguments, exiting
+// on error
+func checkPaths(args []string) {
+	for _, arg := range args {
+		if archive.IsArchive(arg) {
+			fmt.Fprintf(os.Stderr, "Error: archive %s cannot be combined with path arguments\n", arg)
+			os.Exit(1)
+		}
+	}
+	if cfg.Watch {
+		fmt.Fprintln(os.Stderr, "Error: --watch cannot be used with path arguments")
+		os.Exit(1)
+	}
+}
+
+// cleanExtensions normalizes the extension flags
+func cleanExtensions() {
+	// Clean extensions immediately upon receiving flags
+	for i, ext := range cfg.Extensions {
+		// Trim dot if user included it (e.g. .go -> go)
+		if len(ext) > 0 && ext[0] == '.' {
+			cfg.Extensions[i] = ext[1:]
+		}
+	}
+}
@@ -1,20 +1,33 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"text/tabwriter"
 
+	"github.com/nessaee/concat/internal/cache"
 	"github.com/nessaee/concat/internal/infra"
+	"github.com/nessaee/concat/internal/pricing"
 	"github.com/nessaee/concat/internal/transform"
 	"github.com/spf13/cobra"
 )
 
 var (
-	flagCompact      bool
-	flagStripHeaders bool
-	flagCost         bool
-	flagStdout       bool
+	flagCompact          bool
+	flagStripHeaders     bool
+	flagSkeleton         bool
+	flagCost             bool
+	flagStdout           bool
+	flagNoCache          bool
+	flagModel            string
+	flagCompare          bool
+	flagCostFormat       string
+	flagPricing          string
+	flagStats            bool
+	flagStatsFormat      string
+	flagClipboardBackend string
 )
 
 func main() {
@@ -25,6 +38,20 @@ func main() {
 Refines text streams for LLM consumption.
 Handles cost estimation, whitespace compaction, and license stripping.`,
 		Run: func(cmd *cobra.Command, args []string) {
+			if flagCostFormat != "text" && flagCostFormat != "json" {
+				fmt.Fprintf(os.Stderr, "Error: unknown cost format %q (expected text or json)\n", flagCostFormat)
+				os.Exit(1)
+			}
+			if flagStatsFormat != "text" && flagStatsFormat != "json" {
+				fmt.Fprintf(os.Stderr, "Error: unknown stats format %q (expected text or json)\n", flagStatsFormat)
+				os.Exit(1)
+			}
+			clipboard, err := infra.NewClipboard(flagClipboardBackend)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
 			// 1. Read Stream
 			input, err := io.ReadAll(os.Stdin)
 			if err != nil {
@@ -33,19 +60,32 @@ Handles cost estimation, whitespace compaction, and license stripping.`,
 			}
 			content := string(input)
 
-			// 2. Handle Analysis (Cost)
-			if flagCost {
-				tokens := len(content) / 4
-				fmt.Fprintf(os.Stderr, "Tokens: ~%d\n", tokens)
-			}
-
-			// 3. Apply Transformations
+			// 2. Apply Transformations
 			transformer := transform.NewTransformer(transform.Options{
 				Compact:      flagCompact,
 				StripHeaders: flagStripHeaders,
+				Skeleton:     flagSkeleton,
 			})
+			if !flagNoCache {
+				if c, err := cache.Open(); err != nil {
+					fmt.Fprintf(os.Stderr, "⚠ Cache disabled: %v\n", err)
+				} else {
+					transformer.SetCache(c)
+				}
+			}
 			result := transformer.Process(content)
 
+			// 3. Handle Analysis (Savings and cost of what is sent on)
+			if flagStats {
+				reportSavings(content, result)
+			}
+			if flagCost || flagCompare {
+				if err := reportCost(result); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+
 			// 4. Output Strategy
 			// Check if stdout is a pipe
 			stat, _ := os.Stdout.Stat()
@@ -60,13 +100,16 @@ Handles cost estimation, whitespace compaction, and license stripping.`,
 				// Let's follow that.
 			} else {
 				// TTY -> Clipboard
-				clipboard := infra.NewClipboard()
-				if err := clipboard.WriteAll(result); err != nil {
+				estTokens := len(result) / 4
+				used, err := clipboard.WriteAll(result)
+				switch f, isFile := used.(*infra.FileBackend); {
+				case err != nil:
 					fmt.Fprintf(os.Stderr, "Error copying to clipboard: %v\nPrinting to stdout instead.\n", err)
 					fmt.Print(result)
-				} else {
-					estTokens := len(result) / 4
-					fmt.Fprintf(os.Stderr, "✓ Copied to clipboard (%d bytes, ~%d tokens).\n", len(result), estTokens)
+				case isFile:
+					fmt.Fprintf(os.Stderr, "⚠ No clipboard available; wrote %d bytes (~%d tokens) to '%s'.\n", len(result), estTokens, f.Path)
+				default:
+					fmt.Fprintf(os.Stderr, "✓ Copied to clipboard (%s, %d bytes, ~%d tokens).\n", used.Name(), len(result), estTokens)
 				}
 			}
 		},
@@ -74,12 +117,116 @@ Handles cost estimation, whitespace compaction, and license stripping.`,
 
 	rootCmd.PersistentFlags().BoolVarP(&flagCompact, "compact", "c", false, "Reduce whitespace to save tokens.")
 	rootCmd.PersistentFlags().BoolVar(&flagStripHeaders, "strip-headers", false, "Strip copyright/license headers.")
-	rootCmd.PersistentFlags().BoolVar(&flagCost, "cost", false, "Estimate tokens (output to stderr).")
+	rootCmd.PersistentFlags().BoolVar(&flagSkeleton, "skeleton", false, "Reduce each file to its outline (signatures, types, doc comments).")
+	rootCmd.PersistentFlags().BoolVar(&flagCost, "cost", false, "Estimate tokens and cost of the output for --model (output to stderr).")
+	rootCmd.PersistentFlags().StringVar(&flagModel, "model", pricing.DefaultModel, "Model to price the output for with --cost.")
+	rootCmd.PersistentFlags().BoolVar(&flagCompare, "compare", false, "Compare tokens, cost and context window use across all models (output to stderr).")
+	rootCmd.PersistentFlags().StringVar(&flagCostFormat, "cost-format", "text", "Format of the cost report: 'text' or 'json'.")
+	rootCmd.PersistentFlags().StringVar(&flagPricing, "pricing", "", "JSON pricing file overriding the built-in table (default: pricing.json in the user config dir, if present).")
 	rootCmd.PersistentFlags().BoolVar(&flagCost, "dry-run", false, "Alias for --cost")
+	rootCmd.PersistentFlags().BoolVar(&flagStats, "stats", false, "Report the tokens saved by the transformations (output to stderr).")
+	rootCmd.PersistentFlags().StringVar(&flagStatsFormat, "stats-format", "text", "Format of the --stats report: 'text' or 'json'.")
+	rootCmd.PersistentFlags().BoolVar(&flagNoCache, "no-cache", false, "Re-outline every file instead of reusing cached outlines from earlier runs.")
 	rootCmd.PersistentFlags().BoolVarP(&flagStdout, "stdout", "s", false, "Print output to stdout instead of clipboard.")
+	rootCmd.PersistentFlags().StringVar(&flagClipboardBackend, "clipboard-backend", infra.BackendAuto, "Clipboard to copy to: auto, osc52 (terminal escape, works over SSH), wl-copy, xclip, xsel, pbcopy, windows or file (a temporary file).")
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
+
+// reportSavings prints the size of the input and output and what the
+// transformations saved to stderr
+func reportSavings(input, output string) {
+	in, out := int64(len(input)), int64(len(output))
+	saved := 0.0
+	if in > 0 {
+		saved = float64(in-out) * 100 / float64(in)
+	}
+
+	if flagStatsFormat == "json" {
+		enc := json.NewEncoder(os.Stderr)
+		enc.SetIndent("", "  ")
+		enc.Encode(map[string]any{
+			"inputBytes":   in,
+			"outputBytes":  out,
+			"inputTokens":  in / 4,
+			"outputTokens": out / 4,
+			"savedTokens":  in/4 - out/4,
+			"savedPercent": saved,
+		})
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Savings: ~%d -> ~%d tokens (saved ~%d, %.1f%%)\n", in/4, out/4, in/4-out/4, saved)
+}
+
+// reportCost prints the estimated tokens and cost of content to stderr: for
+// the selected model, or for every model in the pricing table with --compare.
+// Models whose context window is too small are flagged.
+func reportCost(content string) error {
+	table, err := pricing.Load(flagPricing)
+	if err != nil {
+		return err
+	}
+	selected, err := table.Lookup(flagModel)
+	if err != nil {
+		return err
+	}
+
+	tokens := int64(len(content) / 4)
+	estimates := []pricing.Estimate{selected.Estimate(tokens)}
+	if flagCompare {
+		estimates = estimates[:0]
+		for _, m := range table.Models() {
+			estimates = append(estimates, m.Estimate(tokens))
+		}
+	}
+
+	if flagCostFormat == "json" {
+		enc := json.NewEncoder(os.Stderr)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]any{"tokens": tokens, "model": selected.Name, "estimates": estimates})
+	}
+
+	if flagCompare {
+		tw := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "MODEL\tTOKENS\tINPUT COST\tCONTEXT")
+		for _, e := range estimates {
+			note := ""
+			if !e.Fits {
+				note = "  ⚠ does not fit"
+			}
+			fmt.Fprintf(tw, "%s\t~%d\t$%.4f\t%s%s\n", e.Model, e.Tokens, e.InputCost, contextUse(e), note)
+		}
+		tw.Flush()
+	} else {
+		e := estimates[0]
+		fmt.Fprintf(os.Stderr, "Tokens: ~%d\n", tokens)
+		fmt.Fprintf(os.Stderr, "Cost:   ~$%.4f input (%s, %s context)\n", e.InputCost, e.Model, contextUse(e))
+	}
+
+	if e := selected.Estimate(tokens); !e.Fits {
+		fmt.Fprintf(os.Stderr, "⚠ ~%d tokens exceed the %d-token context window of %s\n", tokens, e.ContextWindow, e.Model)
+	}
+	return nil
+}
+
+// contextUse describes the share of the context window an estimate uses
+func contextUse(e pricing.Estimate) string {
+	if e.ContextWindow == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%.1f%% of %s", e.ContextUsed, formatCount(e.ContextWindow))
+}
+
+// formatCount renders large counts compactly (e.g. 200k, 1.0M)
+func formatCount(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%dk", n/1_000)
+	}
+	return fmt.Sprintf("%d", n)
+}
@@ -0,0 +1,208 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nessaee/concat/internal/cache"
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/core"
+	"github.com/nessaee/concat/internal/prompt"
+	"github.com/nessaee/concat/internal/transform"
+)
+
+// This file holds the request handling shared by the HTTP API (serve) and
+// the MCP server (mcp)
+
+// bundleRequest describes a bundle: the fields of config.Config
+// (e.g. "extensions", "ignorePatterns", "includeTree") plus the project root
+// and the transformations to apply
+type bundleRequest struct {
+	Root string `json:"root"`
+	config.Config
+	Transform transform.Options `json:"transform"`
+	// List returns the selected files as JSON instead of their contents
+	List bool `json:"list"`
+}
+
+// bundleFile describes a selected file in a list response
+type bundleFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Tokens int64  `json:"tokens"`
+}
+
+// requestConfig returns the defaults for a request's configuration: the CLI
+// defaults, keeping the cache setting of the running server
+func requestConfig(cfg *config.Config) config.Config {
+	return config.Config{
+		TreeMode:        core.TreeModeIncluded,
+		Order:           core.OrderPath,
+		RepoMapFraction: 0.1,
+		NoCache:         cfg.NoCache,
+	}
+}
+
+// normalizeRequest validates a request configuration like the CLI does and
+// clears the settings that only make sense for the CLI
+func normalizeRequest(cfg *config.Config) error {
+	if len(cfg.Extensions) == 0 && len(cfg.Languages) == 0 && !cfg.AutoDetect {
+		return fmt.Errorf("at least one file type is required (extensions, languages or autoDetect)")
+	}
+	for i, ext := range cfg.Extensions {
+		cfg.Extensions[i] = strings.TrimPrefix(ext, ".")
+	}
+	if cfg.Archive != "" || cfg.Rev != "" {
+		return fmt.Errorf("archive and revision inputs are not supported; set root to a directory instead")
+	}
+	if cfg.PromptFile != "" {
+		// Only files under the allowed roots may be read; use prompt or prefix
+		return fmt.Errorf("promptFile is not supported; use prompt (a template in %s) or prefix instead", prompt.Dir)
+	}
+	// Symlink targets outside the root would escape the allowlist
+	cfg.FollowSymlinks = false
+	cfg.Output = ""
+	cfg.PrintToStdout = false
+	cfg.Clipboard = false
+	cfg.Append = false
+	cfg.Watch = false
+	return nil
+}
+
+// newRequestTransformer returns the transformer for opts, or nil if no
+// transformation was requested
+func newRequestTransformer(opts transform.Options, noCache bool) *transform.Transformer {
+	if opts == (transform.Options{}) {
+		return nil
+	}
+	t := transform.NewTransformer(opts)
+	if !noCache {
+		if c, err := cache.Open(); err == nil {
+			t.SetCache(c)
+		}
+	}
+	return t
+}
+
+// listFiles describes the selected files (relative to root)
+func listFiles(root string, files []string) []bundleFile {
+	list := make([]bundleFile, 0, len(files))
+	for _, f := range files {
+		info, err := os.Stat(filepath.Join(root, f))
+		if err != nil {
+			continue
+		}
+		list = append(list, bundleFile{Path: filepath.ToSlash(f), Size: info.Size(), Tokens: info.Size() / 4})
+	}
+	return list
+}
+
+// sectionWriter writes the preamble and file sections of a bundle as they are
+// rendered, transforming each one and flushing it if w supports it
+type sectionWriter struct {
+	w io.Writer
+	t *transform.Transformer
+}
+
+func (sw *sectionWriter) write(section string, preamble bool) error {
+	switch {
+	case sw.t == nil:
+	case preamble:
+		section = sw.t.ProcessPreamble(section)
+	default:
+		section = sw.t.Process(section)
+	}
+	if _, err := io.WriteString(sw.w, section); err != nil {
+		return err
+	}
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// writeFiles renders files through the pipeline one at a time, stopping when
+// ctx is done. It returns the number of files written.
+func (p *pipeline) writeFiles(ctx context.Context, sw *sectionWriter, files []string) (int, error) {
+	var buf bytes.Buffer
+	count := 0
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return count, fmt.Errorf("time limit exceeded after %d files", count)
+		}
+		buf.Reset()
+		ok, err := p.concatenator.RenderFile(&buf, p.root, f)
+		if err != nil {
+			return count, err
+		}
+		if !ok {
+			continue
+		}
+		if err := sw.write(buf.String(), false); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// allowlist restricts requests to projects inside the allowed roots
+type allowlist []string
+
+// newAllowlist resolves the allowed roots, defaulting to the current directory
+func newAllowlist(roots []string) (allowlist, error) {
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	var a allowlist
+	for _, root := range roots {
+		real, err := realPath(root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root %q: %w", root, err)
+		}
+		a = append(a, real)
+	}
+	return a, nil
+}
+
+// resolve resolves a requested path (relative paths are taken from the first
+// allowed root) and checks it against the allowlist
+func (a allowlist) resolve(path string) (string, error) {
+	if path == "" {
+		return a[0], nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(a[0], path)
+	}
+	real, err := realPath(path)
+	if err != nil {
+		return "", fmt.Errorf("%q is not accessible", path)
+	}
+	for _, allowed := range a {
+		if within(allowed, real) {
+			return real, nil
+		}
+	}
+	return "", fmt.Errorf("%q is outside the allowed roots", path)
+}
+
+// within reports whether path lies inside dir (both absolute)
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// realPath resolves symlinks and returns the absolute path
+func realPath(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(real)
+}
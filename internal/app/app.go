@@ -1,23 +1,129 @@
 package app
 
 import (
-	"bytes"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"time"
+	"slices"
+	"strings"
 
+	"github.com/nessaee/concat/internal/archive"
+	"github.com/nessaee/concat/internal/cache"
 	"github.com/nessaee/concat/internal/config"
 	"github.com/nessaee/concat/internal/core"
+	"github.com/nessaee/concat/internal/gitfs"
 	"github.com/nessaee/concat/internal/infra"
 	"github.com/nessaee/concat/internal/protocol"
+	"github.com/nessaee/concat/internal/symbols"
 )
 
 // Run is the main application entry point
 func Run(cfg *config.Config) error {
-	// 1. Initialize Filter
-	filter := core.NewFilter(cfg.Extensions, cfg.IgnorePatterns, cfg.ExcludeTests)
+	// 1-2. Initialize Filter and Components
+	p, err := newPipeline(cfg, ".")
+	if err != nil {
+		return err
+	}
+	defer closeSource(p.fsys)
+	return p.run()
+}
+
+// run writes the preamble and the selected files to the configured output
+func (p *pipeline) run() error {
+	cfg := p.cfg
+
+	// Determine Output Writer
+	out, err := openOutput(cfg)
+	if err != nil {
+		return err
+	}
+	defer out.Abort()
+	written := &core.CountingWriter{Writer: out}
+
+	// 3-4. Header, Tree and Repo Map
+	if err := p.writePreamble(written); err != nil {
+		return err
+	}
+
+	// 5. Process Files
+	var stats *core.Stats
+	if cfg.Stats {
+		stats = core.NewStats()
+		p.concatenator.SetStats(stats)
+	}
+	fmt.Fprintln(os.Stderr, "> Searching for files to process...")
+	count, size, err := p.concatenator.Process(p.root, written)
+	if err != nil {
+		return fmt.Errorf("processing failed: %w", err)
+	}
+	p.writeSuffix(written)
+
+	// 6. Finalize (Clipboard logic)
+	if err := out.Finish(fmt.Sprintf("%d files", count), size); err != nil {
+		return err
+	}
+	if stats != nil {
+		return writeStats(cfg, stats, written.Count)
+	}
+	return nil
+}
+
+// pipeline holds the components shared by a run
+type pipeline struct {
+	cfg          *config.Config
+	root         string
+	fsys         fs.FS
+	filter       *core.Filter
+	formatter    protocol.Formatter
+	concatenator *core.Concatenator
+	sections     []section // written after the document header
+	suffix       string    // prompt text written after the files
+}
+
+// section is a named block of the preamble, written through the formatter
+type section struct {
+	name    string
+	content string
+}
+
+// newPipeline builds the filter, formatter and concatenator for cfg and the
+// project at root, restricting the selection in focus mode
+func newPipeline(cfg *config.Config, root string) (*pipeline, error) {
+	fsys, root, err := openSource(cfg, root)
+	if err != nil {
+		return nil, err
+	}
+	p, err := buildPipeline(cfg, fsys, root)
+	if err != nil {
+		closeSource(fsys)
+		return nil, err
+	}
+	return p, nil
+}
+
+// buildPipeline is newPipeline for the project at root in fsys (nil for the
+// OS file system)
+func buildPipeline(cfg *config.Config, fsys fs.FS, root string) (*pipeline, error) {
+	if cfg.CacheFriendly {
+		if cfg.Order != "" && cfg.Order != core.OrderPath && cfg.Order != core.OrderHistory {
+			return nil, fmt.Errorf("--cache-friendly cannot be combined with --order %s", cfg.Order)
+		}
+		friendly := *cfg
+		friendly.Order = core.OrderHistory
+		cfg = &friendly
+	}
+
+	filter, err := newFilter(cfg, projectFS(fsys, root))
+	if err != nil {
+		return nil, err
+	}
+	if fsys == nil {
+		if err := excludeOutput(filter, cfg.Output, root); err != nil {
+			return nil, err
+		}
+	}
 
 	// Determine Formatter
 	var formatter protocol.Formatter
@@ -27,73 +133,374 @@ func Run(cfg *config.Config) error {
 		formatter = &protocol.MarkdownFormatter{}
 	}
 
-	// 2. Initialize Components
 	concatenator := core.NewConcatenator(filter, cfg, formatter)
+	if fsys != nil {
+		concatenator.SetFS(fsys)
+	} else if c := openCache(cfg); c != nil {
+		concatenator.SetCache(c)
+	}
 
-	// Determine Output Writer
-	var outWriter io.Writer
-	var clipboardBuffer *bytes.Buffer
-
-	stat, _ := os.Stdout.Stat()
-	isPipe := (stat.Mode() & os.ModeCharDevice) == 0
+	// Git history for ordering and cache breakpoints (Optional)
+	if cfg.Order == core.OrderHistory || cfg.CacheBreakpoints {
+		if cfg.Archive != "" {
+			return nil, fmt.Errorf("archives have no git history for --order %s or --cache-breakpoints", core.OrderHistory)
+		}
+		dir := root
+		if fsys != nil {
+			dir = "."
+		}
+		// Uncommitted files have no history, so their place would change
+		// once they are committed
+		if cfg.Deterministic && cfg.Rev == "" {
+			if info, err := gitfs.Describe(dir, ""); err == nil && info.Dirty {
+				return nil, fmt.Errorf("--deterministic cannot order by git history with uncommitted changes (commit them or use --rev)")
+			}
+		}
+		history, err := gitfs.History(dir, cfg.Rev)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read git history: %w", err)
+		}
+		concatenator.SetHistory(history)
+	}
 
-	if cfg.PrintToStdout || isPipe {
-		outWriter = os.Stdout
-	} else if cfg.Output != "" {
-		f, err := os.Create(cfg.Output)
+	// Restrict to the focused files and their dependencies (Optional)
+	if len(cfg.Focus) > 0 {
+		selection, err := planFocus(cfg, concatenator, root)
 		if err != nil {
-			return fmt.Errorf("failed to create output file: %w", err)
+			return nil, err
 		}
-		defer f.Close()
-		outWriter = f
-	} else {
-		clipboardBuffer = new(bytes.Buffer)
-		outWriter = clipboardBuffer
+		filter.Select(selection)
 	}
 
-	cwd, err := os.Getwd()
+	return &pipeline{cfg: cfg, root: root, fsys: fsys, filter: filter, formatter: formatter, concatenator: concatenator}, nil
+}
+
+// writePreamble writes everything that precedes the files: the document
+// header, the directory tree and the repo map
+func (p *pipeline) writePreamble(w io.Writer) error {
+	project, err := projectName(p.cfg, p.root)
 	if err != nil {
 		return err
 	}
 
 	// 3. Generate Header
-	header := fmt.Sprintf("---\nProject: %s\nGenerated: %s\n---\n\n", filepath.Base(cwd), time.Now().Format(time.RFC1123))
-	fmt.Fprint(outWriter, header)
+	header, err := p.header(project)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, header)
+
+	// Instructions around the body (Optional)
+	prefix, suffix, err := p.promptText(project)
+	if err != nil {
+		return err
+	}
+	if prefix != "" {
+		fmt.Fprintf(w, "%s\n\n", prefix)
+	}
+	p.suffix = suffix
+
+	for _, s := range p.sections {
+		p.formatter.WriteSection(w, s.name, s.content)
+	}
 
 	// 4. Generate Tree (Optional)
-	if cfg.IncludeTree {
+	if p.cfg.IncludeTree {
 		fmt.Fprintln(os.Stderr, "> Generating directory tree...")
-		treeGen := core.NewTreeGenerator(filter)
-		treeStr, err := treeGen.Generate(".")
+		treeGen, err := newTreeGenerator(p.cfg, p.filter, p.concatenator, p.fsys, p.root)
+		if err != nil {
+			return err
+		}
+		treeStr, err := treeGen.Generate(p.root)
 		if err != nil {
 			return fmt.Errorf("failed to generate tree: %w", err)
 		}
-		fmt.Fprint(outWriter, treeStr+"\n---\n\n")
+		fmt.Fprint(w, treeStr+"\n---\n\n")
 	}
 
-	// 5. Process Files
-	fmt.Fprintln(os.Stderr, "> Searching for files to process...")
-	count, size, err := concatenator.Process(".", outWriter)
+	// Repo Map (Optional)
+	if p.cfg.RepoMap {
+		fmt.Fprintln(os.Stderr, "> Generating repo map...")
+		repoMap, err := buildRepoMap(p.cfg, p.concatenator, p.root)
+		if err != nil {
+			return fmt.Errorf("failed to generate repo map: %w", err)
+		}
+		p.formatter.WriteSection(w, "Repo Map", repoMap)
+	}
+	return nil
+}
+
+// RunTree emits only the directory tree, without file contents
+func RunTree(cfg *config.Config) error {
+	treeStr, err := generateTree(cfg, ".")
 	if err != nil {
-		return fmt.Errorf("processing failed: %w", err)
+		return err
 	}
 
-	// 6. Finalize (Clipboard logic)
-	estTokens := size / 4
+	out, err := openOutput(cfg)
+	if err != nil {
+		return err
+	}
+	defer out.Abort()
+	fmt.Fprint(out, treeStr)
+
+	return out.Finish("directory tree", int64(len(treeStr)))
+}
+
+// excludeOutput keeps the -o file, and the temporary files it is written
+// through, out of the selection of the project at root, so the output of an
+// earlier run is never read back into the next
+func excludeOutput(filter *core.Filter, output, root string) error {
+	if output == "" {
+		return nil
+	}
+	out, err := filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+	base, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	filter.Exclude(func(path string) bool {
+		abs := filepath.Join(base, path)
+		return abs == out || infra.IsAtomicTemp(abs, out)
+	})
+	return nil
+}
+
+// generateTree renders the directory tree of the project at root on its own
+func generateTree(cfg *config.Config, root string) (string, error) {
+	fsys, root, err := openSource(cfg, root)
+	if err != nil {
+		return "", err
+	}
+	defer closeSource(fsys)
+	filter, err := newFilter(cfg, projectFS(fsys, root))
+	if err != nil {
+		return "", err
+	}
+
+	concatenator := core.NewConcatenator(filter, cfg, &protocol.MarkdownFormatter{})
+	if fsys != nil {
+		concatenator.SetFS(fsys)
+	}
+	treeGen, err := newTreeGenerator(cfg, filter, concatenator, fsys, root)
+	if err != nil {
+		return "", err
+	}
+	treeStr, err := treeGen.Generate(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate tree: %w", err)
+	}
+	return treeStr, nil
+}
+
+// buildRepoMap renders the symbol index of the files to be processed, sized to
+// cfg.RepoMapFraction of their estimated tokens
+func buildRepoMap(cfg *config.Config, concatenator *core.Concatenator, root string) (string, error) {
+	files, err := concatenator.Collect(root)
+	if err != nil {
+		return "", err
+	}
 
-	if clipboardBuffer != nil {
-		clipboard := infra.NewClipboard()
-		err := clipboard.WriteAll(clipboardBuffer.String())
+	var total int64
+	for _, f := range files {
+		if info, err := concatenator.Stat(filepath.Join(root, f)); err == nil {
+			total += info.Size()
+		}
+	}
+	maxTokens := 0
+	if cfg.RepoMapFraction > 0 {
+		maxTokens = max(1, int(float64(total/4)*cfg.RepoMapFraction))
+	}
+
+	fsys, err := concatenator.FS(root)
+	if err != nil {
+		return "", err
+	}
+	return symbols.RepoMapFS(fsys, files, maxTokens)
+}
+
+// newTreeGenerator creates a TreeGenerator over fsys (nil for the OS file
+// system) whose sibling order follows the configured file order
+func newTreeGenerator(cfg *config.Config, filter *core.Filter, concatenator *core.Concatenator, fsys fs.FS, root string) (*core.TreeGenerator, error) {
+	treeGen := core.NewTreeGenerator(filter, cfg)
+	if fsys != nil {
+		treeGen.SetFS(fsys)
+	}
+	if cfg.Order != "" && cfg.Order != core.OrderPath {
+		files, err := concatenator.Collect(root)
 		if err != nil {
-			return fmt.Errorf("failed to copy to clipboard: %w", err)
+			return nil, err
 		}
-		fmt.Printf("✓ Copied %d files (%d bytes, ~%d tokens) to clipboard.\n", count, size, estTokens)
-	} else if cfg.Output != "" {
-		fmt.Printf("✓ Wrote %d files (%d bytes, ~%d tokens) to '%s'.\n", count, size, estTokens, cfg.Output)
-	} else {
-		// Stdout logic: log to stderr
-		fmt.Fprintf(os.Stderr, "✓ Output %d files (%d bytes, ~%d tokens) to stdout.\n", count, size, estTokens)
+		treeGen.SetOrder(files)
+	}
+	return treeGen, nil
+}
+
+// openSource opens the archive or git revision given as input, returning its
+// files and the root to walk within them. Otherwise fsys is nil (the OS file
+// system) and root is unchanged. Release it with closeSource.
+func openSource(cfg *config.Config, root string) (fs.FS, string, error) {
+	switch {
+	case cfg.Archive != "":
+		fsys, err := archive.Open(cfg.Archive)
+		if err != nil {
+			return nil, "", err
+		}
+		return fsys, ".", nil
+	case cfg.Rev != "":
+		fsys, err := gitfs.Open(root, cfg.Rev)
+		if err != nil {
+			return nil, "", err
+		}
+		return fsys, ".", nil
+	}
+	return nil, root, nil
+}
+
+// closeSource releases a file system returned by openSource
+func closeSource(fsys fs.FS) {
+	if c, ok := fsys.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// projectFS returns the files of the project at root in fsys (nil for the OS
+// file system)
+func projectFS(fsys fs.FS, root string) fs.FS {
+	if fsys != nil {
+		return fsys
+	}
+	return os.DirFS(root)
+}
+
+// projectName names the project in the document header: the archive or
+// the root directory, with the revision if one was given
+func projectName(cfg *config.Config, root string) (string, error) {
+	if cfg.Archive != "" {
+		return archive.Name(cfg.Archive), nil
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Rev != "" {
+		return fmt.Sprintf("%s (%s)", filepath.Base(abs), cfg.Rev), nil
+	}
+	return filepath.Base(abs), nil
+}
+
+// openCache opens the on-disk content cache unless disabled. A cache that
+// cannot be opened only costs speed, so it is reported and skipped.
+func openCache(cfg *config.Config) *cache.Cache {
+	if cfg.NoCache {
+		return nil
+	}
+	c, err := cache.Open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠ Cache disabled: %v\n", err)
+		return nil
 	}
+	return c
+}
 
+// validateConfig rejects flag combinations that cannot work together
+func validateConfig(cfg *config.Config) error {
+	if cfg.OnlyTests && (cfg.ExcludeTests || cfg.PairTests) {
+		return fmt.Errorf("--only-tests cannot be combined with --no-tests or --pair-tests")
+	}
+	if cfg.StatsFormat != "" && cfg.StatsFormat != StatsFormatText && cfg.StatsFormat != StatsFormatJSON {
+		return fmt.Errorf("unknown stats format %q (expected %s or %s)", cfg.StatsFormat, StatsFormatText, StatsFormatJSON)
+	}
+	if cfg.Prompt != "" && cfg.PromptFile != "" {
+		return fmt.Errorf("--prompt cannot be combined with --prompt-file")
+	}
+	if cfg.Order != "" && !slices.Contains(core.Orders, cfg.Order) {
+		return fmt.Errorf("unknown order %q (expected one of %s)", cfg.Order, strings.Join(core.Orders, ", "))
+	}
+	if cfg.ClipboardBackend != "" && !slices.Contains(infra.Backends, cfg.ClipboardBackend) {
+		return fmt.Errorf("unknown clipboard backend %q (expected one of %s)", cfg.ClipboardBackend, strings.Join(infra.Backends, ", "))
+	}
+	if cfg.Append && cfg.Output == "" {
+		return fmt.Errorf("--append requires -o")
+	}
+	if cfg.CacheBreakpoints && !cfg.UseXML {
+		return fmt.Errorf("--cache-breakpoints requires --xml")
+	}
+	if cfg.Deterministic && cfg.Order == core.OrderMtime {
+		return fmt.Errorf("--order %s cannot be used with --deterministic", core.OrderMtime)
+	}
+	switch cfg.Header {
+	case "", HeaderNone, HeaderMinimal, HeaderFull:
+	default:
+		return fmt.Errorf("unknown header %q (expected %s, %s or %s)", cfg.Header, HeaderNone, HeaderMinimal, HeaderFull)
+	}
 	return nil
 }
+
+// nonIgnoredFiles returns every non-ignored file under root, whatever its type
+func nonIgnoredFiles(cfg *config.Config, root string) ([]string, error) {
+	filter := core.NewFilterAt(root, nil, cfg.IgnorePatterns, cfg.ExcludeTests)
+	walker, err := core.NewWalker(root, cfg.FollowSymlinks)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = walker.Walk(func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if filter.IsIgnored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// newFilter builds the Filter for cfg and the project files in fsys, applying
+// test settings and language presets
+func newFilter(cfg *config.Config, fsys fs.FS) (*core.Filter, error) {
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	filter := core.NewFilterFS(fsys, cfg.Extensions, cfg.IgnorePatterns, cfg.ExcludeTests)
+	filter.AddTestPatterns(cfg.TestPatterns)
+	filter.SetOnlyTests(cfg.OnlyTests)
+
+	// Apply Language Presets
+	langs, err := core.ResolveLanguages(cfg.Languages)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AutoDetect {
+		detected, err := core.DetectLanguagesFS(fsys, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect languages: %w", err)
+		}
+		if len(detected) == 0 && len(cfg.Extensions) == 0 && len(langs) == 0 {
+			return nil, fmt.Errorf("no known languages detected; specify file types with -p or -l")
+		}
+		for _, l := range detected {
+			fmt.Fprintf(os.Stderr, "> Detected language: %s\n", l.Name)
+		}
+		langs = append(langs, detected...)
+	}
+	for _, l := range langs {
+		filter.AddLanguage(l)
+	}
+	return filter, nil
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nessaee/concat/internal/bundle"
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/core"
+)
+
+// RunBundle emits the files of the named bundle (.concat/bundles/<name>.yaml)
+// like Run, with the bundle's description as a preamble. Without file type
+// flags, the bundle may name files of any type.
+func RunBundle(cfg *config.Config, name string) error {
+	if len(cfg.Focus) > 0 {
+		return fmt.Errorf("--focus cannot be used with a bundle")
+	}
+	b, err := bundle.Load(".", name)
+	if err != nil {
+		return err
+	}
+
+	runCfg := *cfg
+	if b.Order == bundle.OrderManifest {
+		runCfg.Order = core.OrderPriority
+		runCfg.Priority = b.Patterns()
+	} else {
+		runCfg.Order = b.Order
+	}
+	if len(cfg.Extensions) == 0 && len(cfg.Languages) == 0 && !cfg.AutoDetect {
+		if runCfg.Extensions, err = bundleExtensions(&runCfg, ".", b); err != nil {
+			return err
+		}
+	}
+
+	p, err := newPipeline(&runCfg, ".")
+	if err != nil {
+		return err
+	}
+	defer closeSource(p.fsys)
+
+	// Resolve the entries through the filter (ignore rules, tests, types)
+	files, err := p.concatenator.Collect(p.root)
+	if err != nil {
+		return err
+	}
+	selection := b.Resolve(files)
+	for _, pattern := range selection.Unmatched {
+		fmt.Fprintf(os.Stderr, "⚠ Bundle entry %s matches no files\n", pattern)
+	}
+	if len(selection.Files) == 0 {
+		return fmt.Errorf("bundle %q matches no files", name)
+	}
+	p.filter.Select(selection.Files)
+	p.filter.SelectLines(selection.Lines)
+
+	if b.Description != "" {
+		p.sections = append(p.sections, section{name: "Bundle: " + b.Name, content: b.Description})
+	}
+	fmt.Fprintf(os.Stderr, "> Bundle %s: %d files\n", b.Name, len(selection.Files))
+	return p.run()
+}
+
+// bundleExtensions returns the extensions of the non-ignored files the
+// bundle's entries match, so they pass the filter without type flags
+func bundleExtensions(cfg *config.Config, root string, b *bundle.Bundle) ([]string, error) {
+	files, err := nonIgnoredFiles(cfg, root)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var extensions []string
+	for f := range b.Resolve(files).Files {
+		ext := strings.TrimPrefix(filepath.Ext(f), ".")
+		if !seen[ext] {
+			seen[ext] = true
+			extensions = append(extensions, ext)
+		}
+	}
+	sort.Strings(extensions)
+	return extensions, nil
+}
+
+// ListBundles prints the bundles of the current project with the first line
+// of their descriptions
+func ListBundles() error {
+	names, err := bundle.List(".")
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(os.Stderr, "No bundles in %s\n", bundle.Dir)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		b, err := bundle.Load(".", name)
+		if err != nil {
+			fmt.Fprintf(tw, "%s\t⚠ %v\n", name, err)
+			continue
+		}
+		summary, _, _ := strings.Cut(b.Description, "\n")
+		fmt.Fprintf(tw, "%s\t%s\n", name, summary)
+	}
+	return tw.Flush()
+}
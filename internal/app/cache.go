@@ -0,0 +1,43 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/nessaee/concat/internal/cache"
+)
+
+// RunCacheStats prints a summary of the on-disk content cache
+func RunCacheStats() error {
+	c, err := cache.Open()
+	if err != nil {
+		return err
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		return fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	fmt.Printf("Location: %s\n", stats.Dir)
+	fmt.Printf("Files:    %d\n", stats.Entries)
+	fmt.Printf("Outputs:  %d\n", stats.Blobs)
+	fmt.Printf("Size:     %d bytes\n", stats.Bytes)
+	fmt.Printf("Tokens:   ~%d\n", stats.Tokens)
+	return nil
+}
+
+// RunCacheClear removes everything from the on-disk content cache
+func RunCacheClear() error {
+	c, err := cache.Open()
+	if err != nil {
+		return err
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		return fmt.Errorf("failed to read cache: %w", err)
+	}
+	if err := c.Clear(); err != nil {
+		return fmt.Errorf("failed to clear cache: %w", err)
+	}
+	fmt.Printf("✓ Cleared cache (%d files, %d bytes).\n", stats.Entries, stats.Bytes)
+	return nil
+}
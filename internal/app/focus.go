@@ -0,0 +1,128 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/core"
+	"github.com/nessaee/concat/internal/deps"
+	"github.com/nessaee/concat/internal/skeleton"
+)
+
+// planFocus selects the focused files in full and the files they (transitively)
+// import as outlines. With a token budget, the most distant dependencies are
+// dropped first; focused files are always kept.
+func planFocus(cfg *config.Config, concatenator *core.Concatenator, root string) (map[string]core.Inclusion, error) {
+	files, err := concatenator.Collect(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Resolve focus paths (files or directories) against the candidates
+	var focused []string
+	for _, focus := range cfg.Focus {
+		target := path.Clean(filepath.ToSlash(focus))
+		matched := false
+		for _, f := range files {
+			f = filepath.ToSlash(f)
+			if target == "." || f == target || strings.HasPrefix(f, target+"/") {
+				focused = append(focused, f)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("focus path %q matches no included files", focus)
+		}
+	}
+
+	// 2. Walk the import graph outwards from the focused files
+	fsys, err := concatenator.FS(root)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := deps.BuildFS(fsys, files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build dependency graph: %w", err)
+	}
+	dist := graph.Distances(focused)
+
+	// Go files share their package with their non-test siblings
+	fileSet := deps.NewFileSetFS(fsys, files)
+	for _, f := range focused {
+		if !strings.HasSuffix(f, ".go") {
+			continue
+		}
+		for _, sibling := range fileSet.InDir(path.Dir(f)) {
+			if _, ok := dist[sibling]; !ok && strings.HasSuffix(sibling, ".go") && !strings.HasSuffix(sibling, "_test.go") {
+				dist[sibling] = 1
+			}
+		}
+	}
+
+	var dependencies []string
+	for f, d := range dist {
+		if d > 0 {
+			dependencies = append(dependencies, f)
+		}
+	}
+	sort.Slice(dependencies, func(i, j int) bool {
+		di, dj := dist[dependencies[i]], dist[dependencies[j]]
+		if di != dj {
+			return di < dj
+		}
+		return dependencies[i] < dependencies[j]
+	})
+
+	// 3. Assemble the selection within the budget
+	selection := make(map[string]core.Inclusion)
+	var tokens int64
+	for _, f := range focused {
+		selection[f] = core.IncludeFull
+		if info, err := concatenator.Stat(filepath.Join(root, f)); err == nil {
+			tokens += info.Size() / 4
+		}
+	}
+	if cfg.Budget > 0 && tokens > int64(cfg.Budget) {
+		fmt.Fprintf(os.Stderr, "⚠ Focused files alone (~%d tokens) exceed the budget of %d tokens\n", tokens, cfg.Budget)
+	}
+
+	dropped := 0
+	for _, f := range dependencies {
+		if cfg.Budget > 0 {
+			cost, err := outlineTokens(fsys, f)
+			if err != nil {
+				return nil, err
+			}
+			if tokens+cost > int64(cfg.Budget) {
+				dropped++
+				continue
+			}
+			tokens += cost
+		}
+		selection[f] = core.IncludeSkeleton
+	}
+
+	fmt.Fprintf(os.Stderr, "> Focus: %d files in full, %d dependencies as outlines", len(focused), len(dependencies)-dropped)
+	if dropped > 0 {
+		fmt.Fprintf(os.Stderr, " (%d dropped to fit the budget)", dropped)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	return selection, nil
+}
+
+// outlineTokens estimates the tokens of a file's outline
+func outlineTokens(fsys fs.FS, path string) (int64, error) {
+	src, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return 0, err
+	}
+	out, _, _ := skeleton.Outline(path, src)
+	return int64(len(out)) / 4, nil
+}
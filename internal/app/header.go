@@ -0,0 +1,83 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nessaee/concat/internal/gitfs"
+)
+
+// Levels of the document header (--header)
+const (
+	HeaderNone    = "none"
+	HeaderMinimal = "minimal"
+	HeaderFull    = "full"
+)
+
+// header returns the document header: the project name and generation time
+// (minimal), plus the git branch, commit, dirty state and remote (full)
+func (p *pipeline) header(project string) (string, error) {
+	cfg := p.cfg
+	if cfg.Header == HeaderNone {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	fmt.Fprintf(&sb, "Project: %s\n", project)
+	// Cache-friendly headers leave out what changes between runs and commits
+	generated, ok, err := generatedAt(cfg.Deterministic || cfg.CacheFriendly)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		fmt.Fprintf(&sb, "Generated: %s\n", generated.Format(time.RFC1123))
+	}
+
+	if cfg.Header == HeaderFull && cfg.Archive == "" {
+		dir := p.root
+		if p.fsys != nil {
+			dir = "."
+		}
+		info, err := gitfs.Describe(dir, cfg.Rev)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ No git metadata in the header: %v\n", err)
+		} else {
+			if info.Branch != "" {
+				fmt.Fprintf(&sb, "Branch: %s\n", info.Branch)
+			}
+			switch {
+			case cfg.CacheFriendly:
+			case info.Dirty:
+				fmt.Fprintf(&sb, "Commit: %s (dirty)\n", info.Commit)
+			default:
+				fmt.Fprintf(&sb, "Commit: %s\n", info.Commit)
+			}
+			if info.Remote != "" {
+				fmt.Fprintf(&sb, "Remote: %s\n", info.Remote)
+			}
+		}
+	}
+	sb.WriteString("---\n\n")
+	return sb.String(), nil
+}
+
+// generatedAt returns the time to report as the generation time:
+// SOURCE_DATE_EPOCH when set (for reproducible builds), otherwise the
+// current time, unless the output must be deterministic
+func generatedAt(deterministic bool) (time.Time, bool, error) {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: expected seconds since the Unix epoch", epoch)
+		}
+		return time.Unix(seconds, 0).UTC(), true, nil
+	}
+	if deterministic {
+		return time.Time{}, false, nil
+	}
+	return time.Now(), true, nil
+}
@@ -0,0 +1,360 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/core"
+	"github.com/nessaee/concat/internal/mcp"
+	"github.com/nessaee/concat/internal/transform"
+)
+
+// ServeMCP runs the MCP server on stdin/stdout until stdin closes or the
+// process is interrupted. Progress messages keep going to stderr.
+func ServeMCP(cfg *config.Config, serveCfg *config.ServeConfig) error {
+	srv, err := NewMCPServer(cfg, serveCfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return srv.Serve(ctx, os.Stdin, os.Stdout)
+}
+
+// mcpServer backs the MCP tools and resources. cfg supplies the defaults of
+// each call and, through the CLI flags, the files listed as resources.
+type mcpServer struct {
+	cfg   *config.Config
+	opts  *config.ServeConfig
+	roots allowlist
+}
+
+// NewMCPServer creates the MCP server with concat's tools and file resources
+func NewMCPServer(cfg *config.Config, serveCfg *config.ServeConfig) (*mcp.Server, error) {
+	roots, err := newAllowlist(serveCfg.AllowRoots)
+	if err != nil {
+		return nil, err
+	}
+	s := &mcpServer{cfg: cfg, opts: serveCfg, roots: roots}
+
+	srv := mcp.NewServer("concat", "0.1.4")
+	srv.AddTool(mcp.Tool{
+		Name:        "list_files",
+		Description: "List the project files matching the filters, with sizes and estimated tokens.",
+		InputSchema: objectSchema(filterProperties()),
+		Handler:     s.listFiles,
+	})
+	srv.AddTool(mcp.Tool{
+		Name:        "read_files",
+		Description: "Read specific files (paths relative to the project root) in the bundle format.",
+		InputSchema: objectSchema(map[string]any{
+			"root":     stringSchema("Project directory, relative to the server root."),
+			"paths":    listSchema("File paths relative to the project root."),
+			"useXML":   boolSchema("Wrap files in <file> tags instead of Markdown markers."),
+			"skeleton": boolSchema("Emit outlines with function bodies elided."),
+		}, "paths"),
+		Handler: s.readFiles,
+	})
+	srv.AddTool(mcp.Tool{
+		Name:        "get_tree",
+		Description: "Render the project's directory tree.",
+		InputSchema: objectSchema(merge(filterProperties(), map[string]any{
+			"treeMode":     enumSchema("Files shown: included files, all non-ignored files, or both with included ones marked.", core.TreeModeIncluded, core.TreeModeFull, core.TreeModeBoth),
+			"treeDepth":    intSchema("Limit the tree to N levels (0 = unlimited)."),
+			"treeStats":    boolSchema("Annotate entries with size, estimated tokens and file counts."),
+			"treeDirsOnly": boolSchema("Show only directories."),
+			"treeFanout":   intSchema("Collapse directories with more than N entries (0 = unlimited)."),
+		})),
+		Handler: s.getTree,
+	})
+	srv.AddTool(mcp.Tool{
+		Name:        "bundle",
+		Description: "Concatenate the project files matching the filters into one document, optionally focused on some paths within a token budget.",
+		InputSchema: objectSchema(merge(filterProperties(), map[string]any{
+			"useXML":      boolSchema("Wrap files in <file> tags instead of Markdown markers."),
+			"skeleton":    boolSchema("Emit outlines with function bodies elided."),
+			"focus":       listSchema("Files or directories to include in full; their imports are included as outlines and nothing else."),
+			"budget":      intSchema("Token budget for focus; the most distant dependencies are dropped first (0 = unlimited)."),
+			"order":       enumSchema("File order.", core.Orders...),
+			"priority":    listSchema("Pattern ranking for the priority order."),
+			"includeTree": boolSchema("Include the directory tree at the top."),
+			"repoMap":     boolSchema("Include an index of top-level symbols per file."),
+			"transform":   objectSchemaValue(transformProperties()),
+		})),
+		Handler: s.bundle,
+	})
+	srv.AddTool(mcp.Tool{
+		Name:        "optimize",
+		Description: "Apply opt's transformations to text, e.g. a bundle.",
+		InputSchema: objectSchema(merge(map[string]any{
+			"text": stringSchema("The text to transform."),
+		}, transformProperties()), "text"),
+		Handler: s.optimize,
+	})
+	srv.SetResources(s)
+	return srv, nil
+}
+
+// decodeBundleRequest decodes tool arguments shaped like a POST /bundle body
+func (s *mcpServer) decodeBundleRequest(args json.RawMessage) (*bundleRequest, string, error) {
+	req := &bundleRequest{Config: requestConfig(s.cfg)}
+	if err := decodeArgs(args, req); err != nil {
+		return nil, "", err
+	}
+	root, err := s.roots.resolve(req.Root)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := normalizeRequest(&req.Config); err != nil {
+		return nil, "", err
+	}
+	return req, root, nil
+}
+
+func (s *mcpServer) listFiles(ctx context.Context, args json.RawMessage) (string, error) {
+	req, root, err := s.decodeBundleRequest(args)
+	if err != nil {
+		return "", err
+	}
+	p, err := newPipeline(&req.Config, root)
+	if err != nil {
+		return "", err
+	}
+	files, err := p.concatenator.Collect(root)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(map[string]any{"files": listFiles(root, files)}, "", "  ")
+	return string(data), err
+}
+
+func (s *mcpServer) readFiles(ctx context.Context, args json.RawMessage) (string, error) {
+	var req struct {
+		Root     string   `json:"root"`
+		Paths    []string `json:"paths"`
+		UseXML   bool     `json:"useXML"`
+		Skeleton bool     `json:"skeleton"`
+	}
+	if err := decodeArgs(args, &req); err != nil {
+		return "", err
+	}
+	root, err := s.roots.resolve(req.Root)
+	if err != nil {
+		return "", err
+	}
+
+	cfg := requestConfig(s.cfg)
+	cfg.UseXML = req.UseXML
+	cfg.Skeleton = req.Skeleton
+	p, err := newPipeline(&cfg, root)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	for _, path := range req.Paths {
+		real, err := s.roots.resolve(filepath.Join(root, path))
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(root, real)
+		if err != nil {
+			return "", err
+		}
+		if _, err := p.concatenator.RenderFile(&buf, root, rel); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+func (s *mcpServer) getTree(ctx context.Context, args json.RawMessage) (string, error) {
+	req, root, err := s.decodeBundleRequest(args)
+	if err != nil {
+		return "", err
+	}
+	return generateTree(&req.Config, root)
+}
+
+func (s *mcpServer) bundle(ctx context.Context, args json.RawMessage) (string, error) {
+	req, root, err := s.decodeBundleRequest(args)
+	if err != nil {
+		return "", err
+	}
+	p, err := newPipeline(&req.Config, root)
+	if err != nil {
+		return "", err
+	}
+	files, err := p.concatenator.Collect(root)
+	if err != nil {
+		return "", err
+	}
+
+	var preamble, out bytes.Buffer
+	if err := p.writePreamble(&preamble); err != nil {
+		return "", err
+	}
+	sw := &sectionWriter{w: &out, t: newRequestTransformer(req.Transform, req.NoCache)}
+	if err := sw.write(preamble.String(), true); err != nil {
+		return "", err
+	}
+
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+	if _, err := p.writeFiles(ctx, sw, files); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (s *mcpServer) optimize(ctx context.Context, args json.RawMessage) (string, error) {
+	var req struct {
+		Text string `json:"text"`
+		transform.Options
+	}
+	if err := decodeArgs(args, &req); err != nil {
+		return "", err
+	}
+	if t := newRequestTransformer(req.Options, s.cfg.NoCache); t != nil {
+		return t.Process(req.Text), nil
+	}
+	return req.Text, nil
+}
+
+// ListResources implements mcp.ResourceProvider. The resources are the files
+// selected by the CLI flags, or every non-ignored file if no types were given.
+func (s *mcpServer) ListResources(ctx context.Context) ([]mcp.Resource, error) {
+	root := s.roots[0]
+	files, err := s.resourceFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]mcp.Resource, 0, len(files))
+	for _, f := range files {
+		resources = append(resources, mcp.Resource{
+			URI:      (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(root, f))}).String(),
+			Name:     filepath.ToSlash(f),
+			MimeType: "text/plain",
+		})
+	}
+	return resources, nil
+}
+
+func (s *mcpServer) resourceFiles(root string) ([]string, error) {
+	cfg := *s.cfg
+	if len(cfg.Extensions) > 0 || len(cfg.Languages) > 0 || cfg.AutoDetect {
+		p, err := newPipeline(&cfg, root)
+		if err != nil {
+			return nil, err
+		}
+		return p.concatenator.Collect(root)
+	}
+
+	return nonIgnoredFiles(&cfg, root)
+}
+
+// ReadResource implements mcp.ResourceProvider for file:// URIs inside the
+// allowed roots
+func (s *mcpServer) ReadResource(ctx context.Context, uri string) (*mcp.ResourceContents, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return nil, fmt.Errorf("unsupported resource %q", uri)
+	}
+	path, err := s.roots.resolve(filepath.FromSlash(u.Path))
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if core.IsBinary(data) {
+		return nil, fmt.Errorf("%s is a binary file", uri)
+	}
+	return &mcp.ResourceContents{URI: uri, MimeType: "text/plain", Text: string(data)}, nil
+}
+
+// decodeArgs decodes tool arguments strictly so typos surface as errors
+func decodeArgs(args json.RawMessage, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// filterProperties describes the file selection arguments shared by the tools
+func filterProperties() map[string]any {
+	return map[string]any{
+		"root":           stringSchema("Project directory, relative to the server root."),
+		"extensions":     listSchema("File extensions to include, e.g. [\"go\"]."),
+		"languages":      listSchema("Language presets to include: " + strings.Join(core.Languages(), ", ") + "."),
+		"autoDetect":     boolSchema("Detect the languages present and include their presets."),
+		"ignorePatterns": listSchema("Gitignore-style patterns to exclude."),
+		"excludeTests":   boolSchema("Exclude test files."),
+		"onlyTests":      boolSchema("Include only test files."),
+		"testPatterns":   listSchema("Extra patterns identifying test files."),
+		"pairTests":      boolSchema("Include each source file's test counterpart."),
+	}
+}
+
+func transformProperties() map[string]any {
+	return map[string]any{
+		"compact":      boolSchema("Reduce whitespace."),
+		"stripHeaders": boolSchema("Strip copyright/license headers."),
+		"skeleton":     boolSchema("Reduce each file to its outline."),
+	}
+}
+
+func objectSchemaValue(props map[string]any, required ...string) map[string]any {
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func objectSchema(props map[string]any, required ...string) json.RawMessage {
+	data, _ := json.Marshal(objectSchemaValue(props, required...))
+	return data
+}
+
+func stringSchema(desc string) map[string]any {
+	return map[string]any{"type": "string", "description": desc}
+}
+
+func boolSchema(desc string) map[string]any {
+	return map[string]any{"type": "boolean", "description": desc}
+}
+
+func intSchema(desc string) map[string]any {
+	return map[string]any{"type": "integer", "description": desc}
+}
+
+func listSchema(desc string) map[string]any {
+	return map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": desc}
+}
+
+func enumSchema(desc string, values ...string) map[string]any {
+	return map[string]any{"type": "string", "enum": values, "description": desc}
+}
+
+func merge(a, b map[string]any) map[string]any {
+	for k, v := range b {
+		a[k] = v
+	}
+	return a
+}
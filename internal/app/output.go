@@ -0,0 +1,201 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/infra"
+)
+
+// output fans the document out to the destinations (sinks) chosen for a
+// run: any combination of a file, the clipboard and stdout
+type output struct {
+	io.Writer
+	sinks []sink
+}
+
+// sink is a destination of the output
+type sink interface {
+	io.Writer
+	// finish completes the write and reports it. what describes the
+	// payload, e.g. "5 files", and size is its length in bytes.
+	finish(what string, size int64) error
+	// abort discards an incomplete write
+	abort()
+}
+
+// openOutput determines the sinks: those requested with -o, --clipboard and
+// --stdout, or else stdout when it is a pipe and the clipboard otherwise
+func openOutput(cfg *config.Config) (*output, error) {
+	out := &output{}
+	if cfg.Output != "" {
+		s, err := openFileSink(cfg.Output, cfg.Append)
+		if err != nil {
+			return nil, err
+		}
+		out.sinks = append(out.sinks, s)
+	}
+	if cfg.Clipboard {
+		out.sinks = append(out.sinks, &clipboardSink{backend: cfg.ClipboardBackend})
+	}
+	if cfg.PrintToStdout {
+		out.sinks = append(out.sinks, stdoutSink{})
+	}
+
+	if len(out.sinks) == 0 {
+		stat, _ := os.Stdout.Stat()
+		isPipe := (stat.Mode() & os.ModeCharDevice) == 0
+		if isPipe {
+			out.sinks = append(out.sinks, stdoutSink{})
+		} else {
+			out.sinks = append(out.sinks, &clipboardSink{backend: cfg.ClipboardBackend})
+		}
+	}
+
+	writers := make([]io.Writer, len(out.sinks))
+	for i, s := range out.sinks {
+		writers[i] = s
+	}
+	out.Writer = io.MultiWriter(writers...)
+	return out, nil
+}
+
+// Finish completes every sink (copying to the clipboard, committing files)
+// and reports what was written. what describes the payload, e.g. "5 files".
+func (o *output) Finish(what string, size int64) error {
+	for i, s := range o.sinks {
+		if err := s.finish(what, size); err != nil {
+			for _, rest := range o.sinks[i+1:] {
+				rest.abort()
+			}
+			return err
+		}
+	}
+	o.sinks = nil
+	return nil
+}
+
+// Abort discards the output of a failed run. It does nothing after Finish.
+func (o *output) Abort() {
+	for _, s := range o.sinks {
+		s.abort()
+	}
+	o.sinks = nil
+}
+
+// fileSink writes -o: atomically through a temporary file, or appended to
+// the existing file with --append, and gzip-compressed for .gz paths
+type fileSink struct {
+	io.Writer
+	path   string
+	atomic *infra.AtomicFile
+	file   *os.File // with --append
+	offset int64    // size of the file before appending
+	gz     *gzip.Writer
+}
+
+func openFileSink(path string, appendTo bool) (*fileSink, error) {
+	s := &fileSink{path: path}
+	if appendTo {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open output file: %w", err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to open output file: %w", err)
+		}
+		s.file, s.offset, s.Writer = f, info.Size(), f
+	} else {
+		f, err := infra.CreateAtomic(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output file: %w", err)
+		}
+		s.atomic, s.Writer = f, f
+	}
+
+	// Appending to a .gz file adds a gzip member, which readers concatenate
+	if strings.HasSuffix(path, ".gz") {
+		s.gz = gzip.NewWriter(s.Writer)
+		s.Writer = s.gz
+	}
+	return s, nil
+}
+
+func (s *fileSink) finish(what string, size int64) error {
+	if s.gz != nil {
+		if err := s.gz.Close(); err != nil {
+			s.abort()
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+	}
+	verb := "Wrote"
+	if s.file != nil {
+		verb = "Appended"
+		if err := s.file.Close(); err != nil {
+			return fmt.Errorf("failed to close output file: %w", err)
+		}
+	} else if err := s.atomic.Commit(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "✓ %s %s (%d bytes, ~%d tokens) to '%s'.\n", verb, what, size, size/4, s.path)
+	return nil
+}
+
+// abort discards the temporary file, or truncates an appended file back to
+// its previous size
+func (s *fileSink) abort() {
+	if s.file != nil {
+		s.file.Truncate(s.offset)
+		s.file.Close()
+		return
+	}
+	s.atomic.Abort()
+}
+
+// clipboardSink copies the output once it is complete
+type clipboardSink struct {
+	bytes.Buffer
+	backend string
+}
+
+func (s *clipboardSink) finish(what string, size int64) error {
+	clipboard, err := infra.NewClipboard(s.backend)
+	if err != nil {
+		return err
+	}
+	used, err := clipboard.WriteAll(s.String())
+	if err != nil {
+		return fmt.Errorf("failed to copy to clipboard: %w", err)
+	}
+	if f, ok := used.(*infra.FileBackend); ok {
+		if s.backend == infra.BackendFile {
+			fmt.Fprintf(os.Stderr, "✓ Saved %s (%d bytes, ~%d tokens) to '%s'.\n", what, size, size/4, f.Path)
+		} else {
+			fmt.Fprintf(os.Stderr, "⚠ No clipboard available; saved %s (%d bytes, ~%d tokens) to '%s'.\n", what, size, size/4, f.Path)
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "✓ Copied %s (%d bytes, ~%d tokens) to clipboard (%s).\n", what, size, size/4, used.Name())
+	}
+	return nil
+}
+
+func (s *clipboardSink) abort() {}
+
+// stdoutSink streams the output to stdout
+type stdoutSink struct{}
+
+func (stdoutSink) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+
+func (stdoutSink) finish(what string, size int64) error {
+	fmt.Fprintf(os.Stderr, "✓ Output %s (%d bytes, ~%d tokens) to stdout.\n", what, size, size/4)
+	return nil
+}
+
+func (stdoutSink) abort() {}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/core"
+	"github.com/nessaee/concat/internal/symbols"
+)
+
+// RunPaths emits the files and directories named by path specs like Run.
+// A spec may narrow a file to line ranges (server.go:120-260) or to the
+// declaration of a symbol (server.go#HandleLogin). Without file type flags,
+// the specs may name files of any type.
+func RunPaths(cfg *config.Config, args []string) error {
+	if len(cfg.Focus) > 0 {
+		return fmt.Errorf("--focus cannot be used with path arguments")
+	}
+	specs := make([]core.PathSpec, len(args))
+	for i, arg := range args {
+		spec, err := core.ParsePathSpec(arg)
+		if err != nil {
+			return err
+		}
+		spec.Path = path.Clean(filepath.ToSlash(spec.Path))
+		specs[i] = spec
+	}
+
+	runCfg := *cfg
+	if len(cfg.Extensions) == 0 && len(cfg.Languages) == 0 && !cfg.AutoDetect {
+		files, err := nonIgnoredFiles(&runCfg, ".")
+		if err != nil {
+			return err
+		}
+		runCfg.Extensions = specExtensions(specs, files)
+	}
+
+	p, err := newPipeline(&runCfg, ".")
+	if err != nil {
+		return err
+	}
+	defer closeSource(p.fsys)
+
+	// Resolve the specs through the filter (ignore rules, tests, types)
+	files, err := p.concatenator.Collect(p.root)
+	if err != nil {
+		return err
+	}
+	fsys, err := p.concatenator.FS(p.root)
+	if err != nil {
+		return err
+	}
+	selection, lines, err := resolvePaths(fsys, specs, files)
+	if err != nil {
+		return err
+	}
+	p.filter.Select(selection)
+	p.filter.SelectLines(lines)
+	return p.run()
+}
+
+// resolvePaths selects the files the specs match, with the line ranges of
+// the specs that narrow them. A file also named whole is emitted whole.
+func resolvePaths(fsys fs.FS, specs []core.PathSpec, files []string) (map[string]core.Inclusion, map[string][]core.LineRange, error) {
+	selection := make(map[string]core.Inclusion)
+	lines := make(map[string][]core.LineRange)
+	whole := make(map[string]bool)
+	for _, spec := range specs {
+		matched := matchSpec(spec, files)
+		if len(matched) == 0 {
+			return nil, nil, fmt.Errorf("path %q matches no included files", spec.Path)
+		}
+		partial := len(spec.Lines) > 0 || spec.Symbol != ""
+		if partial && (len(matched) > 1 || matched[0] != spec.Path) {
+			return nil, nil, fmt.Errorf("path %q is a directory: line ranges and symbols need a file", spec.Path)
+		}
+
+		ranges := spec.Lines
+		if spec.Symbol != "" {
+			src, err := fs.ReadFile(fsys, spec.Path)
+			if err != nil {
+				return nil, nil, err
+			}
+			start, end, err := symbols.Locate(spec.Path, src, spec.Symbol)
+			if err != nil {
+				return nil, nil, err
+			}
+			ranges = []core.LineRange{{Start: start, End: end}}
+		}
+
+		for _, f := range matched {
+			selection[f] = core.IncludeFull
+			if partial {
+				lines[f] = append(lines[f], ranges...)
+			} else {
+				whole[f] = true
+			}
+		}
+	}
+
+	for f, ranges := range lines {
+		if whole[f] {
+			delete(lines, f)
+			continue
+		}
+		sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	}
+	return selection, lines, nil
+}
+
+// matchSpec returns the files (slash-separated) at or under the spec's path
+func matchSpec(spec core.PathSpec, files []string) []string {
+	var matched []string
+	for _, f := range files {
+		f = filepath.ToSlash(f)
+		if spec.Path == "." || f == spec.Path || strings.HasPrefix(f, spec.Path+"/") {
+			matched = append(matched, f)
+		}
+	}
+	return matched
+}
+
+// specExtensions returns the extensions of the files the specs match, so
+// they pass the filter without type flags
+func specExtensions(specs []core.PathSpec, files []string) []string {
+	seen := make(map[string]bool)
+	var extensions []string
+	for _, spec := range specs {
+		for _, f := range matchSpec(spec, files) {
+			ext := strings.TrimPrefix(filepath.Ext(f), ".")
+			if !seen[ext] {
+				seen[ext] = true
+				extensions = append(extensions, ext)
+			}
+		}
+	}
+	sort.Strings(extensions)
+	return extensions
+}
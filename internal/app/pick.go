@@ -0,0 +1,121 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/core"
+	"github.com/nessaee/concat/internal/picker"
+)
+
+// Pick lets the user choose files from the filtered tree in a terminal UI and
+// emits them like Run. With useSaved, the selection saved in the project
+// config is emitted without showing the picker.
+func Pick(cfg *config.Config, useSaved bool) error {
+	if len(cfg.Focus) > 0 {
+		return fmt.Errorf("--focus cannot be used with pick")
+	}
+	p, err := newPipeline(cfg, ".")
+	if err != nil {
+		return err
+	}
+	defer closeSource(p.fsys)
+
+	project, err := config.LoadProject(p.root)
+	if err != nil {
+		return err
+	}
+
+	var selected []string
+	if useSaved {
+		if len(project.Selection) == 0 {
+			return fmt.Errorf("no saved selection in %s; choose files with concat pick and press s to save them", filepath.Join(config.ProjectDir, config.ProjectFile))
+		}
+		if selected, err = savedSelection(p, project.Selection); err != nil {
+			return err
+		}
+	} else {
+		confirmed, err := pickFiles(p, project)
+		if err != nil {
+			return err
+		}
+		if confirmed == nil {
+			fmt.Fprintln(os.Stderr, "> Selection cancelled.")
+			return nil
+		}
+		selected = confirmed
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("no files selected")
+	}
+
+	selection := make(map[string]core.Inclusion, len(selected))
+	for _, f := range selected {
+		selection[f] = core.IncludeFull
+	}
+	p.filter.Select(selection)
+	return p.run()
+}
+
+// pickFiles shows the picker over the included files, starting from the saved
+// selection. It returns nil if the picker was cancelled.
+func pickFiles(p *pipeline, project *config.Project) ([]string, error) {
+	// The picker offers only the files that would be included
+	treeCfg := *p.cfg
+	treeCfg.TreeMode = core.TreeModeIncluded
+	treeGen, err := newTreeGenerator(&treeCfg, p.filter, p.concatenator, p.fsys, p.root)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := treeGen.Tree(p.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build tree: %w", err)
+	}
+	if tree.Files == 0 {
+		return nil, fmt.Errorf("no files match the selected file types")
+	}
+
+	ui := picker.New(tree, project.Selection)
+	ui.SetSave(func(selected []string) (string, error) {
+		project.Selection = selected
+		return project.Save(p.root)
+	})
+
+	// Draw on the terminal itself so the output can still be piped
+	in, out := os.Stdin, io.Writer(os.Stderr)
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		in, out = tty, tty
+	}
+	confirmed, err := picker.Run(ui, in, out)
+	if err != nil || !confirmed {
+		return nil, err
+	}
+	return ui.Selected(), nil
+}
+
+// savedSelection returns the saved files that are still included, warning
+// about the others
+func savedSelection(p *pipeline, saved []string) ([]string, error) {
+	files, err := p.concatenator.Collect(p.root)
+	if err != nil {
+		return nil, err
+	}
+	included := make(map[string]bool, len(files))
+	for _, f := range files {
+		included[filepath.ToSlash(f)] = true
+	}
+
+	var selected []string
+	for _, f := range saved {
+		if !included[f] {
+			fmt.Fprintf(os.Stderr, "⚠ Saved file %s is no longer included\n", f)
+			continue
+		}
+		selected = append(selected, f)
+	}
+	return selected, nil
+}
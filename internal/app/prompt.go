@@ -0,0 +1,66 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nessaee/concat/internal/prompt"
+)
+
+// promptText returns the text written before and after the files: --prefix,
+// the prompt template (--prompt or --prompt-file) split at {{.Body}}, and
+// --suffix
+func (p *pipeline) promptText(project string) (prefix, suffix string, err error) {
+	cfg := p.cfg
+	var text string
+	switch {
+	case cfg.Prompt != "":
+		if text, err = prompt.Load(projectFS(p.fsys, p.root), cfg.Prompt); err != nil {
+			return "", "", err
+		}
+	case cfg.PromptFile != "":
+		data, err := os.ReadFile(cfg.PromptFile)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read prompt file: %w", err)
+		}
+		text = string(data)
+	}
+
+	var before, after []string
+	if s := strings.TrimSpace(cfg.Prefix); s != "" {
+		before = append(before, s)
+	}
+	if text != "" {
+		files, err := p.concatenator.Collect(p.root)
+		if err != nil {
+			return "", "", err
+		}
+		for i, f := range files {
+			files[i] = filepath.ToSlash(f)
+		}
+		pre, post, err := prompt.Render(text, prompt.Vars{Project: project, Files: files, DiffBase: cfg.DiffBase})
+		if err != nil {
+			return "", "", err
+		}
+		if pre != "" {
+			before = append(before, pre)
+		}
+		if post != "" {
+			after = append(after, post)
+		}
+	}
+	if s := strings.TrimSpace(cfg.Suffix); s != "" {
+		after = append(after, s)
+	}
+	return strings.Join(before, "\n\n"), strings.Join(after, "\n\n"), nil
+}
+
+// writeSuffix writes the prompt text that follows the files, if any
+func (p *pipeline) writeSuffix(w io.Writer) {
+	if p.suffix != "" {
+		fmt.Fprintf(w, "\n%s\n", p.suffix)
+	}
+}
@@ -0,0 +1,301 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/transform"
+)
+
+// Serve runs the HTTP API until interrupted
+func Serve(cfg *config.Config, serveCfg *config.ServeConfig) error {
+	handler, err := NewServer(cfg, serveCfg)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:        serveCfg.Addr,
+		Handler:     handler,
+		ReadTimeout: serveCfg.Timeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(os.Stderr, "> Serving on http://%s (Ctrl+C to stop)...\n", serveCfg.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "> Stopped serving.")
+	return nil
+}
+
+// server exposes bundling, tree generation and optimization over HTTP.
+// Requests may only read projects inside one of the allowed roots.
+type server struct {
+	cfg   *config.Config
+	opts  *config.ServeConfig
+	roots allowlist
+}
+
+// NewServer creates the API handler. cfg supplies the defaults of each
+// request's configuration; serveCfg the allowlist and limits.
+func NewServer(cfg *config.Config, serveCfg *config.ServeConfig) (http.Handler, error) {
+	roots, err := newAllowlist(serveCfg.AllowRoots)
+	if err != nil {
+		return nil, err
+	}
+	s := &server{cfg: cfg, opts: serveCfg, roots: roots}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bundle", s.handleBundle)
+	mux.HandleFunc("/tree", s.handleTree)
+	mux.HandleFunc("/optimize", s.handleOptimize)
+	return mux, nil
+}
+
+// handleBundle serves POST /bundle. The body is a JSON bundleRequest.
+func (s *server) handleBundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The time limit covers the whole request, not just streaming
+	ctx, cancel := context.WithTimeout(r.Context(), s.opts.Timeout)
+	defer cancel()
+
+	req := bundleRequest{Config: requestConfig(s.cfg)}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.opts.MaxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		s.bodyError(w, err)
+		return
+	}
+
+	root, err := s.roots.resolve(req.Root)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	cfg := &req.Config
+	if err := normalizeRequest(cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p, err := newPipeline(cfg, root)
+	if timedOut(w, ctx) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.concatenator.SetContext(ctx)
+	files, err := p.concatenator.Collect(root)
+	if timedOut(w, ctx) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if req.List {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"files": listFiles(root, files)})
+		return
+	}
+
+	var buf bytes.Buffer
+	err = p.writePreamble(&buf)
+	if timedOut(w, ctx) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Stream file by file. Failures after the first write can't change the
+	// status, so they are reported in a trailer along with the file count.
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Trailer", "X-Concat-Files, X-Concat-Error")
+	sw := &sectionWriter{w: w, t: newRequestTransformer(req.Transform, cfg.NoCache)}
+	if err := sw.write(buf.String(), true); err != nil {
+		return
+	}
+
+	count, err := p.writeFiles(ctx, sw, files)
+	if err != nil {
+		w.Header().Set("X-Concat-Error", err.Error())
+	}
+	w.Header().Set("X-Concat-Files", strconv.Itoa(count))
+}
+
+// timedOut reports whether the time limit of a request was exceeded before
+// streaming started, answering it with 503 if so
+func timedOut(w http.ResponseWriter, ctx context.Context) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	http.Error(w, "time limit exceeded", http.StatusServiceUnavailable)
+	return true
+}
+
+// handleTree serves GET /tree. Query parameters mirror the CLI flags
+// (root, pattern, lang, auto, ignore, no-tests, mode, depth, stats, dirs-only,
+// fanout); list parameters may be repeated. Symlinks are not followed.
+func (s *server) handleTree(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := &queryParams{values: r.URL.Query()}
+	root, err := s.roots.resolve(q.string("root", ""))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	cfg := requestConfig(s.cfg)
+	cfg.Extensions = q.list("pattern")
+	cfg.Languages = q.list("lang")
+	cfg.IgnorePatterns = q.list("ignore")
+	cfg.TreeMode = q.string("mode", cfg.TreeMode)
+	cfg.AutoDetect = q.bool("auto")
+	cfg.ExcludeTests = q.bool("no-tests")
+	cfg.TreeStats = q.bool("stats")
+	cfg.TreeDirsOnly = q.bool("dirs-only")
+	cfg.TreeDepth = q.int("depth")
+	cfg.TreeFanout = q.int("fanout")
+	if err := q.err; err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := normalizeRequest(&cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	treeStr, err := generateTree(&cfg, root)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, treeStr)
+}
+
+// handleOptimize serves POST /optimize, transforming the request body like
+// opt. The compact, strip-headers and skeleton query parameters select the
+// transformations.
+func (s *server) handleOptimize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := &queryParams{values: r.URL.Query()}
+	opts := transform.Options{
+		Compact:      q.bool("compact"),
+		StripHeaders: q.bool("strip-headers"),
+		Skeleton:     q.bool("skeleton"),
+	}
+	if err := q.err; err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	input, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.opts.MaxBodyBytes))
+	if err != nil {
+		s.bodyError(w, err)
+		return
+	}
+
+	result := string(input)
+	if t := newRequestTransformer(opts, s.cfg.NoCache); t != nil {
+		result = t.Process(result)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, result)
+}
+
+// bodyError reports a request body that could not be read or decoded
+func (s *server) bodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+}
+
+// queryParams parses typed query parameters, keeping the first error
+type queryParams struct {
+	values url.Values
+	err    error
+}
+
+// list returns every value of a repeatable parameter, also splitting
+// comma-separated values like the CLI's slice flags
+func (q *queryParams) list(name string) []string {
+	var out []string
+	for _, v := range q.values[name] {
+		out = append(out, strings.Split(v, ",")...)
+	}
+	return out
+}
+
+func (q *queryParams) string(name, def string) string {
+	if !q.values.Has(name) {
+		return def
+	}
+	return q.values.Get(name)
+}
+
+// bool treats a present parameter without a value ("?stats") as true
+func (q *queryParams) bool(name string) bool {
+	if !q.values.Has(name) {
+		return false
+	}
+	v := q.values.Get(name)
+	if v == "" {
+		return true
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil && q.err == nil {
+		q.err = fmt.Errorf("invalid value %q for %s", v, name)
+	}
+	return b
+}
+
+func (q *queryParams) int(name string) int {
+	if !q.values.Has(name) {
+		return 0
+	}
+	v := q.values.Get(name)
+	n, err := strconv.Atoi(v)
+	if err != nil && q.err == nil {
+		q.err = fmt.Errorf("invalid value %q for %s", v, name)
+	}
+	return n
+}
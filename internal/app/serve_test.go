@@ -0,0 +1,232 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nessaee/concat/internal/config"
+)
+
+func newTestServer(t *testing.T, maxBody int64) (*httptest.Server, string) {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":     "package main\n\n\n\nfunc main() {}\n",
+		"pkg/util.go": "package pkg\n",
+		"notes.txt":   "not included\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler, err := NewServer(&config.Config{NoCache: true}, &config.ServeConfig{
+		AllowRoots:   []string{root},
+		MaxBodyBytes: maxBody,
+		Timeout:      time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv, root
+}
+
+func request(t *testing.T, method, url, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func TestServer_Bundle(t *testing.T) {
+	srv, _ := newTestServer(t, 1<<20)
+
+	resp, body := request(t, http.MethodPost, srv.URL+"/bundle", `{"extensions": ["go"], "includeTree": true, "transform": {"compact": true}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	for _, want := range []string{"### Directory Structure ###", "### File: main.go ###\npackage main\n\nfunc main() {}", "### File: pkg/util.go ###"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Bundle missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "not included") {
+		t.Errorf("Bundle contains an unselected file:\n%s", body)
+	}
+	if got := resp.Trailer.Get("X-Concat-Files"); got != "2" {
+		t.Errorf("Expected a file count trailer of 2, got %q", got)
+	}
+}
+
+func TestServer_BundleList(t *testing.T) {
+	srv, _ := newTestServer(t, 1<<20)
+
+	resp, body := request(t, http.MethodPost, srv.URL+"/bundle", `{"extensions": [".go"], "list": true}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	var got struct {
+		Files []bundleFile `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, body)
+	}
+	if len(got.Files) != 2 || got.Files[0].Path != "main.go" || got.Files[1].Path != "pkg/util.go" {
+		t.Errorf("Unexpected file list: %+v", got.Files)
+	}
+}
+
+func TestServer_BundleRejections(t *testing.T) {
+	srv, _ := newTestServer(t, 64)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"no file types", http.MethodPost, `{}`, http.StatusBadRequest},
+		{"unknown field", http.MethodPost, `{"extensions": ["go"], "bogus": 1}`, http.StatusBadRequest},
+		{"outside root", http.MethodPost, `{"root": "..", "extensions": ["go"]}`, http.StatusForbidden},
+		{"absolute outside root", http.MethodPost, `{"root": "/", "extensions": ["go"]}`, http.StatusForbidden},
+		{"prompt file", http.MethodPost, `{"extensions": ["go"], "promptFile": "/etc/hostname"}`, http.StatusBadRequest},
+		{"body too large", http.MethodPost, `{"extensions": ["go"], "ignorePatterns": ["` + strings.Repeat("x", 100) + `"]}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := request(t, tt.method, srv.URL+"/bundle", tt.body)
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected %d, got %d: %s", tt.status, resp.StatusCode, body)
+			}
+		})
+	}
+}
+
+func TestServer_BundlePrompt(t *testing.T) {
+	srv, root := newTestServer(t, 1<<20)
+	if err := os.MkdirAll(filepath.Join(root, ".concat", "prompts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".concat", "prompts", "custom.md"), []byte("Project checklist.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Templates come from the requested root, not the server's directory
+	resp, body := request(t, http.MethodPost, srv.URL+"/bundle", `{"extensions": ["go"], "prompt": "custom"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	if !strings.Contains(body, "Project checklist.") {
+		t.Errorf("Expected the root's template in the bundle:\n%s", body)
+	}
+}
+
+func TestServer_BundleSymlinks(t *testing.T) {
+	srv, root := newTestServer(t, 1<<20)
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.go"), []byte("package secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.go"), filepath.Join(root, "link.go")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests cannot turn on following symlinks out of the allowed roots
+	resp, body := request(t, http.MethodPost, srv.URL+"/bundle", `{"extensions": ["go"], "followSymlinks": true}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	if strings.Contains(body, "package secret") {
+		t.Errorf("Bundle contains a file outside the root:\n%s", body)
+	}
+	if !strings.Contains(body, "### File: main.go ###") {
+		t.Errorf("Bundle missing main.go:\n%s", body)
+	}
+}
+
+func TestServer_BundleTimeout(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	handler, err := NewServer(&config.Config{NoCache: true}, &config.ServeConfig{
+		AllowRoots:   []string{root},
+		MaxBodyBytes: 1 << 20,
+		Timeout:      time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	// The limit expires before streaming starts, so the status reports it
+	for _, body := range []string{`{"extensions": ["go"]}`, `{"extensions": ["go"], "list": true}`} {
+		resp, got := request(t, http.MethodPost, srv.URL+"/bundle", body)
+		if resp.StatusCode != http.StatusServiceUnavailable || strings.Contains(got, "main.go") {
+			t.Errorf("%s: expected 503 without files, got %d: %s", body, resp.StatusCode, got)
+		}
+	}
+}
+
+func TestServer_Tree(t *testing.T) {
+	srv, _ := newTestServer(t, 1<<20)
+
+	resp, body := request(t, http.MethodGet, srv.URL+"/tree?root=pkg&pattern=go&stats", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	if !strings.Contains(body, "util.go (12 B, ~3 tokens)") || strings.Contains(body, "main.go") {
+		t.Errorf("Unexpected tree:\n%s", body)
+	}
+
+	resp, body = request(t, http.MethodGet, srv.URL+"/tree?pattern=go&depth=x", "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid depth, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestServer_Optimize(t *testing.T) {
+	srv, _ := newTestServer(t, 1<<20)
+
+	input := "### File: a.go ###\n/* Copyright 2024 */\npackage a\n\n\n\nvar x = 1\n\n---\n\n"
+	resp, body := request(t, http.MethodPost, srv.URL+"/optimize?compact&strip-headers=true", input)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	if strings.Contains(body, "Copyright") || strings.Contains(body, "\n\n\n") {
+		t.Errorf("Transformations not applied:\n%q", body)
+	}
+	if !strings.Contains(body, "package a\n\nvar x = 1") {
+		t.Errorf("Content lost:\n%q", body)
+	}
+}
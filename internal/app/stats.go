@@ -0,0 +1,189 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/core"
+)
+
+// Stats report formats for --stats-format
+const (
+	StatsFormatText = "text"
+	StatsFormatJSON = "json"
+)
+
+// statsExamples is how many skipped paths the text report lists per reason
+const statsExamples = 3
+
+// statsReport summarizes what a run included and skipped
+type statsReport struct {
+	Files        int             `json:"files"`
+	Bytes        int64           `json:"bytes"`
+	Tokens       int64           `json:"tokens"`
+	OutputBytes  int64           `json:"outputBytes"`
+	OutputTokens int64           `json:"outputTokens"`
+	Largest      []core.FileStat `json:"largest"`
+	Extensions   []statsGroup    `json:"extensions"`
+	Directories  []statsGroup    `json:"directories"`
+	Skipped      []skipGroup     `json:"skipped"`
+}
+
+// statsGroup totals the emitted files sharing an extension or top-level directory
+type statsGroup struct {
+	Name   string  `json:"name"`
+	Files  int     `json:"files"`
+	Bytes  int64   `json:"bytes"`
+	Tokens int64   `json:"tokens"`
+	Share  float64 `json:"share"` // percent of the files' tokens
+}
+
+// skipGroup lists the paths skipped for one reason
+type skipGroup struct {
+	Reason string   `json:"reason"`
+	Count  int      `json:"count"`
+	Paths  []string `json:"paths"`
+}
+
+// newStatsReport builds the report from a run's stats. outputBytes covers
+// everything written, including the header and tree.
+func newStatsReport(stats *core.Stats, top int, outputBytes int64) *statsReport {
+	files := stats.Files()
+	r := &statsReport{Files: len(files), OutputBytes: outputBytes, OutputTokens: outputBytes / 4}
+
+	extensions := make(map[string]*statsGroup)
+	directories := make(map[string]*statsGroup)
+	for _, f := range files {
+		r.Bytes += f.Bytes
+		r.Tokens += f.Tokens
+
+		ext := strings.TrimPrefix(path.Ext(f.Path), ".")
+		if ext == "" {
+			ext = path.Base(f.Path)
+		}
+		dir, _, nested := strings.Cut(f.Path, "/")
+		if !nested {
+			dir = "."
+		}
+		addToGroup(extensions, ext, f)
+		addToGroup(directories, dir, f)
+	}
+	r.Largest = files[:min(top, len(files))]
+	r.Extensions = sortGroups(extensions, r.Tokens)
+	r.Directories = sortGroups(directories, r.Tokens)
+
+	byReason := make(map[string]*skipGroup)
+	for _, s := range stats.Skipped() {
+		g := byReason[s.Reason]
+		if g == nil {
+			g = &skipGroup{Reason: s.Reason}
+			byReason[s.Reason] = g
+		}
+		g.Count++
+		g.Paths = append(g.Paths, s.Path)
+	}
+	for _, g := range byReason {
+		r.Skipped = append(r.Skipped, *g)
+	}
+	sort.Slice(r.Skipped, func(i, j int) bool {
+		if r.Skipped[i].Count != r.Skipped[j].Count {
+			return r.Skipped[i].Count > r.Skipped[j].Count
+		}
+		return r.Skipped[i].Reason < r.Skipped[j].Reason
+	})
+	return r
+}
+
+func addToGroup(groups map[string]*statsGroup, key string, f core.FileStat) {
+	g := groups[key]
+	if g == nil {
+		g = &statsGroup{Name: key}
+		groups[key] = g
+	}
+	g.Files++
+	g.Bytes += f.Bytes
+	g.Tokens += f.Tokens
+}
+
+// sortGroups orders groups by tokens, largest first, filling in their share
+func sortGroups(groups map[string]*statsGroup, total int64) []statsGroup {
+	sorted := make([]statsGroup, 0, len(groups))
+	for _, g := range groups {
+		if total > 0 {
+			g.Share = float64(g.Tokens) * 100 / float64(total)
+		}
+		sorted = append(sorted, *g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Tokens != sorted[j].Tokens {
+			return sorted[i].Tokens > sorted[j].Tokens
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// writeStats writes the report for a run to cfg.StatsFile (stderr by default)
+func writeStats(cfg *config.Config, stats *core.Stats, outputBytes int64) error {
+	var w io.Writer = os.Stderr
+	if cfg.StatsFile != "" {
+		f, err := os.Create(cfg.StatsFile)
+		if err != nil {
+			return fmt.Errorf("failed to create stats file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	r := newStatsReport(stats, cfg.StatsTop, outputBytes)
+	if cfg.StatsFormat == StatsFormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	return r.writeText(w)
+}
+
+func (r *statsReport) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Files:\t%d (%d bytes, ~%d tokens)\n", r.Files, r.Bytes, r.Tokens)
+	fmt.Fprintf(tw, "Output:\t%d bytes, ~%d tokens (with header and tree)\n", r.OutputBytes, r.OutputTokens)
+
+	if len(r.Largest) > 0 {
+		fmt.Fprintf(tw, "\nLargest files:\n")
+		for _, f := range r.Largest {
+			fmt.Fprintf(tw, "  ~%d tokens\t%s\n", f.Tokens, f.Path)
+		}
+	}
+	for _, section := range []struct {
+		title  string
+		groups []statsGroup
+	}{{"By extension", r.Extensions}, {"By directory", r.Directories}} {
+		if len(section.groups) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\n%s:\n", section.title)
+		for _, g := range section.groups {
+			fmt.Fprintf(tw, "  %s\t%d %s\t~%d tokens\t%.1f%%\n", g.Name, g.Files, core.Plural(g.Files, "file", "files"), g.Tokens, g.Share)
+		}
+	}
+	if len(r.Skipped) > 0 {
+		fmt.Fprintf(tw, "\nSkipped:\n")
+		for _, g := range r.Skipped {
+			examples := g.Paths[:min(statsExamples, len(g.Paths))]
+			more := ""
+			if len(g.Paths) > len(examples) {
+				more = fmt.Sprintf(", … %d more", len(g.Paths)-len(examples))
+			}
+			fmt.Fprintf(tw, "  %s\t%d\t%s%s\n", g.Reason, g.Count, strings.Join(examples, ", "), more)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,344 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/infra"
+)
+
+const (
+	// watchInterval is how often the tree is polled for changes when
+	// filesystem notifications are unavailable
+	watchInterval = 500 * time.Millisecond
+	// watchDebounce is how long the tree must be quiet before regenerating
+	watchDebounce = 300 * time.Millisecond
+)
+
+// fileState is the scanned state of a file; a change in either field marks it dirty
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// watcher regenerates the output file as the selected tree changes.
+// Rendered file blocks are cached so only changed files are re-read.
+type watcher struct {
+	cfg      *config.Config
+	pipeline *pipeline
+	chunks   map[string][]byte
+	output   string // absolute path of the output file
+
+	// fsw delivers filesystem notifications; nil while polling
+	fsw     *fsnotify.Watcher
+	changed chan struct{}
+}
+
+// Watch writes cfg.Output and keeps it up to date until interrupted.
+// Filesystem notifications (inotify, kqueue, ReadDirectoryChangesW) on the
+// project's directories trigger a rescan; where they are unavailable, e.g. on
+// network filesystems or past the watch limit, the tree is polled instead.
+func Watch(cfg *config.Config) error {
+	if cfg.Output == "" {
+		return fmt.Errorf("--watch requires an output file (-o)")
+	}
+	switch {
+	case cfg.Append:
+		return fmt.Errorf("--watch cannot be used with --append")
+	case cfg.Clipboard || cfg.PrintToStdout:
+		return fmt.Errorf("--watch only writes the -o file; --clipboard and --stdout cannot be used with it")
+	case strings.HasSuffix(cfg.Output, ".gz"):
+		return fmt.Errorf("--watch cannot write compressed (.gz) output")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	output, err := filepath.Abs(cfg.Output)
+	if err != nil {
+		return err
+	}
+	w := &watcher{cfg: cfg, chunks: make(map[string][]byte), output: output, changed: make(chan struct{}, 1)}
+	if err := w.rebuild(); err != nil {
+		return err
+	}
+
+	state, err := w.snapshot()
+	if err != nil {
+		return err
+	}
+	if err := w.regenerate(); err != nil {
+		return err
+	}
+	w.notify(ctx)
+	fmt.Fprintf(os.Stderr, "> Watching for changes (Ctrl+C to stop)...\n")
+
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(os.Stderr, "> Stopped watching.")
+			return nil
+		case <-w.changed:
+		}
+
+		// Watch new directories before scanning, so files created in them
+		// are either seen now or notified later
+		w.watchDirs(ctx)
+
+		next, err := w.snapshot()
+		if err != nil {
+			return err
+		}
+		changed := diffStates(state, next)
+		if len(changed) == 0 {
+			continue
+		}
+
+		// Debounce: wait until a rescan sees no further changes
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(watchDebounce):
+			}
+			again, err := w.snapshot()
+			if err != nil {
+				return err
+			}
+			more := diffStates(next, again)
+			if len(more) == 0 {
+				break
+			}
+			changed = append(changed, more...)
+			next = again
+		}
+		state = next
+
+		rebuild := false
+		for _, path := range changed {
+			if filepath.Base(path) == ".gitignore" {
+				rebuild = true
+			}
+			delete(w.chunks, path)
+		}
+		if rebuild {
+			fmt.Fprintln(os.Stderr, "> .gitignore changed, rebuilding filter...")
+			if err := w.rebuild(); err != nil {
+				return err
+			}
+			if state, err = w.snapshot(); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "> %d changed, regenerating...\n", len(changed))
+		if err := w.regenerate(); err != nil {
+			// Keep watching; the next change may fix it
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+}
+
+// notify starts delivering possible changes to w.changed: filesystem
+// notifications if the project's directories can be watched, else a poll
+// every watchInterval
+func (w *watcher) notify(ctx context.Context) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠ Filesystem notifications unavailable (%v); polling for changes\n", err)
+		go w.poll(ctx)
+		return
+	}
+	w.fsw = fsw
+	w.watchDirs(ctx)
+
+	go func() {
+		defer fsw.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-fsw.Events:
+				if !ok {
+					return
+				}
+				if !w.isOutput(event.Name) {
+					w.signal()
+				}
+			case err, ok := <-fsw.Errors:
+				if !ok {
+					return
+				}
+				// e.g. a queue overflow losing events: rescan to be safe
+				fmt.Fprintf(os.Stderr, "⚠ Watch error: %v\n", err)
+				w.signal()
+			}
+		}
+	}()
+}
+
+// watchDirs adds the project's directories that are not ignored to the
+// notifications, including those created since the last call. If one cannot
+// be watched, it falls back to polling.
+func (w *watcher) watchDirs(ctx context.Context) {
+	if w.fsw == nil {
+		return
+	}
+	err := filepath.WalkDir(".", func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != "." && w.pipeline.filter.IsIgnored(path, true) {
+			return filepath.SkipDir
+		}
+		return w.fsw.Add(path)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠ Filesystem notifications unavailable (%v); polling for changes\n", err)
+		w.fsw.Close()
+		w.fsw = nil
+		go w.poll(ctx)
+	}
+}
+
+// poll signals a possible change every watchInterval
+func (w *watcher) poll(ctx context.Context) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.signal()
+		}
+	}
+}
+
+// signal wakes the watch loop without blocking; pending wakeups coalesce
+func (w *watcher) signal() {
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+// isOutput reports whether path is the output file or one of the temporary
+// files it is written through
+func (w *watcher) isOutput(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	return abs == w.output || infra.IsAtomicTemp(abs, w.output)
+}
+
+// rebuild recreates the filter and components, e.g. after .gitignore changed
+func (w *watcher) rebuild() error {
+	p, err := newPipeline(w.cfg, ".")
+	if err != nil {
+		return err
+	}
+	w.pipeline = p
+	return nil
+}
+
+// snapshot collects the selected files (consulting the filter, so new files
+// are picked up) plus any .gitignore, with their current state
+func (w *watcher) snapshot() (map[string]fileState, error) {
+	files, err := w.collect()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, ".gitignore")
+
+	state := make(map[string]fileState, len(files))
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			continue // deleted between walk and stat
+		}
+		state[f] = fileState{size: info.Size(), modTime: info.ModTime()}
+	}
+	return state, nil
+}
+
+// collect lists the selected files; the pipeline excludes the output file
+func (w *watcher) collect() ([]string, error) {
+	return w.pipeline.concatenator.Collect(".")
+}
+
+// regenerate renders the output, re-reading only files without a cached block,
+// and replaces the output file atomically
+func (w *watcher) regenerate() error {
+	var buf bytes.Buffer
+	if err := w.pipeline.writePreamble(&buf); err != nil {
+		return err
+	}
+
+	files, err := w.collect()
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	live := make(map[string]bool, len(files))
+	for _, f := range files {
+		live[f] = true
+		chunk, ok := w.chunks[f]
+		if !ok {
+			var fb bytes.Buffer
+			included, err := w.pipeline.concatenator.RenderFile(&fb, ".", f)
+			if err != nil {
+				return err
+			}
+			if included {
+				chunk = fb.Bytes()
+			}
+			w.chunks[f] = chunk
+		}
+		if chunk != nil {
+			buf.Write(chunk)
+			count++
+		}
+	}
+
+	w.pipeline.writeSuffix(&buf)
+
+	// Forget files that are no longer selected
+	for f := range w.chunks {
+		if !live[f] {
+			delete(w.chunks, f)
+		}
+	}
+
+	if err := infra.WriteFileAtomic(w.cfg.Output, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "✓ Wrote %d files (%d bytes, ~%d tokens) to '%s'.\n", count, buf.Len(), buf.Len()/4, w.cfg.Output)
+	return nil
+}
+
+// diffStates returns the paths added, removed or modified between two snapshots
+func diffStates(prev, next map[string]fileState) []string {
+	var changed []string
+	for path, s := range next {
+		if old, ok := prev[path]; !ok || old != s {
+			changed = append(changed, path)
+		}
+	}
+	for path := range prev {
+		if _, ok := next[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,184 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// extensions lists the supported archive suffixes, longest first
+var extensions = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
+// IsArchive reports whether path names a supported archive (.tar, .tar.gz,
+// .tgz or .zip)
+func IsArchive(path string) bool {
+	return extension(path) != ""
+}
+
+// Name returns the archive's base name without its extension, used as the
+// project name (e.g. "release" for release.tar.gz)
+func Name(path string) string {
+	base := filepath.Base(path)
+	return base[:len(base)-len(extension(base))]
+}
+
+func extension(path string) string {
+	lower := strings.ToLower(path)
+	for _, ext := range extensions {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// entry is a regular file read from an archive
+type entry struct {
+	name    string
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// Open reads the archive at path into memory and returns its files as an
+// fs.FS. If every entry sits below one top-level directory (as in most
+// release tarballs), that directory becomes the root. Symlinks and other
+// special entries are skipped.
+func Open(path string) (fs.FS, error) {
+	var entries []entry
+	var err error
+	switch extension(path) {
+	case ".tar.gz", ".tgz":
+		entries, err = readTar(path, true)
+	case ".tar":
+		entries, err = readTar(path, false)
+	case ".zip":
+		entries, err = readZip(path)
+	default:
+		return nil, fmt.Errorf("unsupported archive %s (expected %s)", path, strings.Join(extensions, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", path, err)
+	}
+
+	prefix := commonDir(entries)
+	fsys := newMemFS()
+	for _, e := range entries {
+		e.name = strings.TrimPrefix(e.name, prefix)
+		fsys.add(e)
+	}
+	return fsys, nil
+}
+
+func readTar(path string, gzipped bool) ([]entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var entries []entry
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name, ok := cleanName(hdr.Name)
+		if !ok {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+		case tar.TypeSymlink:
+			fmt.Fprintf(os.Stderr, "⚠ Skipping symlink in archive: %s -> %s\n", name, hdr.Linkname)
+			continue
+		default:
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{name: name, data: data, mode: hdr.FileInfo().Mode().Perm(), modTime: hdr.ModTime})
+	}
+}
+
+func readZip(path string) ([]entry, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var entries []entry
+	for _, zf := range zr.File {
+		name, ok := cleanName(zf.Name)
+		if !ok || !zf.Mode().IsRegular() {
+			if zf.Mode()&fs.ModeSymlink != 0 {
+				fmt.Fprintf(os.Stderr, "⚠ Skipping symlink in archive: %s\n", name)
+			}
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{name: name, data: data, mode: zf.Mode().Perm(), modTime: zf.Modified})
+	}
+	return entries, nil
+}
+
+// cleanName turns an entry name into a valid fs.FS path, rejecting names
+// that escape the archive
+func cleanName(name string) (string, bool) {
+	name = path.Clean(strings.TrimPrefix(strings.ReplaceAll(name, "\\", "/"), "/"))
+	if name == "." || !fs.ValidPath(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// commonDir returns the single top-level directory (with a trailing slash)
+// that contains every entry, or "" if there is none
+func commonDir(entries []entry) string {
+	var prefix string
+	for _, e := range entries {
+		dir, _, ok := strings.Cut(e.name, "/")
+		if !ok {
+			return ""
+		}
+		if prefix == "" {
+			prefix = dir + "/"
+		} else if prefix != dir+"/" {
+			return ""
+		}
+	}
+	return prefix
+}
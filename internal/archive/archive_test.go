@@ -0,0 +1,144 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+var fixture = map[string]string{
+	"release-1.0/main.go":     "package main\n",
+	"release-1.0/pkg/util.go": "package pkg\n",
+	"release-1.0/.gitignore":  "gen/\n",
+}
+
+func writeTar(t *testing.T, path string, gzipped bool, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if gzipped {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "release-1.0/link.go", Linkname: "main.go", Typeflag: tar.TypeSymlink}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	defer zw.Close()
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	paths := map[string]func(string){
+		"release.tar":    func(p string) { writeTar(t, p, false, fixture) },
+		"release.tar.gz": func(p string) { writeTar(t, p, true, fixture) },
+		"release.tgz":    func(p string) { writeTar(t, p, true, fixture) },
+		"release.zip":    func(p string) { writeZip(t, p, fixture) },
+	}
+
+	for name, write := range paths {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			write(path)
+
+			fsys, err := Open(path)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			// The common top-level directory becomes the root; symlinks are skipped
+			if err := fstest.TestFS(fsys, "main.go", "pkg/util.go", ".gitignore"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fs.Stat(fsys, "link.go"); err == nil {
+				t.Error("Expected the symlink to be skipped")
+			}
+			data, err := fs.ReadFile(fsys, "pkg/util.go")
+			if err != nil || string(data) != "package pkg\n" {
+				t.Errorf("Unexpected content %q: %v", data, err)
+			}
+		})
+	}
+}
+
+func TestOpen_Names(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mixed.zip")
+	writeZip(t, path, map[string]string{
+		"a/one.go":        "one",
+		"b/two.go":        "two",
+		"../escape.go":    "escape",
+		"/abs/three.go":   "three",
+		`win\dows\old.go`: "old",
+	})
+
+	fsys, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	// Different top-level directories are kept; escaping names are dropped
+	if err := fstest.TestFS(fsys, "a/one.go", "b/two.go", "abs/three.go", "win/dows/old.go"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(fsys, "escape.go"); err == nil {
+		t.Error("Expected the escaping entry to be dropped")
+	}
+}
+
+func TestName(t *testing.T) {
+	tests := map[string]string{
+		"release.tar.gz":     "release",
+		"dir/project.TGZ":    "project",
+		"src.zip":            "src",
+		"v1.2.3.tar":         "v1.2.3",
+		"not-an-archive.txt": "not-an-archive.txt",
+	}
+	for path, want := range tests {
+		if got := Name(path); got != want {
+			t.Errorf("Name(%q) = %q, want %q", path, got, want)
+		}
+	}
+	if IsArchive("main.go") || !IsArchive("x.tar.gz") {
+		t.Error("IsArchive misclassified a path")
+	}
+}
@@ -0,0 +1,137 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// memFS is a read-only in-memory file system. Directories are implied by
+// the files added to it.
+type memFS struct {
+	files map[string]*entry
+	dirs  map[string]map[string]bool // directory -> child names
+}
+
+func newMemFS() *memFS {
+	return &memFS{
+		files: make(map[string]*entry),
+		dirs:  map[string]map[string]bool{".": {}},
+	}
+}
+
+// add stores a file, creating its parent directories
+func (m *memFS) add(e entry) {
+	m.files[e.name] = &e
+	for name := e.name; name != "."; {
+		dir := path.Dir(name)
+		if m.dirs[dir] == nil {
+			m.dirs[dir] = make(map[string]bool)
+		}
+		m.dirs[dir][path.Base(name)] = true
+		name = dir
+	}
+}
+
+// Open implements fs.FS
+func (m *memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if e, ok := m.files[name]; ok {
+		return &openFile{Reader: bytes.NewReader(e.data), info: e.info()}, nil
+	}
+	if _, ok := m.dirs[name]; ok {
+		entries, _ := m.ReadDir(name)
+		return &openDir{info: dirInfo(name), entries: entries}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir implements fs.ReadDirFS, listing entries sorted by name
+func (m *memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	children, ok := m.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for child := range children {
+		full := path.Join(name, child)
+		if e, ok := m.files[full]; ok {
+			entries = append(entries, fs.FileInfoToDirEntry(e.info()))
+		} else {
+			entries = append(entries, fs.FileInfoToDirEntry(dirInfo(full)))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// ReadFile implements fs.ReadFileFS
+func (m *memFS) ReadFile(name string) ([]byte, error) {
+	if e, ok := m.files[name]; ok {
+		return bytes.Clone(e.data), nil
+	}
+	return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+}
+
+func (e *entry) info() fs.FileInfo {
+	return &fileInfo{name: path.Base(e.name), size: int64(len(e.data)), mode: e.mode, modTime: e.modTime}
+}
+
+func dirInfo(name string) fs.FileInfo {
+	return &fileInfo{name: path.Base(name), mode: fs.ModeDir | 0755}
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() any           { return nil }
+
+type openFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *openFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *openFile) Close() error               { return nil }
+
+type openDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *openDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *openDir) Close() error               { return nil }
+
+func (d *openDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile
+func (d *openDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	rest = rest[:min(n, len(rest))]
+	d.offset += len(rest)
+	return rest, nil
+}
@@ -0,0 +1,279 @@
+package bundle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/core"
+	ignore "github.com/sabhiram/go-gitignore"
+	"gopkg.in/yaml.v3"
+)
+
+// Dir holds the bundle manifests, relative to the project root
+var Dir = filepath.Join(config.ProjectDir, "bundles")
+
+// Ext is the extension of bundle manifests
+const Ext = ".yaml"
+
+// OrderManifest emits files in the order of the entries that match them
+const OrderManifest = "manifest"
+
+// validName restricts bundle names to file names within Dir
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Bundle is a named, reproducible file selection read from a manifest:
+//
+//	description: |
+//	  Login, session and token refresh.
+//	order: manifest
+//	files:
+//	  - internal/auth/
+//	  - path: internal/server/routes.go
+//	    lines: 120-260
+//	  - path: "internal/db/*.go"
+//	    skeleton: true
+type Bundle struct {
+	Name        string
+	Description string
+	// Order is OrderManifest (the default) or one of core.Orders
+	Order string
+	Files []Entry
+}
+
+// Entry selects the files matching a path, directory or glob (gitignore
+// syntax, relative to the project root: "*.go" matches only top-level files,
+// "**/*.go" all of them), with options for how to emit them
+type Entry struct {
+	Pattern  string
+	Skeleton bool
+	Lines    []core.LineRange
+}
+
+// Load reads the manifest of the named bundle in the project at root
+func Load(root, name string) (*Bundle, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid bundle name %q", name)
+	}
+	file := filepath.Join(root, Dir, name+Ext)
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		names, _ := List(root)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("bundle %q not found: no manifests in %s", name, Dir)
+		}
+		return nil, fmt.Errorf("bundle %q not found (expected one of %s)", name, strings.Join(names, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := Parse(name, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle %s: %w", file, err)
+	}
+	return b, nil
+}
+
+// List returns the names of the bundles in the project at root, sorted
+func List(root string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(root, Dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), Ext); ok && !e.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Parse decodes a manifest. Unknown keys are rejected so typos surface. Only
+// the first document of a multi-document file is read.
+func Parse(name string, data []byte) (*Bundle, error) {
+	var m manifest
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	b := &Bundle{Name: name, Description: strings.TrimSpace(m.Description), Order: m.Order}
+	if b.Order == "" {
+		b.Order = OrderManifest
+	}
+	if b.Order != OrderManifest && !slices.Contains(core.Orders, b.Order) {
+		return nil, fmt.Errorf("unknown order %q (expected %s or one of %s)", b.Order, OrderManifest, strings.Join(core.Orders, ", "))
+	}
+	for i, f := range m.Files {
+		entry, err := f.entry()
+		if err != nil {
+			return nil, fmt.Errorf("files[%d]: %w", i, err)
+		}
+		b.Files = append(b.Files, entry)
+	}
+	if len(b.Files) == 0 {
+		return nil, errors.New("no files listed")
+	}
+	return b, nil
+}
+
+// manifest is the document form of a Bundle
+type manifest struct {
+	Description string      `yaml:"description"`
+	Order       string      `yaml:"order"`
+	Files       []fileEntry `yaml:"files"`
+}
+
+// fileEntry is a files item: a pattern, or a mapping with path, skeleton and
+// lines
+type fileEntry struct {
+	Path     string    `yaml:"path"`
+	Skeleton bool      `yaml:"skeleton"`
+	Lines    lineSpecs `yaml:"lines"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (f *fileEntry) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Decode(&f.Path)
+	case yaml.MappingNode:
+		// Decoding through node.Decode does not inherit KnownFields
+		for i := 0; i < len(node.Content); i += 2 {
+			switch key := node.Content[i].Value; key {
+			case "path", "skeleton", "lines":
+			default:
+				return fmt.Errorf("line %d: unknown key %q", node.Content[i].Line, key)
+			}
+		}
+		type plain fileEntry
+		return node.Decode((*plain)(f))
+	default:
+		return fmt.Errorf("line %d: expected a path or a mapping with path", node.Line)
+	}
+}
+
+func (f fileEntry) entry() (Entry, error) {
+	e := Entry{Pattern: cleanPattern(f.Path), Skeleton: f.Skeleton}
+	if e.Pattern == "" {
+		return Entry{}, errors.New("missing path")
+	}
+	for _, spec := range f.Lines {
+		r, err := core.ParseLineRange(spec)
+		if err != nil {
+			return Entry{}, err
+		}
+		e.Lines = append(e.Lines, r)
+	}
+	return e, nil
+}
+
+// lineSpecs are the line ranges of an entry: "120-260", "10-20, 40-60" or a
+// list of ranges
+type lineSpecs []string
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (l *lineSpecs) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*l = strings.Split(node.Value, ",")
+		return nil
+	case yaml.SequenceNode:
+		return node.Decode((*[]string)(l))
+	default:
+		return fmt.Errorf("line %d: lines must be ranges like 120-260", node.Line)
+	}
+}
+
+// Selection is a bundle resolved against the files of a project
+type Selection struct {
+	// Files maps each selected file to how it is emitted
+	Files map[string]core.Inclusion
+	// Lines restricts selected files to line ranges
+	Lines map[string][]core.LineRange
+	// Unmatched lists the patterns of entries that matched no file
+	Unmatched []string
+}
+
+// Resolve matches the entries against files (relative paths, as returned by
+// core.Concatenator.Collect). A file matched by several entries takes the
+// options of the first.
+func (b *Bundle) Resolve(files []string) *Selection {
+	s := &Selection{Files: make(map[string]core.Inclusion), Lines: make(map[string][]core.LineRange)}
+	for _, e := range b.Files {
+		matcher := ignore.CompileIgnoreLines(e.anchored())
+		matched := false
+		for _, f := range files {
+			f = filepath.ToSlash(f)
+			if !matcher.MatchesPath(f) {
+				continue
+			}
+			matched = true
+			if _, seen := s.Files[f]; seen {
+				continue
+			}
+			s.Files[f] = core.IncludeFull
+			if e.Skeleton {
+				s.Files[f] = core.IncludeSkeleton
+			}
+			if len(e.Lines) > 0 {
+				s.Lines[f] = e.Lines
+			}
+		}
+		if !matched {
+			s.Unmatched = append(s.Unmatched, e.Pattern)
+		}
+	}
+	return s
+}
+
+// Patterns returns the entry patterns in manifest order, anchored to the
+// project root (for ranking files with core.OrderPriority)
+func (b *Bundle) Patterns() []string {
+	patterns := make([]string, len(b.Files))
+	for i, e := range b.Files {
+		patterns[i] = e.anchored()
+	}
+	return patterns
+}
+
+// anchored returns the pattern in gitignore syntax, matching from the
+// project root rather than at any depth
+func (e Entry) anchored() string {
+	return "/" + e.Pattern
+}
+
+// cleanPattern normalizes a path pattern, keeping a trailing slash that marks
+// a directory
+func cleanPattern(s string) string {
+	s = strings.TrimSpace(filepath.ToSlash(s))
+	if s == "" {
+		return ""
+	}
+	dir := strings.HasSuffix(s, "/")
+	s = strings.TrimLeft(path.Clean(s), "/")
+	if s == "" || s == "." {
+		return "**"
+	}
+	if dir {
+		s += "/"
+	}
+	return s
+}
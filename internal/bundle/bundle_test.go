@@ -0,0 +1,136 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nessaee/concat/internal/core"
+)
+
+func TestParse(t *testing.T) {
+	manifest := `description: |
+  Login flow.
+order: dependency
+files:
+  - internal/auth/
+  - ./main.go
+  - path: server.go
+    lines: 10-20, 40-
+  - path: "db/**/*.go"
+    skeleton: true
+`
+	b, err := Parse("auth", []byte(manifest))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if b.Description != "Login flow." || b.Order != core.OrderDependency || len(b.Files) != 4 {
+		t.Fatalf("Unexpected bundle: %+v", b)
+	}
+	want := []Entry{
+		{Pattern: "internal/auth/"},
+		{Pattern: "main.go"},
+		{Pattern: "server.go", Lines: []core.LineRange{{Start: 10, End: 20}, {Start: 40}}},
+		{Pattern: "db/**/*.go", Skeleton: true},
+	}
+	if !reflect.DeepEqual(b.Files, want) {
+		t.Errorf("Unexpected entries:\n got %+v\nwant %+v", b.Files, want)
+	}
+
+	// Standard YAML features: anchors, flow mappings, multiple documents
+	b, err = Parse("auth", []byte(`---
+files:
+  - &a {path: a.go, lines: [1-2, 5-]}
+  - *a
+  - {path: b.go, skeleton: yes}
+---
+files: [ignored.go]
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want = []Entry{
+		{Pattern: "a.go", Lines: []core.LineRange{{Start: 1, End: 2}, {Start: 5}}},
+		{Pattern: "a.go", Lines: []core.LineRange{{Start: 1, End: 2}, {Start: 5}}},
+		{Pattern: "b.go", Skeleton: true},
+	}
+	if !reflect.DeepEqual(b.Files, want) || b.Order != OrderManifest {
+		t.Errorf("Unexpected entries:\n got %+v\nwant %+v", b.Files, want)
+	}
+
+	for _, bad := range []string{
+		"",
+		"- a.go\n",
+		"files:\n  - path: a\n    typo: x\n",
+		"files:\n  - [a]\n",
+		"files: []\n",
+		"description: x\n",
+		"files: [a]\norder: random\n",
+		"files: [a]\ntypo: x\n",
+		"files:\n  - path: a\n    lines: 20-10\n",
+		"files:\n  - path: a\n    skeleton: maybe\n",
+		"files:\n  - lines: 1-2\n",
+	} {
+		if _, err := Parse("bad", []byte(bad)); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	b, err := Parse("b", []byte(`files:
+  - path: pkg/util.go
+    lines: 1-2
+  - pkg/
+  - "*.go"
+  - gone/
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []string{"main.go", "pkg/util.go", "pkg/sub/deep.go", "cmd/tool.go"}
+
+	s := b.Resolve(files)
+	wantFiles := map[string]core.Inclusion{"main.go": core.IncludeFull, "pkg/util.go": core.IncludeFull, "pkg/sub/deep.go": core.IncludeFull}
+	if !reflect.DeepEqual(s.Files, wantFiles) {
+		t.Errorf("Expected %v, got %v", wantFiles, s.Files)
+	}
+	if got := s.Lines["pkg/util.go"]; len(got) != 1 || got[0] != (core.LineRange{Start: 1, End: 2}) {
+		t.Errorf("Expected the first entry's lines to win, got %v", s.Lines)
+	}
+	if !reflect.DeepEqual(s.Unmatched, []string{"gone/"}) {
+		t.Errorf("Expected gone/ to be reported, got %v", s.Unmatched)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	if _, err := Load(root, "auth"); err == nil || !strings.Contains(err.Error(), "no manifests") {
+		t.Errorf("Expected a missing bundle error, got %v", err)
+	}
+
+	dir := filepath.Join(root, Dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"billing", "auth"} {
+		if err := os.WriteFile(filepath.Join(dir, name+Ext), []byte("files: [a.go]\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if names, err := List(root); err != nil || !reflect.DeepEqual(names, []string{"auth", "billing"}) {
+		t.Errorf("Unexpected bundles %v: %v", names, err)
+	}
+	if b, err := Load(root, "auth"); err != nil || b.Name != "auth" {
+		t.Errorf("Load failed: %+v, %v", b, err)
+	}
+	if _, err := Load(root, "other"); err == nil || !strings.Contains(err.Error(), "auth, billing") {
+		t.Errorf("Expected the available bundles to be listed, got %v", err)
+	}
+	if _, err := Load(root, "../escape"); err == nil {
+		t.Error("Expected names with path separators to be rejected")
+	}
+}
@@ -0,0 +1,336 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Version is part of every cache key. Bump it whenever the output produced
+// for the same input changes (outliners, formatters, transformations), so
+// entries written by older versions are never reused.
+const Version = "2"
+
+// DefaultMaxBytes bounds the size of the default cache; Open prunes the least
+// recently used files beyond it
+const DefaultMaxBytes = 256 << 20
+
+// PruneInterval is how often Open prunes the default cache
+const PruneInterval = time.Hour
+
+// pruneStamp is the file whose mtime records the last pruning
+const pruneStamp = "pruned"
+
+// Cache stores processed output on disk so repeated runs only re-process
+// changed inputs. Per-file entries map a path and variant (the processing
+// options) to the size, mtime and content hash last seen; outputs live in
+// content-addressed blobs keyed by the content hash and variant.
+type Cache struct {
+	dir string
+}
+
+// Entry records the last processed state of a file for one variant
+type Entry struct {
+	Path    string    `json:"path"`
+	Variant string    `json:"variant"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Hash    string    `json:"hash"`
+	Tokens  int64     `json:"tokens"`
+	// Skipped marks inputs that produced no output (e.g. binary files)
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// Stats summarizes the cache contents
+type Stats struct {
+	Dir     string
+	Entries int
+	Blobs   int
+	Bytes   int64
+	Tokens  int64
+}
+
+// Dir returns the default cache directory ($XDG_CACHE_HOME/concat on Linux)
+func Dir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "concat"), nil
+}
+
+// Open opens (creating if needed) the cache in the default directory,
+// pruning it to DefaultMaxBytes if it was not pruned within PruneInterval
+func Open() (*Cache, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	c, err := OpenDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pruning only reclaims space, so failures are ignored
+	stamp := filepath.Join(dir, pruneStamp)
+	if info, err := os.Stat(stamp); err != nil || time.Since(info.ModTime()) > PruneInterval {
+		if c.Prune(DefaultMaxBytes) == nil {
+			writeFile(stamp, nil)
+		}
+	}
+	return c, nil
+}
+
+// OpenDir opens (creating if needed) the cache in dir
+func OpenDir(dir string) (*Cache, error) {
+	for _, sub := range []string{"entries", "blobs"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// HashBytes returns the hex SHA-256 of data
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hashStrings(parts ...string) string {
+	h := sha256.New()
+	io.WriteString(h, Version)
+	h.Write([]byte{0})
+	for _, p := range parts {
+		io.WriteString(h, p)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) entryPath(path, variant string) string {
+	return filepath.Join(c.dir, "entries", hashStrings(path, variant)+".json")
+}
+
+func (c *Cache) blobPath(hash, variant string) string {
+	key := hashStrings(hash, variant)
+	return filepath.Join(c.dir, "blobs", key[:2], key)
+}
+
+// Lookup returns the output cached for path if its size and mtime are unchanged,
+// without reading the file
+func (c *Cache) Lookup(path, variant string, info os.FileInfo) (*Entry, []byte, bool) {
+	e, err := c.entry(path, variant)
+	if err != nil || e.Size != info.Size() || !e.ModTime.Equal(info.ModTime()) {
+		return nil, nil, false
+	}
+	touch(c.entryPath(path, variant))
+	if e.Skipped {
+		return e, nil, true
+	}
+	data, err := c.Blob(e.Hash, variant)
+	if err != nil {
+		return nil, nil, false
+	}
+	return e, data, true
+}
+
+// Blob returns the output stored for a content hash and variant
+func (c *Cache) Blob(hash, variant string) ([]byte, error) {
+	path := c.blobPath(hash, variant)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		touch(path)
+	}
+	return data, err
+}
+
+// Store records the output for path (whose content hashes to e.Hash).
+// A nil output with e.Skipped records that the input produced nothing.
+func (c *Cache) Store(e *Entry, output []byte) error {
+	if !e.Skipped {
+		blob := c.blobPath(e.Hash, e.Variant)
+		if _, err := os.Stat(blob); errors.Is(err, fs.ErrNotExist) {
+			if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+				return err
+			}
+			if err := writeFile(blob, output); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return writeFile(c.entryPath(e.Path, e.Variant), data)
+}
+
+// Memo returns the output cached under the key formed by parts, computing and
+// storing it with compute on a miss. Used for content-keyed transforms.
+func (c *Cache) Memo(compute func() []byte, parts ...string) []byte {
+	key := hashStrings(parts...)
+	if data, err := c.Blob(key, "memo"); err == nil {
+		return data
+	}
+	data := compute()
+	blob := c.blobPath(key, "memo")
+	if err := os.MkdirAll(filepath.Dir(blob), 0755); err == nil {
+		writeFile(blob, data)
+	}
+	return data
+}
+
+func (c *Cache) entry(path, variant string) (*Entry, error) {
+	data, err := os.ReadFile(c.entryPath(path, variant))
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	if e.Path != path || e.Variant != variant {
+		return nil, fmt.Errorf("cache entry collision")
+	}
+	return &e, nil
+}
+
+// Stats walks the cache and summarizes its contents
+func (c *Cache) Stats() (Stats, error) {
+	stats := Stats{Dir: c.dir}
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(c.dir, path)
+		if filepath.Dir(rel) == "." {
+			// The prune stamp
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stats.Bytes += info.Size()
+
+		switch filepath.Base(filepath.Dir(rel)) {
+		case "entries":
+			stats.Entries++
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			var e Entry
+			if json.Unmarshal(data, &e) == nil {
+				stats.Tokens += e.Tokens
+			}
+		default:
+			stats.Blobs++
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Prune removes the entries of files that no longer exist, then the least
+// recently used entries and outputs until the cache holds at most maxBytes.
+// Lookups and reads refresh the mtime of what they use.
+func (c *Cache) Prune(maxBytes int64) error {
+	type file struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var files []file
+	var total int64
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(c.dir, path)
+		if d.IsDir() || filepath.Dir(rel) == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if filepath.Base(filepath.Dir(rel)) == "entries" {
+			var e Entry
+			if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &e) == nil {
+				if _, err := os.Stat(e.Path); errors.Is(err, fs.ErrNotExist) {
+					return os.Remove(path)
+				}
+			}
+		}
+		files = append(files, file{path, info.Size(), info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil || total <= maxBytes {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].used.Before(files[j].used) })
+	for _, f := range files {
+		if total <= maxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		total -= f.size
+	}
+	return nil
+}
+
+// Clear removes all cached data
+func (c *Cache) Clear() error {
+	for _, sub := range []string{"entries", "blobs"} {
+		if err := os.RemoveAll(filepath.Join(c.dir, sub)); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(c.dir, sub), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touch marks a cache file as recently used, for Prune
+func touch(path string) {
+	now := time.Now()
+	os.Chtimes(path, now, now)
+}
+
+// writeFile writes via a temp file and rename so concurrent runs never read
+// a partial entry
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache_LookupAndStore(t *testing.T) {
+	c, err := OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "main.go")
+	content := []byte("package main\n")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+
+	if _, _, ok := c.Lookup(path, "md", info); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+
+	e := &Entry{Path: path, Variant: "md", Size: info.Size(), ModTime: info.ModTime(), Hash: HashBytes(content), Tokens: 3}
+	if err := c.Store(e, []byte("rendered")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, data, ok := c.Lookup(path, "md", info)
+	if !ok || string(data) != "rendered" {
+		t.Fatalf("expected a hit with the stored output, got %v %q", ok, data)
+	}
+
+	// Other variants are cached separately
+	if _, _, ok := c.Lookup(path, "xml", info); ok {
+		t.Error("expected a miss for a different variant")
+	}
+
+	// A changed mtime invalidates the entry, but the content's output remains
+	later := info.ModTime().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	info, _ = os.Stat(path)
+	if _, _, ok := c.Lookup(path, "md", info); ok {
+		t.Error("expected a miss after the mtime changed")
+	}
+	if data, err := c.Blob(HashBytes(content), "md"); err != nil || string(data) != "rendered" {
+		t.Errorf("expected the output to be found by content hash, got %q, %v", data, err)
+	}
+}
+
+func TestCache_Skipped(t *testing.T) {
+	c, err := OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "image.png")
+	if err := os.WriteFile(path, []byte{0, 1, 2}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+
+	e := &Entry{Path: path, Variant: "md", Size: info.Size(), ModTime: info.ModTime(), Hash: "h", Skipped: true}
+	if err := c.Store(e, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, data, ok := c.Lookup(path, "md", info)
+	if !ok || !got.Skipped || data != nil {
+		t.Errorf("expected a skipped hit, got %v %+v %q", ok, got, data)
+	}
+}
+
+func TestCache_Memo(t *testing.T) {
+	c, err := OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	compute := func() []byte {
+		calls++
+		return []byte("out")
+	}
+	for i := 0; i < 2; i++ {
+		if got := c.Memo(compute, "opt", "section"); string(got) != "out" {
+			t.Errorf("expected %q, got %q", "out", got)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected one computation, got %d", calls)
+	}
+
+	c.Memo(compute, "opt", "other section")
+	if calls != 2 {
+		t.Errorf("expected a new key to compute, got %d calls", calls)
+	}
+}
+
+func TestCache_StatsAndClear(t *testing.T) {
+	dir := t.TempDir()
+	c, err := OpenDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.go", "b.go"} {
+		e := &Entry{Path: name, Variant: "md", Hash: HashBytes([]byte(name)), Tokens: 10}
+		if err := c.Store(e, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Dir != dir || stats.Entries != 2 || stats.Blobs != 2 || stats.Tokens != 20 || stats.Bytes == 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 0 || stats.Blobs != 0 || stats.Bytes != 0 {
+		t.Errorf("expected an empty cache after Clear, got %+v", stats)
+	}
+}
+
+func TestCache_Prune(t *testing.T) {
+	c, err := OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcDir := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+
+	var infos []os.FileInfo
+	for _, name := range []string{"a.go", "b.go", "deleted.go"} {
+		path := filepath.Join(srcDir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := os.Stat(path)
+		infos = append(infos, info)
+		e := &Entry{Path: path, Variant: "md", Size: info.Size(), ModTime: info.ModTime(), Hash: HashBytes([]byte(name))}
+		if err := c.Store(e, []byte(strings.Repeat(name, 100))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Age everything, then use b.go
+	filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			os.Chtimes(path, old, old)
+		}
+		return err
+	})
+	if _, _, ok := c.Lookup(filepath.Join(srcDir, "b.go"), "md", infos[1]); !ok {
+		t.Fatal("expected a hit for b.go")
+	}
+	os.Remove(filepath.Join(srcDir, "deleted.go"))
+
+	// Entries of deleted files go regardless of the size
+	if err := c.Prune(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := c.Stats(); stats.Entries != 2 || stats.Blobs != 3 {
+		t.Errorf("expected the deleted file's entry to be pruned, got %+v", stats)
+	}
+
+	// The least recently used files go first
+	stats, _ := c.Stats()
+	if err := c.Prune(stats.Bytes - 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := c.Lookup(filepath.Join(srcDir, "b.go"), "md", infos[1]); !ok {
+		t.Error("expected the recently used b.go to survive")
+	}
+	if after, _ := c.Stats(); after.Bytes >= stats.Bytes {
+		t.Errorf("expected the cache to shrink below %d bytes, got %+v", stats.Bytes, after)
+	}
+
+	if err := c.Prune(0); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := c.Stats(); stats.Bytes != 0 {
+		t.Errorf("expected an empty cache, got %+v", stats)
+	}
+}
@@ -1,11 +1,60 @@
 package config
 
+import "time"
+
 type Config struct {
-	Extensions     []string
-	IgnorePatterns []string
-	Output         string
-	IncludeTree    bool
-	UseXML         bool
-	PrintToStdout  bool
-	ExcludeTests   bool
+	Extensions       []string
+	IgnorePatterns   []string
+	Output           string
+	IncludeTree      bool
+	UseXML           bool
+	PrintToStdout    bool
+	ExcludeTests     bool
+	Languages        []string
+	AutoDetect       bool
+	TestPatterns     []string
+	OnlyTests        bool
+	PairTests        bool
+	FollowSymlinks   bool
+	TreeStats        bool
+	TreeDepth        int
+	TreeDirsOnly     bool
+	TreeFanout       int
+	TreeMode         string
+	Skeleton         bool
+	Focus            []string
+	Budget           int
+	Order            string
+	Priority         []string
+	RepoMap          bool
+	RepoMapFraction  float64
+	Watch            bool
+	NoCache          bool
+	Archive          string
+	Rev              string
+	MaxFileSize      int64
+	Stats            bool
+	StatsFormat      string
+	StatsFile        string
+	StatsTop         int
+	Prompt           string
+	PromptFile       string
+	Prefix           string
+	Suffix           string
+	DiffBase         string
+	Header           string
+	Deterministic    bool
+	CacheFriendly    bool
+	CacheBreakpoints bool
+	ClipboardBackend string
+	Clipboard        bool
+	Append           bool
+}
+
+// ServeConfig holds the settings of the HTTP API server
+type ServeConfig struct {
+	Addr         string
+	AllowRoots   []string
+	MaxBodyBytes int64
+	Timeout      time.Duration
 }
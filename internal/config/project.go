@@ -0,0 +1,57 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ProjectDir holds the project configuration, relative to the project root
+const ProjectDir = ".concat"
+
+// ProjectFile is the project configuration file within ProjectDir
+const ProjectFile = "config.json"
+
+// Project is the configuration saved in a project's .concat/config.json
+type Project struct {
+	// Selection lists the files chosen with concat pick (relative,
+	// slash-separated paths)
+	Selection []string `json:"selection,omitempty"`
+}
+
+// LoadProject reads the project configuration under root. A missing file
+// yields an empty configuration.
+func LoadProject(root string) (*Project, error) {
+	path := filepath.Join(root, ProjectDir, ProjectFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Project{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var p Project
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid project config %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// Save writes the project configuration under root, creating the directory
+// if needed, and returns the file written
+func (p *Project) Save(root string) (string, error) {
+	dir := filepath.Join(root, ProjectDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, ProjectFile)
+	return path, os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -4,9 +4,9 @@ import (
 	"bytes"
 )
 
-// isBinary checks if the data is likely binary by looking for null bytes
+// IsBinary checks if the data is likely binary by looking for null bytes
 // in the first 8000 bytes (similar to git's heuristic).
-func isBinary(content []byte) bool {
+func IsBinary(content []byte) bool {
 	limit := 8000
 	if len(content) < limit {
 		limit = len(content)
@@ -1,13 +1,18 @@
 package core
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 
+	"github.com/nessaee/concat/internal/cache"
 	"github.com/nessaee/concat/internal/config"
 	"github.com/nessaee/concat/internal/protocol"
+	"github.com/nessaee/concat/internal/skeleton"
 )
 
 // Concatenator handles finding and reading files
@@ -15,6 +20,11 @@ type Concatenator struct {
 	filter    *Filter
 	config    *config.Config
 	formatter protocol.Formatter
+	cache     *cache.Cache
+	fsys      fs.FS
+	stats     *Stats
+	history   map[string]int64
+	ctx       context.Context
 }
 
 // NewConcatenator creates a new Concatenator
@@ -26,6 +36,53 @@ func NewConcatenator(filter *Filter, cfg *config.Config, formatter protocol.Form
 	}
 }
 
+// SetCache enables reuse of outlined files across runs. Files whose size and
+// mtime (or, failing that, content hash) are unchanged are not re-outlined.
+// Files emitted in full are not cached: they are read either way.
+func (c *Concatenator) SetCache(cc *cache.Cache) {
+	c.cache = cc
+}
+
+// SetContext makes Collect stop walking with ctx's error once ctx is done
+func (c *Concatenator) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// SetStats records the emitted and skipped files of Process in stats
+func (c *Concatenator) SetStats(stats *Stats) {
+	c.stats = stats
+}
+
+// SetHistory supplies when each file (slash-separated, relative to the root)
+// last changed, as Unix times, for OrderHistory and cache breakpoints. Files
+// missing from history count as changed most recently.
+func (c *Concatenator) SetHistory(history map[string]int64) {
+	c.history = history
+}
+
+// SetFS reads files from fsys (e.g. an archive) instead of the OS file system.
+// Roots are then slash-separated paths within fsys, usually ".". Symlink
+// handling and the cache only apply to the OS file system.
+func (c *Concatenator) SetFS(fsys fs.FS) {
+	c.fsys = fsys
+}
+
+// FS returns the files under root as an fs.FS
+func (c *Concatenator) FS(root string) (fs.FS, error) {
+	if c.fsys == nil {
+		return os.DirFS(root), nil
+	}
+	return fs.Sub(c.fsys, filepath.ToSlash(root))
+}
+
+// Stat returns the file info of path (root joined with a collected path)
+func (c *Concatenator) Stat(path string) (fs.FileInfo, error) {
+	if c.fsys == nil {
+		return os.Stat(path)
+	}
+	return fs.Stat(c.fsys, filepath.ToSlash(path))
+}
+
 // Process walks the directory and returns the formatted content
 func (c *Concatenator) Process(root string, w io.Writer) (int, int64, error) {
 	var count int
@@ -33,10 +90,54 @@ func (c *Concatenator) Process(root string, w io.Writer) (int, int64, error) {
 	// Wrap the writer
 	cw := &CountingWriter{Writer: w}
 
-	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+	files, err := c.Collect(root)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// With cache breakpoints, one marker follows the last file unchanged
+	// since its last commit
+	marked := !c.config.CacheBreakpoints
+	for _, relPath := range files {
+		if _, stable := c.history[filepath.ToSlash(relPath)]; !marked && !stable {
+			c.formatter.WriteBreakpoint(cw)
+			marked = true
+		}
+		before := cw.Count
+		ok, err := c.emitFile(cw, filepath.Join(root, relPath), relPath)
+		if err != nil {
+			return count, cw.Count, err
+		}
+		if ok {
+			count++
+			c.stats.addFile(relPath, cw.Count-before)
+		}
+	}
+	if !marked {
+		c.formatter.WriteBreakpoint(cw)
+	}
+
+	return count, cw.Count, nil
+}
+
+// Collect walks the directory and returns the paths (relative to root) of the
+// files to process, in output order
+func (c *Concatenator) Collect(root string) ([]string, error) {
+	var files []string
+
+	// Track collected files so paired tests are not listed twice
+	collected := make(map[string]bool)
+
+	var walker *Walker
+	visit := func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if c.ctx != nil {
+			if err := c.ctx.Err(); err != nil {
+				return err
+			}
+		}
 
 		// Handle "."
 		if path == root {
@@ -53,57 +154,273 @@ func (c *Concatenator) Process(root string, w io.Writer) (int, int64, error) {
 			if d.IsDir() {
 				// If directory is ignored, skip it
 				if c.filter.IsIgnored(relPath, true) {
+					c.stats.skip(relPath+"/", SkipIgnored)
 					return filepath.SkipDir
 				}
 				// If directory is not ignored but passed (e.g. just a folder traversal), continue
 				return nil
 			}
-			// File not ignored but extension doesn't match
+			// File ignored, excluded or of another type
+			if reason := c.filter.SkipReason(relPath); reason != "" {
+				c.stats.skip(relPath, reason)
+			}
 			return nil
 		}
 
 		if !d.IsDir() {
-			// Open file instead of ReadFile
-			file, err := os.Open(path)
-			if err != nil {
-				return fmt.Errorf("failed to open %s: %w", path, err)
-			}
-			defer file.Close()
-
-			// Binary Check: Read small buffer first
-			// 8192 bytes (8KB) is a safe bet for detection without reading huge files
-			header := make([]byte, 8192)
-			n, err := file.Read(header)
-			if err != nil && err != io.EOF {
-				return fmt.Errorf("failed to read header of %s: %w", path, err)
-			}
-
-			if isBinary(header[:n]) {
-				fmt.Fprintf(os.Stderr, "⚠ Skipping binary file: %s\n", relPath)
+			if collected[relPath] || c.tooLarge(path, relPath) {
 				return nil
 			}
+			collected[relPath] = true
+			files = append(files, relPath)
 
-			// Reset file pointer to start
-			if _, err := file.Seek(0, 0); err != nil {
-				return fmt.Errorf("failed to seek %s: %w", path, err)
+			// Pull in the conventional test counterpart right after its source
+			if c.config.PairTests {
+				for _, testRel := range c.filter.TestCounterparts(relPath) {
+					// Counterparts obey the same ignores as walked files
+					if collected[testRel] || c.filter.IsIgnored(testRel, false) {
+						continue
+					}
+					testPath := filepath.Join(root, testRel)
+					info, err := c.Stat(testPath)
+					if err != nil || info.IsDir() || c.tooLarge(testPath, testRel) {
+						continue
+					}
+					if walker != nil {
+						if link, err := walker.Resolve(testPath); err != nil || !walker.Readable(link) {
+							continue
+						}
+					}
+					collected[testRel] = true
+					files = append(files, testRel)
+				}
 			}
+		}
 
-			c.formatter.WriteHeader(cw, relPath)
+		return nil
+	}
 
-			// Copy content to writer
-			if _, err := io.Copy(cw, file); err != nil {
-				return fmt.Errorf("failed to copy content of %s: %w", path, err)
-			}
+	var err error
+	if c.fsys != nil {
+		err = fs.WalkDir(c.fsys, filepath.ToSlash(root), func(path string, d fs.DirEntry, err error) error {
+			return visit(filepath.FromSlash(path), d, err)
+		})
+	} else {
+		walker, err = NewWalker(root, c.config.FollowSymlinks)
+		if err != nil {
+			return nil, err
+		}
+		err = walker.Walk(visit)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-			c.formatter.WriteFooter(cw)
+	return c.order(root, files)
+}
 
-			count++
+// tooLarge reports (and records) whether a file exceeds the configured size limit
+func (c *Concatenator) tooLarge(path, relPath string) bool {
+	if c.config.MaxFileSize <= 0 {
+		return false
+	}
+	info, err := c.Stat(path)
+	if err != nil || info.Size() <= c.config.MaxFileSize {
+		return false
+	}
+	c.stats.skip(relPath, SkipTooLarge)
+	return true
+}
+
+// RenderFile writes a single file (relative to root) through the formatter.
+// It returns false if the file was skipped (e.g. binary).
+func (c *Concatenator) RenderFile(w io.Writer, root, relPath string) (bool, error) {
+	return c.emitFile(w, filepath.Join(root, relPath), relPath)
+}
+
+// emitFile writes a single file through the formatter.
+// It returns false if the file was skipped (e.g. binary).
+func (c *Concatenator) emitFile(w io.Writer, path, relPath string) (bool, error) {
+	if c.fsys != nil {
+		content, err := fs.ReadFile(c.fsys, filepath.ToSlash(path))
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", path, err)
 		}
+		return c.render(w, bytes.NewReader(content), path, relPath)
+	}
+	// Cached skips are reported as binary, so partial files bypass the cache
+	if c.cache != nil && c.outlined(relPath) && c.filter.Lines(relPath) == nil {
+		return c.emitCached(w, path, relPath)
+	}
 
-		return nil
-	})
+	// Open file instead of ReadFile
+	file, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	return c.render(w, file, path, relPath)
+}
+
+// emitCached writes a single file from the cache, rendering and storing it on a miss
+func (c *Concatenator) emitCached(w io.Writer, path, relPath string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	variant := c.variant(relPath)
+
+	// Unchanged size and mtime: trust the entry without reading the file
+	if entry, data, ok := c.cache.Lookup(abs, variant, info); ok {
+		return c.writeCached(w, entry, data, path, relPath)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	entry := &cache.Entry{
+		Path:    abs,
+		Variant: variant,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Hash:    cache.HashBytes(content),
+	}
+
+	// Touched but unchanged content reuses the stored output
+	data, err := c.cache.Blob(entry.Hash, variant)
+	if err != nil {
+		var buf bytes.Buffer
+		ok, err := c.render(&buf, bytes.NewReader(content), path, relPath)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			// render already reported the skip
+			entry.Skipped = true
+			c.store(entry, nil, relPath)
+			return false, nil
+		}
+		data = buf.Bytes()
+	}
+
+	c.store(entry, data, relPath)
+	return c.writeCached(w, entry, data, path, relPath)
+}
+
+// store records a rendered file; failures only cost a later cache miss
+func (c *Concatenator) store(entry *cache.Entry, data []byte, relPath string) {
+	entry.Tokens = int64(len(data) / 4)
+	if err := c.cache.Store(entry, data); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠ Could not update cache for %s: %v\n", relPath, err)
+	}
+}
+
+// writeCached writes cached output, reporting cached skips like render does
+func (c *Concatenator) writeCached(w io.Writer, entry *cache.Entry, data []byte, path, relPath string) (bool, error) {
+	if entry.Skipped {
+		fmt.Fprintf(os.Stderr, "⚠ Skipping binary file: %s\n", relPath)
+		c.stats.skip(relPath, SkipBinary)
+		return false, nil
+	}
+	if _, err := w.Write(data); err != nil {
+		return false, fmt.Errorf("failed to write content of %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// variant identifies everything besides the content that shapes an outlined
+// file's rendered output: its displayed path and the formatter
+func (c *Concatenator) variant(relPath string) string {
+	return fmt.Sprintf("%s|%T|skeleton", filepath.ToSlash(relPath), c.formatter)
+}
 
-	return count, cw.Count, err
+// outlined reports whether a file is emitted as its outline
+func (c *Concatenator) outlined(relPath string) bool {
+	return c.config.Skeleton || c.filter.Inclusion(relPath) == IncludeSkeleton
+}
+
+// render writes the content read from file through the formatter.
+// It returns false if the content is binary.
+func (c *Concatenator) render(w io.Writer, file io.ReadSeeker, path, relPath string) (bool, error) {
+	// Binary Check: Read small buffer first
+	// 8192 bytes (8KB) is a safe bet for detection without reading huge files
+	header := make([]byte, 8192)
+	n, err := file.Read(header)
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read header of %s: %w", path, err)
+	}
+
+	if IsBinary(header[:n]) {
+		fmt.Fprintf(os.Stderr, "⚠ Skipping binary file: %s\n", relPath)
+		c.stats.skip(relPath, SkipBinary)
+		return false, nil
+	}
+
+	// Reset file pointer to start
+	if _, err := file.Seek(0, 0); err != nil {
+		return false, fmt.Errorf("failed to seek %s: %w", path, err)
+	}
+
+	if ranges := c.filter.Lines(relPath); len(ranges) > 0 {
+		content, err := io.ReadAll(file)
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return c.renderLines(w, content, path, relPath, ranges)
+	}
+
+	c.formatter.WriteHeader(w, relPath)
+
+	if c.outlined(relPath) {
+		// Outlines need the whole file; fall back to full content if unsupported
+		content, err := io.ReadAll(file)
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		outline, _, err := skeleton.Outline(relPath, content)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ Could not outline %s, including full content: %v\n", relPath, err)
+		}
+		if _, err := w.Write(outline); err != nil {
+			return false, fmt.Errorf("failed to write content of %s: %w", path, err)
+		}
+	} else {
+		// Copy content to writer
+		if _, err := io.Copy(w, file); err != nil {
+			return false, fmt.Errorf("failed to copy content of %s: %w", path, err)
+		}
+	}
+
+	c.formatter.WriteFooter(w)
+
+	return true, nil
+}
+
+// renderLines writes each line range of content as its own partial block.
+// Ranges are emitted verbatim, even in outline mode. It returns false if no
+// range lies within the file.
+func (c *Concatenator) renderLines(w io.Writer, content []byte, path, relPath string, ranges []LineRange) (bool, error) {
+	emitted := false
+	for _, r := range ranges {
+		lines, end := r.slice(content)
+		if lines == nil {
+			fmt.Fprintf(os.Stderr, "⚠ Lines %s of %s are past its end (%d lines)\n", r, relPath, countLines(content))
+			continue
+		}
+		c.formatter.WritePartialHeader(w, relPath, r.Start, end)
+		if _, err := w.Write(lines); err != nil {
+			return false, fmt.Errorf("failed to write content of %s: %w", path, err)
+		}
+		c.formatter.WriteFooter(w)
+		emitted = true
+	}
+	return emitted, nil
 }
 
 // CountingWriter wraps an io.Writer and counts bytes written
@@ -2,11 +2,18 @@ package core
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"github.com/nessaee/concat/internal/cache"
 	"github.com/nessaee/concat/internal/config"
 	"github.com/nessaee/concat/internal/protocol"
 )
@@ -90,8 +97,360 @@ func TestIsBinary(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		if got := isBinary(tt.content); got != tt.expected {
+		if got := IsBinary(tt.content); got != tt.expected {
 			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
 		}
 	}
 }
+
+func TestConcatenator_PairTests(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"a.go":      "package a",
+		"a_test.go": "package a // test",
+		"b.go":      "package a // b",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{Extensions: []string{"go"}, ExcludeTests: true, PairTests: true}
+	filter := NewFilter(cfg.Extensions, nil, cfg.ExcludeTests)
+	concatenator := NewConcatenator(filter, cfg, &protocol.MarkdownFormatter{})
+
+	var buf bytes.Buffer
+	count, _, err := concatenator.Process(tmpDir, &buf)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	output := buf.String()
+
+	if count != 3 {
+		t.Errorf("Expected 3 files (a.go, a_test.go, b.go), got %d", count)
+	}
+	if strings.Count(output, "### File: a_test.go ###") != 1 {
+		t.Error("Expected paired test file exactly once")
+	}
+	if strings.Index(output, "a_test.go") > strings.Index(output, "b.go") {
+		t.Error("Expected paired test to follow its source file")
+	}
+}
+
+func TestConcatenator_PairTestsIgnored(t *testing.T) {
+	tests := []struct {
+		name      string
+		gitignore string
+		patterns  []string
+		want      []string
+	}{
+		{"gitignored test", "a_test.go\n", nil, []string{"a.go", "b.go", "b_test.go"}},
+		{"user pattern", "", []string{"*_test.go"}, []string{"a.go", "b.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			files := map[string]string{
+				".gitignore": tt.gitignore,
+				"a.go":       "package a",
+				"a_test.go":  "package a // test",
+				"b.go":       "package a // b",
+				"b_test.go":  "package a // b test",
+			}
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg := &config.Config{Extensions: []string{"go"}, ExcludeTests: true, PairTests: true, IgnorePatterns: tt.patterns}
+			filter := NewFilterAt(tmpDir, cfg.Extensions, cfg.IgnorePatterns, cfg.ExcludeTests)
+			concatenator := NewConcatenator(filter, cfg, &protocol.MarkdownFormatter{})
+			got, err := concatenator.Collect(tmpDir)
+			if err != nil {
+				t.Fatalf("Collect failed: %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConcatenator_Context(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.go"), []byte("package a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Extensions: []string{"go"}}
+	concatenator := NewConcatenator(NewFilter(cfg.Extensions, nil, false), cfg, &protocol.MarkdownFormatter{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	concatenator.SetContext(ctx)
+	if _, err := concatenator.Collect(tmpDir); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the walk to stop with the context's error, got %v", err)
+	}
+}
+
+func TestConcatenator_Skeleton(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := "package a\n\n// Add adds.\nfunc Add(x, y int) int {\n\treturn x + y\n}\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Extensions: []string{"go"}, Skeleton: true}
+	concatenator := NewConcatenator(NewFilter(cfg.Extensions, nil, false), cfg, &protocol.MarkdownFormatter{})
+
+	var buf bytes.Buffer
+	if _, _, err := concatenator.Process(tmpDir, &buf); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	output := buf.String()
+
+	if !strings.Contains(output, "// Add adds.\nfunc Add(x, y int) int { ... }") {
+		t.Errorf("Expected outlined function, got:\n%s", output)
+	}
+	if strings.Contains(output, "return x + y") {
+		t.Error("Output contains function body")
+	}
+}
+
+func TestConcatenator_Cache(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "a.go")
+	if err := os.WriteFile(path, []byte("package a\n\nfunc A() {\n\tprintln(\"a\")\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "b.go"), []byte("package b\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cache.OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Extensions: []string{"go"}}
+	filter := NewFilter(cfg.Extensions, nil, false)
+
+	// Files emitted in full are not cached
+	concatenator := NewConcatenator(filter, cfg, &protocol.MarkdownFormatter{})
+	concatenator.SetCache(c)
+	if _, _, err := concatenator.Process(tmpDir, io.Discard); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if stats, _ := c.Stats(); stats.Entries != 0 {
+		t.Errorf("Expected no cache entries for full files, got %+v", stats)
+	}
+
+	cfg.Skeleton = true
+	run := func(cached bool) (int, string) {
+		concatenator := NewConcatenator(filter, cfg, &protocol.MarkdownFormatter{})
+		if cached {
+			concatenator.SetCache(c)
+		}
+		var buf bytes.Buffer
+		count, _, err := concatenator.Process(tmpDir, &buf)
+		if err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+		return count, buf.String()
+	}
+
+	wantCount, want := run(false)
+	for i := 0; i < 2; i++ {
+		count, got := run(true)
+		if count != wantCount || got != want {
+			t.Fatalf("run %d: cached output differs:\n%s\nwant:\n%s", i, got, want)
+		}
+	}
+
+	// A changed mtime forces a re-read
+	info, _ := os.Stat(path)
+	if err := os.WriteFile(path, []byte("package z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := info.ModTime().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, got := run(true); !strings.Contains(got, "package z") {
+		t.Errorf("Expected the changed file to be re-read, got:\n%s", got)
+	}
+}
+
+func TestConcatenator_FS(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore":       {Data: []byte("gen/\n")},
+		"main.go":          {Data: []byte("package main\n")},
+		"pkg/util.go":      {Data: []byte("package pkg\n")},
+		"pkg/util_test.go": {Data: []byte("package pkg\n")},
+		"pkg/blob.go":      {Data: []byte("package pkg\x00")},
+		"gen/generated.go": {Data: []byte("package gen\n")},
+		"docs/notes.md":    {Data: []byte("notes\n")},
+	}
+
+	cfg := &config.Config{Extensions: []string{"go"}, ExcludeTests: true, TreeMode: TreeModeBoth}
+	filter := NewFilterFS(fsys, cfg.Extensions, nil, cfg.ExcludeTests)
+	concatenator := NewConcatenator(filter, cfg, &protocol.MarkdownFormatter{})
+	concatenator.SetFS(fsys)
+
+	var buf bytes.Buffer
+	count, _, err := concatenator.Process(".", &buf)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	output := buf.String()
+
+	if count != 2 {
+		t.Errorf("Expected 2 files, got %d:\n%s", count, output)
+	}
+	for _, want := range []string{"### File: main.go ###\npackage main\n", "### File: pkg/util.go ###\npackage pkg\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Output missing %q:\n%s", want, output)
+		}
+	}
+	for _, notWant := range []string{"util_test.go", "blob.go", "generated.go", "notes"} {
+		if strings.Contains(output, notWant) {
+			t.Errorf("Output should not contain %q:\n%s", notWant, output)
+		}
+	}
+
+	treeGen := NewTreeGenerator(filter, cfg)
+	treeGen.SetFS(fsys)
+	tree, err := treeGen.Generate(".")
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	for _, want := range []string{"├── docs\n", "│   └── notes.md\n", "├── main.go *\n", "└── pkg\n", "    ├── blob.go *\n", "    ├── util.go *\n"} {
+		if !strings.Contains(tree, want) {
+			t.Errorf("Tree missing %q:\n%s", want, tree)
+		}
+	}
+	if strings.Contains(tree, "gen") {
+		t.Errorf("Tree should not contain the ignored directory:\n%s", tree)
+	}
+}
+
+func TestConcatenator_Stats(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore":       {Data: []byte("gen/\nskip.go\n")},
+		"main.go":          {Data: []byte("package main\n")},
+		"main_test.go":     {Data: []byte("package main\n")},
+		"skip.go":          {Data: []byte("package main\n")},
+		"big.go":           {Data: []byte(strings.Repeat("x", 100))},
+		"blob.go":          {Data: []byte("package pkg\x00")},
+		"gen/generated.go": {Data: []byte("package gen\n")},
+		"notes.md":         {Data: []byte("notes\n")},
+	}
+
+	cfg := &config.Config{Extensions: []string{"go"}, ExcludeTests: true, MaxFileSize: 50}
+	concatenator := NewConcatenator(NewFilterFS(fsys, cfg.Extensions, nil, cfg.ExcludeTests), cfg, &protocol.MarkdownFormatter{})
+	concatenator.SetFS(fsys)
+	stats := NewStats()
+	concatenator.SetStats(stats)
+
+	var buf bytes.Buffer
+	if _, _, err := concatenator.Process(".", &buf); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	files := stats.Files()
+	if len(files) != 1 || files[0].Path != "main.go" || files[0].Bytes != int64(buf.Len()) {
+		t.Errorf("Unexpected files %+v for output of %d bytes", files, buf.Len())
+	}
+
+	want := []SkippedFile{
+		{"big.go", SkipTooLarge},
+		{"blob.go", SkipBinary},
+		{"gen/", SkipIgnored},
+		{"main_test.go", SkipTest},
+		{"skip.go", SkipIgnored},
+	}
+	got := stats.Skipped()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Skipped[%d]: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestConcatenator_Lines(t *testing.T) {
+	fsys := fstest.MapFS{
+		"server.go": {Data: []byte("package server\n\nfunc A() {}\n\nfunc B() {}\n")},
+		"main.go":   {Data: []byte("package main")},
+	}
+
+	cfg := &config.Config{Extensions: []string{"go"}}
+	filter := NewFilterFS(fsys, cfg.Extensions, nil, false)
+	filter.SelectLines(map[string][]LineRange{
+		"server.go": {{Start: 3, End: 3}, {Start: 5}, {Start: 9, End: 12}},
+		"main.go":   {{Start: 1, End: 4}},
+	})
+	concatenator := NewConcatenator(filter, cfg, &protocol.MarkdownFormatter{})
+	concatenator.SetFS(fsys)
+
+	var buf bytes.Buffer
+	if _, _, err := concatenator.Process(".", &buf); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	output := buf.String()
+
+	for _, want := range []string{
+		"### File: main.go (lines 1-1) ###\npackage main\n",
+		"### File: server.go (lines 3-3) ###\nfunc A() {}\n\n\n---\n",
+		"### File: server.go (lines 5-5) ###\nfunc B() {}\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Output missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "lines 9") || strings.Contains(output, "package server") {
+		t.Errorf("Expected only the ranges within the file:\n%s", output)
+	}
+}
+
+func TestParseLineRange(t *testing.T) {
+	for spec, want := range map[string]LineRange{"120-260": {120, 260}, "7": {7, 7}, "40-": {40, 0}, " 3 - 4 ": {3, 4}} {
+		got, err := ParseLineRange(spec)
+		if err != nil || got != want {
+			t.Errorf("ParseLineRange(%q) = %v, %v; want %v", spec, got, err, want)
+		}
+		if reparsed, _ := ParseLineRange(got.String()); reparsed != got {
+			t.Errorf("%v does not round-trip through %q", got, got.String())
+		}
+	}
+	for _, bad := range []string{"", "0", "a-b", "10-5", "-5"} {
+		if _, err := ParseLineRange(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestParsePathSpec(t *testing.T) {
+	tests := []struct {
+		in   string
+		want PathSpec
+	}{
+		{"server.go", PathSpec{Path: "server.go"}},
+		{"server.go:120-260", PathSpec{Path: "server.go", Lines: []LineRange{{120, 260}}}},
+		{"server.go:10-20,40-", PathSpec{Path: "server.go", Lines: []LineRange{{10, 20}, {40, 0}}}},
+		{"server.go#Server.HandleLogin", PathSpec{Path: "server.go", Symbol: "Server.HandleLogin"}},
+		{"dir/a:b.txt", PathSpec{Path: "dir/a:b.txt"}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePathSpec(tt.in)
+		if err != nil || got.Path != tt.want.Path || got.Symbol != tt.want.Symbol || fmt.Sprint(got.Lines) != fmt.Sprint(tt.want.Lines) {
+			t.Errorf("ParsePathSpec(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"server.go:20-10", "server.go#"} {
+		if _, err := ParsePathSpec(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package core
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID identifies a file independently of the path used to reach it
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// getFileID returns the device/inode pair backing info
+func getFileID(path string, info os.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package core
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// fileID identifies a file independently of the path used to reach it.
+// Windows does not expose inodes through os.FileInfo, so the fully
+// resolved path stands in for one.
+type fileID struct {
+	path string
+}
+
+// getFileID returns the resolved path backing info
+func getFileID(path string, info os.FileInfo) (fileID, bool) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fileID{}, false
+	}
+	abs, err := filepath.Abs(real)
+	if err != nil {
+		return fileID{}, false
+	}
+	return fileID{path: abs}, true
+}
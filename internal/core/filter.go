@@ -1,6 +1,7 @@
 package core
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -8,15 +9,41 @@ import (
 	ignore "github.com/sabhiram/go-gitignore"
 )
 
+// Inclusion describes how a selected file is emitted
+type Inclusion int
+
+const (
+	// IncludeFull emits the file content verbatim
+	IncludeFull Inclusion = iota
+	// IncludeSkeleton emits only the file's outline
+	IncludeSkeleton
+)
+
 // Filter handles file inclusion and exclusion logic
 type Filter struct {
 	extensions   map[string]struct{}
+	filenames    map[string]struct{}
 	matchers     []*ignore.GitIgnore
+	testMatchers []*ignore.GitIgnore
 	excludeTests bool
+	onlyTests    bool
+	selection    map[string]Inclusion
+	lines        map[string][]LineRange
+	excluded     func(path string) bool
 }
 
-// NewFilter creates a new Filter
+// NewFilter creates a new Filter for the current directory
 func NewFilter(extensions []string, userPatterns []string, excludeTests bool) *Filter {
+	return NewFilterAt(".", extensions, userPatterns, excludeTests)
+}
+
+// NewFilterAt creates a new Filter, reading the .gitignore in root
+func NewFilterAt(root string, extensions []string, userPatterns []string, excludeTests bool) *Filter {
+	return NewFilterFS(os.DirFS(root), extensions, userPatterns, excludeTests)
+}
+
+// NewFilterFS creates a new Filter, reading the .gitignore at the root of fsys
+func NewFilterFS(fsys fs.FS, extensions []string, userPatterns []string, excludeTests bool) *Filter {
 	extMap := make(map[string]struct{})
 	for _, ext := range extensions {
 		cleanExt := strings.TrimPrefix(ext, ".")
@@ -93,22 +120,79 @@ func NewFilter(extensions []string, userPatterns []string, excludeTests bool) *F
 	matchers = append(matchers, m1)
 
 	// 2. .gitignore if exists
-	if _, err := os.Stat(".gitignore"); err == nil {
-		m2, err := ignore.CompileIgnoreFile(".gitignore")
-		if err == nil {
-			matchers = append(matchers, m2)
-		}
+	if data, err := fs.ReadFile(fsys, ".gitignore"); err == nil {
+		matchers = append(matchers, ignore.CompileIgnoreLines(strings.Split(string(data), "\n")...))
 	}
 
 	return &Filter{
 		extensions:   extMap,
+		filenames:    make(map[string]struct{}),
 		matchers:     matchers,
 		excludeTests: excludeTests,
 	}
 }
 
+// AddLanguage merges a language preset into the filter: its extensions and
+// special filenames are included, its test conventions feed IsTestFile and
+// its tool caches are ignored.
+func (f *Filter) AddLanguage(lang *Language) {
+	for _, ext := range lang.Extensions {
+		f.extensions[ext] = struct{}{}
+	}
+	for _, name := range lang.Filenames {
+		f.filenames[name] = struct{}{}
+	}
+	if len(lang.TestPatterns) > 0 {
+		f.testMatchers = append(f.testMatchers, ignore.CompileIgnoreLines(lang.TestPatterns...))
+	}
+	if len(lang.Ignores) > 0 {
+		f.matchers = append(f.matchers, ignore.CompileIgnoreLines(lang.Ignores...))
+	}
+}
+
+// AddTestPatterns registers user-defined gitignore-style test patterns
+// (e.g. "__tests__/", "spec/", "*_it.go") consulted by IsTestFile
+func (f *Filter) AddTestPatterns(patterns []string) {
+	if len(patterns) > 0 {
+		f.testMatchers = append(f.testMatchers, ignore.CompileIgnoreLines(patterns...))
+	}
+}
+
+// SetOnlyTests inverts test handling so that only test files are processed
+func (f *Filter) SetOnlyTests(only bool) {
+	f.onlyTests = only
+}
+
+// Select restricts processing to the given files (relative, slash-separated
+// paths), each emitted according to its Inclusion. A nil selection lifts the
+// restriction.
+func (f *Filter) Select(selection map[string]Inclusion) {
+	f.selection = selection
+}
+
+// Inclusion returns how path should be emitted
+func (f *Filter) Inclusion(path string) Inclusion {
+	return f.selection[filepath.ToSlash(path)]
+}
+
+// SelectLines restricts files (relative, slash-separated paths) to line
+// ranges, each emitted as its own partial block. Other files are emitted
+// whole.
+func (f *Filter) SelectLines(lines map[string][]LineRange) {
+	f.lines = lines
+}
+
+// Lines returns the line ranges path is restricted to, or nil for the whole file
+func (f *Filter) Lines(path string) []LineRange {
+	return f.lines[filepath.ToSlash(path)]
+}
+
 // HasValidExtension checks if the filename has a valid extension
+// or is one of the special filenames requested by a language preset
 func (f *Filter) HasValidExtension(filename string) bool {
+	if _, ok := f.filenames[filepath.Base(filename)]; ok {
+		return true
+	}
 	ext := filepath.Ext(filename)
 	ext = strings.TrimPrefix(ext, ".")
 	_, ok := f.extensions[ext]
@@ -116,14 +200,25 @@ func (f *Filter) HasValidExtension(filename string) bool {
 }
 
 // IsTestFile checks if the file is a test file based on common conventions
+// and the test patterns of any language presets
 func (f *Filter) IsTestFile(path string) bool {
 	base := strings.ToLower(filepath.Base(path))
-	return strings.HasSuffix(base, "_test.go") || // Go
+	if strings.HasSuffix(base, "_test.go") || // Go
 		strings.HasSuffix(base, ".test.js") || // JS/TS
 		strings.HasSuffix(base, ".spec.js") || // JS/TS
 		strings.HasSuffix(base, ".test.ts") || // JS/TS
 		strings.HasSuffix(base, ".spec.ts") || // JS/TS
-		strings.HasPrefix(base, "test_") // Python
+		strings.HasPrefix(base, "test_") { // Python
+		return true
+	}
+
+	slashed := filepath.ToSlash(path)
+	for _, m := range f.testMatchers {
+		if m.MatchesPath(slashed) {
+			return true
+		}
+	}
+	return false
 }
 
 // ShouldProcess returns true if the file should be processed (included)
@@ -137,9 +232,21 @@ func (f *Filter) ShouldProcess(path string, isDir bool) bool {
 		return true
 	}
 
+	if f.selection != nil {
+		if _, ok := f.selection[filepath.ToSlash(path)]; !ok {
+			return false
+		}
+	}
+
 	// NEW: Test Check
-	if f.excludeTests && f.IsTestFile(path) {
-		return false
+	if f.excludeTests || f.onlyTests {
+		isTest := f.IsTestFile(path)
+		if f.excludeTests && isTest {
+			return false
+		}
+		if f.onlyTests && !isTest {
+			return false
+		}
 	}
 
 	// 2. Check extension inclusion
@@ -150,8 +257,40 @@ func (f *Filter) ShouldProcess(path string, isDir bool) bool {
 	return false
 }
 
-// IsIgnored returns true if the path matches any ignore pattern
+// SkipReason explains why ShouldProcess rejects a file of a selected type
+// (one of the Skip constants). It returns "" for files of other types.
+func (f *Filter) SkipReason(path string) string {
+	if !f.HasValidExtension(path) {
+		return ""
+	}
+	if f.IsIgnored(path, false) {
+		return SkipIgnored
+	}
+	if f.selection != nil {
+		if _, ok := f.selection[filepath.ToSlash(path)]; !ok {
+			return SkipNotSelected
+		}
+	}
+	if f.excludeTests && f.IsTestFile(path) {
+		return SkipTest
+	}
+	if f.onlyTests && !f.IsTestFile(path) {
+		return SkipNotTest
+	}
+	return SkipNotSelected
+}
+
+// Exclude drops the files for which excluded returns true, given their path
+// relative to the root (e.g. the output file of the run)
+func (f *Filter) Exclude(excluded func(path string) bool) {
+	f.excluded = excluded
+}
+
+// IsIgnored returns true if the path matches any ignore pattern or is excluded
 func (f *Filter) IsIgnored(path string, isDir bool) bool {
+	if !isDir && f.excluded != nil && f.excluded(path) {
+		return true
+	}
 	for _, m := range f.matchers {
 		if m.MatchesPath(path) {
 			return true
@@ -32,3 +32,61 @@ func TestFilter_IsTestFile(t *testing.T) {
 		}
 	}
 }
+
+func TestFilter_TestPatternsAndOnlyTests(t *testing.T) {
+	filter := NewFilter([]string{"go", "js"}, nil, false)
+	filter.AddTestPatterns([]string{"__tests__/", "testdata/"})
+	filter.SetOnlyTests(true)
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"main.go", false},
+		{"main_test.go", true},
+		{"web/__tests__/app.js", true},
+		{"web/app.js", false},
+		{"pkg/testdata/fixture.go", true},
+	}
+
+	for _, tt := range tests {
+		if got := filter.ShouldProcess(tt.path, false); got != tt.expected {
+			t.Errorf("ShouldProcess(%q) = %v; want %v", tt.path, got, tt.expected)
+		}
+		if !tt.expected {
+			if got := filter.SkipReason(tt.path); got != SkipNotTest {
+				t.Errorf("SkipReason(%q) = %q; want %q", tt.path, got, SkipNotTest)
+			}
+		}
+	}
+}
+
+func TestFilter_TestCounterparts(t *testing.T) {
+	filter := NewFilter(nil, nil, false)
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"pkg/foo.go", "pkg/foo_test.go"},
+		{"web/foo.ts", "web/foo.spec.ts"},
+		{"app/foo.py", "app/test_foo.py"},
+		{"src/main/java/com/x/Foo.java", "src/test/java/com/x/FooTest.java"},
+	}
+
+	for _, tt := range tests {
+		found := false
+		for _, c := range filter.TestCounterparts(tt.path) {
+			if c == tt.expected {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("TestCounterparts(%q) missing %q", tt.path, tt.expected)
+		}
+	}
+
+	if got := filter.TestCounterparts("pkg/foo_test.go"); len(got) != 0 {
+		t.Errorf("expected no counterparts for a test file, got %v", got)
+	}
+}
@@ -0,0 +1,200 @@
+package core
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Language describes the files that make up a project in a given language
+type Language struct {
+	Name    string
+	Aliases []string
+	// Extensions are included as if passed with -p
+	Extensions []string
+	// Filenames are included regardless of extension (manifests, build files)
+	Filenames []string
+	// TestPatterns are gitignore-style patterns identifying test files
+	TestPatterns []string
+	// Ignores are gitignore-style patterns for tool caches and build output.
+	// They apply to the whole tree, so build directories with generic names
+	// are anchored to the root to spare other languages' folders.
+	Ignores []string
+	// Detect lists extensions or filenames that signal the language is present
+	Detect []string
+}
+
+// languages is the built-in registry used by -l and --auto
+var languages = []*Language{
+	{
+		Name:         "go",
+		Aliases:      []string{"golang"},
+		Extensions:   []string{"go"},
+		Filenames:    []string{"go.mod", "go.work"},
+		TestPatterns: []string{"*_test.go", "testdata/"},
+		Detect:       []string{"go", "go.mod"},
+	},
+	{
+		Name:         "typescript",
+		Aliases:      []string{"ts"},
+		Extensions:   []string{"ts", "tsx", "js", "jsx", "mjs", "cjs", "json"},
+		Filenames:    []string{"package.json", "tsconfig.json"},
+		TestPatterns: []string{"*.test.ts", "*.spec.ts", "*.test.tsx", "*.spec.tsx", "*.test.js", "*.spec.js", "__tests__/", "__mocks__/"},
+		Ignores:      []string{".next", ".nuxt", ".turbo", ".parcel-cache", "coverage/", "*.tsbuildinfo"},
+		Detect:       []string{"ts", "tsx", "tsconfig.json"},
+	},
+	{
+		Name:         "javascript",
+		Aliases:      []string{"js", "node"},
+		Extensions:   []string{"js", "jsx", "mjs", "cjs", "json"},
+		Filenames:    []string{"package.json"},
+		TestPatterns: []string{"*.test.js", "*.spec.js", "*.test.jsx", "*.spec.jsx", "__tests__/", "__mocks__/"},
+		Ignores:      []string{".next", ".nuxt", ".parcel-cache", "coverage/"},
+		Detect:       []string{"js", "jsx", "mjs", "cjs", "package.json"},
+	},
+	{
+		Name:         "python",
+		Aliases:      []string{"py"},
+		Extensions:   []string{"py", "pyi"},
+		Filenames:    []string{"pyproject.toml", "setup.py", "setup.cfg", "requirements.txt", "Pipfile"},
+		TestPatterns: []string{"test_*.py", "*_test.py", "conftest.py", "tests/"},
+		Ignores:      []string{".mypy_cache", ".pytest_cache", ".ruff_cache", ".tox", "*.egg-info", "htmlcov/", ".coverage"},
+		Detect:       []string{"py", "pyproject.toml", "setup.py"},
+	},
+	{
+		Name:         "rust",
+		Aliases:      []string{"rs"},
+		Extensions:   []string{"rs"},
+		Filenames:    []string{"Cargo.toml", "build.rs"},
+		TestPatterns: []string{"tests/", "benches/"},
+		Detect:       []string{"rs", "Cargo.toml"},
+	},
+	{
+		Name:         "java",
+		Extensions:   []string{"java"},
+		Filenames:    []string{"pom.xml", "build.gradle", "settings.gradle", "build.gradle.kts", "settings.gradle.kts"},
+		TestPatterns: []string{"*Test.java", "*Tests.java", "*IT.java", "src/test/"},
+		Ignores:      []string{".gradle", "/out/", "/bin/"},
+		Detect:       []string{"java", "pom.xml", "build.gradle", "build.gradle.kts"},
+	},
+	{
+		Name:         "ruby",
+		Aliases:      []string{"rb"},
+		Extensions:   []string{"rb", "rake", "gemspec"},
+		Filenames:    []string{"Gemfile", "Rakefile"},
+		TestPatterns: []string{"*_spec.rb", "*_test.rb", "/spec/", "/test/"},
+		Ignores:      []string{".bundle", "coverage/"},
+		Detect:       []string{"rb", "Gemfile"},
+	},
+}
+
+// Languages returns the names of all built-in languages
+func Languages() []string {
+	names := make([]string, 0, len(languages))
+	for _, l := range languages {
+		names = append(names, l.Name)
+	}
+	return names
+}
+
+// LookupLanguage finds a built-in language by name or alias
+func LookupLanguage(name string) (*Language, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, l := range languages {
+		if l.Name == name {
+			return l, true
+		}
+		for _, a := range l.Aliases {
+			if a == name {
+				return l, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ResolveLanguages maps language names to their presets, failing on unknown names
+func ResolveLanguages(names []string) ([]*Language, error) {
+	var langs []*Language
+	seen := make(map[string]bool)
+	for _, name := range names {
+		l, ok := LookupLanguage(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown language %q (available: %s)", name, strings.Join(Languages(), ", "))
+		}
+		if seen[l.Name] {
+			continue
+		}
+		seen[l.Name] = true
+		langs = append(langs, l)
+	}
+	return langs, nil
+}
+
+// DetectLanguages walks root and returns the languages whose signal files are present.
+// Ignored paths (per filter) are not descended into.
+func DetectLanguages(root string, filter *Filter) ([]*Language, error) {
+	return DetectLanguagesFS(os.DirFS(root), filter)
+}
+
+// DetectLanguagesFS is DetectLanguages for the files of fsys
+func DetectLanguagesFS(fsys fs.FS, filter *Filter) ([]*Language, error) {
+	signals := make(map[string][]*Language)
+	for _, l := range languages {
+		for _, s := range l.Detect {
+			signals[s] = append(signals[s], l)
+		}
+	}
+
+	found := make(map[*Language]bool)
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == "." {
+			return nil
+		}
+
+		relPath := filepath.FromSlash(path)
+
+		if filter.IsIgnored(relPath, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		name := d.Name()
+		for _, l := range signals[name] {
+			found[l] = true
+		}
+		for _, l := range signals[strings.TrimPrefix(filepath.Ext(name), ".")] {
+			found[l] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// TypeScript projects always carry JS too; don't report both
+	if ts, _ := LookupLanguage("typescript"); found[ts] {
+		js, _ := LookupLanguage("javascript")
+		delete(found, js)
+	}
+
+	var langs []*Language
+	for _, l := range languages {
+		if found[l] {
+			langs = append(langs, l)
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].Name < langs[j].Name })
+	return langs, nil
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilter_AddLanguage(t *testing.T) {
+	filter := NewFilter(nil, nil, true)
+	for _, name := range []string{"python", "java", "ruby"} {
+		lang, ok := LookupLanguage(name)
+		if !ok {
+			t.Fatalf("language %q not found", name)
+		}
+		filter.AddLanguage(lang)
+	}
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"app.py", false, true},
+		{"pyproject.toml", false, true},
+		{"other.toml", false, false},
+		{"conftest.py", false, false},
+		{"tests/test_app.py", false, false},
+		{"tests/helpers.py", false, false},
+		{"src/Foo.java", false, true},
+		{"src/FooTest.java", false, false},
+		{"lib/user_spec.rb", false, false},
+		{".mypy_cache", true, false},
+	}
+
+	for _, tt := range tests {
+		if got := filter.ShouldProcess(tt.path, tt.isDir); got != tt.expected {
+			t.Errorf("ShouldProcess(%q) = %v; want %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestFilter_AddLanguageMixed(t *testing.T) {
+	filter := NewFilter(nil, nil, false)
+	for _, name := range []string{"go", "java", "ruby"} {
+		lang, _ := LookupLanguage(name)
+		filter.AddLanguage(lang)
+	}
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		ignored  bool
+		testFile bool
+	}{
+		{"bin", true, true, false},
+		{"out", true, true, false},
+		{"tools/bin", true, false, false},
+		{"tools/bin/run.go", false, false, false},
+		{"web/out/gen.go", false, false, false},
+		{"test/user_test.rb", false, false, true},
+		{"test/helper.rb", false, false, true},
+		{"internal/test/fixtures.go", false, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := filter.IsIgnored(tt.path, tt.isDir); got != tt.ignored {
+			t.Errorf("IsIgnored(%q) = %v; want %v", tt.path, got, tt.ignored)
+		}
+		if tt.isDir {
+			continue
+		}
+		if got := filter.IsTestFile(tt.path); got != tt.testFile {
+			t.Errorf("IsTestFile(%q) = %v; want %v", tt.path, got, tt.testFile)
+		}
+	}
+}
+
+func TestResolveLanguages(t *testing.T) {
+	langs, err := ResolveLanguages([]string{"ts", "typescript", "golang"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(langs) != 2 || langs[0].Name != "typescript" || langs[1].Name != "go" {
+		t.Errorf("unexpected languages: %v", langs)
+	}
+
+	if _, err := ResolveLanguages([]string{"cobol"}); err == nil {
+		t.Error("expected error for unknown language")
+	}
+}
+
+func TestDetectLanguages(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := []string{
+		"go.mod",
+		"cmd/main.go",
+		"web/index.ts",
+		"web/util.js",
+		"node_modules/lib/index.py", // ignored, must not be detected
+	}
+	for _, name := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(""), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	langs, err := DetectLanguages(tmpDir, NewFilter(nil, nil, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, l := range langs {
+		names = append(names, l.Name)
+	}
+	if len(names) != 2 || names[0] != "go" || names[1] != "typescript" {
+		t.Errorf("DetectLanguages() = %v; want [go typescript]", names)
+	}
+}
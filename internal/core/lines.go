@@ -0,0 +1,115 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LineRange is an inclusive range of 1-based line numbers. An End of 0
+// extends to the end of the file.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// ParseLineRange parses "120-260", "120-" (to the end of the file) or "120"
+// (a single line)
+func ParseLineRange(s string) (LineRange, error) {
+	from, to, isRange := strings.Cut(strings.TrimSpace(s), "-")
+	start, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil || start < 1 {
+		return LineRange{}, fmt.Errorf("invalid line range %q (expected e.g. 120-260)", s)
+	}
+	r := LineRange{Start: start, End: start}
+	if isRange {
+		r.End = 0
+		if to = strings.TrimSpace(to); to != "" {
+			if r.End, err = strconv.Atoi(to); err != nil || r.End < start {
+				return LineRange{}, fmt.Errorf("invalid line range %q (expected e.g. 120-260)", s)
+			}
+		}
+	}
+	return r, nil
+}
+
+// PathSpec is a path argument, optionally narrowed to part of a file:
+// "server.go:120-260" (line ranges, comma-separated) or
+// "server.go#HandleLogin" (the declaration of a symbol)
+type PathSpec struct {
+	Path   string
+	Lines  []LineRange
+	Symbol string
+}
+
+// ParsePathSpec parses a path spec. A ":" suffix is only read as line ranges
+// when it consists of digits, so other paths containing ":" are kept as is.
+func ParsePathSpec(s string) (PathSpec, error) {
+	if i := strings.LastIndex(s, "#"); i > 0 {
+		spec := PathSpec{Path: s[:i], Symbol: strings.TrimSpace(s[i+1:])}
+		if spec.Symbol == "" {
+			return PathSpec{}, fmt.Errorf("invalid path %q: missing symbol name after #", s)
+		}
+		return spec, nil
+	}
+	i := strings.LastIndex(s, ":")
+	if i <= 0 || strings.Trim(s[i+1:], "0123456789-, ") != "" || strings.Trim(s[i+1:], "-, ") == "" {
+		return PathSpec{Path: s}, nil
+	}
+	spec := PathSpec{Path: s[:i]}
+	for _, part := range strings.Split(s[i+1:], ",") {
+		r, err := ParseLineRange(part)
+		if err != nil {
+			return PathSpec{}, err
+		}
+		spec.Lines = append(spec.Lines, r)
+	}
+	return spec, nil
+}
+
+// String renders the range as accepted by ParseLineRange
+func (r LineRange) String() string {
+	switch r.End {
+	case 0:
+		return fmt.Sprintf("%d-", r.Start)
+	case r.Start:
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// slice returns the lines of content in the range, clamped to its end, and
+// the last line number included. It returns nil if the range starts past
+// the end of the content.
+func (r LineRange) slice(content []byte) ([]byte, int) {
+	line, start := 1, -1
+	for i := 0; i <= len(content); {
+		if line == r.Start {
+			start = i
+		}
+		next := bytes.IndexByte(content[i:], '\n')
+		if next < 0 || i+next+1 == len(content) {
+			// Last line
+			if start < 0 {
+				return nil, 0
+			}
+			return content[start:], line
+		}
+		i += next + 1
+		if line == r.End {
+			return content[start:i], line
+		}
+		line++
+	}
+	return nil, 0
+}
+
+// countLines returns the number of lines in content
+func countLines(content []byte) int {
+	n := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		n++
+	}
+	return n
+}
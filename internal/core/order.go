@@ -0,0 +1,176 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+
+	"github.com/nessaee/concat/internal/deps"
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// Output orders for --order
+const (
+	// OrderPath is lexical walk order (default)
+	OrderPath = "path"
+	// OrderDependency puts imported files before the files that import them
+	OrderDependency = "dependency"
+	// OrderReverseDependency puts entry points (e.g. main.go) first
+	OrderReverseDependency = "reverse-dependency"
+	// OrderMtime puts the least recently modified files first
+	OrderMtime = "mtime"
+	// OrderSize puts the smallest files first
+	OrderSize = "size"
+	// OrderPriority ranks files by the first --priority pattern they match
+	OrderPriority = "priority"
+	// OrderHistory puts the files changed longest ago (by git history) first
+	// and uncommitted changes last
+	OrderHistory = "history"
+)
+
+// Orders lists the supported --order strategies
+var Orders = []string{OrderPath, OrderDependency, OrderReverseDependency, OrderMtime, OrderSize, OrderPriority, OrderHistory}
+
+// order sorts the collected files according to the configured strategy.
+// Paired test files stay directly after their source.
+func (c *Concatenator) order(root string, files []string) ([]string, error) {
+	strategy := c.config.Order
+	if strategy == "" || strategy == OrderPath {
+		return files, nil
+	}
+
+	// Detach paired tests so they can be re-attached after their source
+	var units []string
+	paired := make(map[string][]string)
+	if c.config.PairTests {
+		present := make(map[string]bool, len(files))
+		for _, f := range files {
+			present[f] = true
+		}
+		attached := make(map[string]bool)
+		for _, f := range files {
+			for _, t := range c.filter.TestCounterparts(f) {
+				if present[t] && !attached[t] {
+					paired[f] = append(paired[f], t)
+					attached[t] = true
+				}
+			}
+		}
+		for _, f := range files {
+			if !attached[f] {
+				units = append(units, f)
+			}
+		}
+	} else {
+		units = append(units, files...)
+	}
+
+	var err error
+	switch strategy {
+	case OrderDependency, OrderReverseDependency:
+		units, err = c.dependencyOrder(root, units)
+		if err != nil {
+			return nil, err
+		}
+		if strategy == OrderReverseDependency {
+			for i, j := 0, len(units)-1; i < j; i, j = i+1, j-1 {
+				units[i], units[j] = units[j], units[i]
+			}
+		}
+	case OrderMtime, OrderSize:
+		keys := make(map[string]int64, len(units))
+		for _, f := range units {
+			info, err := c.Stat(filepath.Join(root, f))
+			if err != nil {
+				return nil, err
+			}
+			if strategy == OrderMtime {
+				keys[f] = info.ModTime().UnixNano()
+			} else {
+				keys[f] = info.Size()
+			}
+		}
+		sort.SliceStable(units, func(i, j int) bool { return keys[units[i]] < keys[units[j]] })
+	case OrderHistory:
+		if c.history == nil {
+			return nil, fmt.Errorf("--order %s needs the git history of the project", OrderHistory)
+		}
+		changed := func(f string) int64 {
+			if t, ok := c.history[filepath.ToSlash(f)]; ok {
+				return t
+			}
+			return math.MaxInt64
+		}
+		sort.SliceStable(units, func(i, j int) bool { return changed(units[i]) < changed(units[j]) })
+	case OrderPriority:
+		rank := priorityRanker(c.config.Priority)
+		sort.SliceStable(units, func(i, j int) bool { return rank(units[i]) < rank(units[j]) })
+	default:
+		return nil, fmt.Errorf("unknown order %q (expected one of %v)", strategy, Orders)
+	}
+
+	ordered := make([]string, 0, len(files))
+	for _, f := range units {
+		ordered = append(ordered, f)
+		ordered = append(ordered, paired[f]...)
+	}
+	return ordered, nil
+}
+
+// dependencyOrder returns files in topological order (imports first).
+// Cycles are broken at the first file reached; ties keep path order.
+func (c *Concatenator) dependencyOrder(root string, files []string) ([]string, error) {
+	fsys, err := c.FS(root)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := deps.BuildFS(fsys, files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build dependency graph: %w", err)
+	}
+
+	// Graph paths are slash-separated; map back to the collected form
+	native := make(map[string]string, len(files))
+	for _, f := range files {
+		native[filepath.ToSlash(f)] = f
+	}
+
+	ordered := make([]string, 0, len(files))
+	visited := make(map[string]bool, len(files))
+	var visit func(f string)
+	visit = func(f string) {
+		if visited[f] {
+			return
+		}
+		visited[f] = true
+		for _, dep := range graph.Edges[f] {
+			if _, ok := native[dep]; ok {
+				visit(dep)
+			}
+		}
+		ordered = append(ordered, native[f])
+	}
+	for _, f := range files {
+		visit(filepath.ToSlash(f))
+	}
+	return ordered, nil
+}
+
+// priorityRanker returns the index of the first pattern matching a path;
+// unmatched paths rank after all patterns
+func priorityRanker(patterns []string) func(string) int {
+	matchers := make([]*ignore.GitIgnore, len(patterns))
+	for i, p := range patterns {
+		matchers[i] = ignore.CompileIgnoreLines(p)
+	}
+	return func(path string) int {
+		path = filepath.ToSlash(path)
+		for i, m := range matchers {
+			if m.MatchesPath(path) {
+				return i
+			}
+		}
+		return len(matchers)
+	}
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/protocol"
+)
+
+func TestConcatenator_Order(t *testing.T) {
+	root := t.TempDir()
+	files := []struct {
+		name    string
+		content string
+	}{
+		// Written oldest to newest
+		{"go.mod", "module example.com/app\n"},
+		{"util/util.go", "package util\n"},
+		{"app/app.go", "package app\n\nimport \"example.com/app/util\"\n\n// padding\n"},
+		{"main.go", "package main\n\nimport \"example.com/app/app\"\n"},
+	}
+	base := time.Now().Add(-time.Hour)
+	for i, f := range files {
+		path := filepath.Join(root, filepath.FromSlash(f.name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		order    string
+		priority []string
+		expected []string
+	}{
+		{OrderPath, nil, []string{"app/app.go", "main.go", "util/util.go"}},
+		{OrderDependency, nil, []string{"util/util.go", "app/app.go", "main.go"}},
+		{OrderReverseDependency, nil, []string{"main.go", "app/app.go", "util/util.go"}},
+		{OrderMtime, nil, []string{"util/util.go", "app/app.go", "main.go"}},
+		{OrderSize, nil, []string{"util/util.go", "main.go", "app/app.go"}},
+		{OrderPriority, []string{"main.go", "util/"}, []string{"main.go", "util/util.go", "app/app.go"}},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{Extensions: []string{"go"}, Order: tt.order, Priority: tt.priority}
+		concatenator := NewConcatenator(NewFilter(cfg.Extensions, nil, false), cfg, &protocol.MarkdownFormatter{})
+		got, err := concatenator.Collect(root)
+		if err != nil {
+			t.Fatalf("%s: Collect failed: %v", tt.order, err)
+		}
+		for i := range got {
+			got[i] = filepath.ToSlash(got[i])
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: got %v; want %v", tt.order, got, tt.expected)
+		}
+	}
+}
+
+func TestConcatenator_HistoryOrder(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"app/app.go", "main.go", "util/util.go"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{Extensions: []string{"go"}, Order: OrderHistory, CacheBreakpoints: true}
+	concatenator := NewConcatenator(NewFilter(cfg.Extensions, nil, false), cfg, &protocol.XMLFormatter{})
+	if _, err := concatenator.Collect(root); err == nil {
+		t.Error("Expected an error without history")
+	}
+
+	// app/app.go has uncommitted changes
+	concatenator.SetHistory(map[string]int64{"main.go": 200, "util/util.go": 100})
+	var buf strings.Builder
+	if _, _, err := concatenator.Process(root, &buf); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "<file") || line == protocol.MarkerBreakpointXML {
+			got = append(got, line)
+		}
+	}
+	want := []string{`<file path="util/util.go">`, `<file path="main.go">`, protocol.MarkerBreakpointXML, `<file path="app/app.go">`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestTreeGenerator_SetOrder(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a/a.go", "b.go", "c/c.go"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{Extensions: []string{"go"}}
+	treeGen := NewTreeGenerator(NewFilter(cfg.Extensions, nil, false), cfg)
+	treeGen.SetOrder([]string{"c/c.go", "b.go", "a/a.go"})
+	tree, err := treeGen.Generate(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, b, a := strings.Index(tree, "c.go"), strings.Index(tree, "b.go"), strings.Index(tree, "a.go")
+	if !(c < b && b < a) {
+		t.Errorf("tree does not follow the file order:\n%s", tree)
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// TestCounterparts returns the conventional test file locations for a source
+// file, relative to the same root as path. Candidates may not exist; callers
+// are expected to check. Test files have no counterparts.
+func (f *Filter) TestCounterparts(path string) []string {
+	if f.IsTestFile(path) {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+
+	join := func(parts ...string) string {
+		return filepath.Join(append([]string{dir}, parts...)...)
+	}
+
+	var candidates []string
+	switch ext {
+	case ".go":
+		candidates = append(candidates, join(stem+"_test.go"))
+	case ".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs":
+		for _, kind := range []string{".test", ".spec"} {
+			candidates = append(candidates,
+				join(stem+kind+ext),
+				join("__tests__", stem+kind+ext),
+			)
+		}
+		candidates = append(candidates, join("__tests__", base))
+	case ".py":
+		candidates = append(candidates,
+			join("test_"+base),
+			join(stem+"_test.py"),
+			join("tests", "test_"+base),
+		)
+	case ".rb":
+		candidates = append(candidates,
+			join(stem+"_spec.rb"),
+			join(stem+"_test.rb"),
+		)
+	case ".java":
+		candidates = append(candidates,
+			join(stem+"Test.java"),
+			join(stem+"Tests.java"),
+		)
+		// Maven/Gradle layout: src/main/java/... -> src/test/java/...
+		slashed := "/" + filepath.ToSlash(dir) + "/"
+		if strings.Contains(slashed, "/src/main/") {
+			testDir := filepath.FromSlash(strings.Trim(strings.Replace(slashed, "/src/main/", "/src/test/", 1), "/"))
+			candidates = append(candidates,
+				filepath.Join(testDir, stem+"Test.java"),
+				filepath.Join(testDir, stem+"Tests.java"),
+			)
+		}
+	}
+	return candidates
+}
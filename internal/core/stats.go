@@ -0,0 +1,99 @@
+package core
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Reasons a candidate file was left out of the output
+const (
+	// SkipIgnored marks paths matched by ignore rules (directories are
+	// recorded once, without their contents)
+	SkipIgnored = "ignored"
+	// SkipTest marks test files excluded by --no-tests
+	SkipTest = "test"
+	// SkipNotTest marks files that are not tests with --only-tests
+	SkipNotTest = "not a test"
+	// SkipNotSelected marks files outside --focus or the requested paths
+	SkipNotSelected = "not selected"
+	// SkipTooLarge marks files over --max-file-size
+	SkipTooLarge = "too large"
+	// SkipBinary marks files detected as binary
+	SkipBinary = "binary"
+)
+
+// Stats records the files a run emitted and the ones it skipped. Files with
+// an unselected extension are not recorded. Paths are slash-separated and
+// recorded once, however often the tree is walked.
+type Stats struct {
+	mu      sync.Mutex
+	files   map[string]FileStat
+	skipped map[string]string
+}
+
+// FileStat is an emitted file and the size of its formatted output
+type FileStat struct {
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	Tokens int64  `json:"tokens"`
+}
+
+// SkippedFile is a file (or ignored directory, with a trailing slash) left out
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// NewStats creates an empty Stats
+func NewStats() *Stats {
+	return &Stats{files: make(map[string]FileStat), skipped: make(map[string]string)}
+}
+
+func (s *Stats) addFile(path string, bytes int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path = filepath.ToSlash(path)
+	s.files[path] = FileStat{Path: path, Bytes: bytes, Tokens: bytes / 4}
+}
+
+func (s *Stats) skip(path, reason string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skipped[filepath.ToSlash(path)] = reason
+}
+
+// Files returns the emitted files, largest first
+func (s *Stats) Files() []FileStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := make([]FileStat, 0, len(s.files))
+	for _, f := range s.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Bytes != files[j].Bytes {
+			return files[i].Bytes > files[j].Bytes
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// Skipped returns the skipped paths, sorted by path
+func (s *Stats) Skipped() []SkippedFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	skipped := make([]SkippedFile, 0, len(s.skipped))
+	for path, reason := range s.skipped {
+		skipped = append(skipped, SkippedFile{Path: path, Reason: reason})
+	}
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].Path < skipped[j].Path })
+	return skipped
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LinkInfo describes a symlink encountered during a walk
+type LinkInfo struct {
+	// Target is the raw link text as returned by os.Readlink
+	Target string
+	// Dangling is true if the target does not exist
+	Dangling bool
+	// Outside is true if the resolved target lies outside the walk root
+	Outside bool
+	// IsDir is true if the resolved target is a directory
+	IsDir bool
+	// Info is the FileInfo of the resolved target (nil if dangling)
+	Info os.FileInfo
+}
+
+// Walker walks a directory tree like filepath.WalkDir, applying a symlink policy:
+// by default, symlinked files are only read if they resolve inside the root and
+// symlinked directories are not descended into. With follow enabled, directory
+// links are descended and targets outside the root are allowed; directories are
+// tracked by inode so link loops terminate.
+type Walker struct {
+	root     string
+	realRoot string
+	follow   bool
+	visited  map[fileID]struct{}
+}
+
+// NewWalker creates a Walker rooted at root
+func NewWalker(root string, follow bool) (*Walker, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	realRoot, err = filepath.Abs(realRoot)
+	if err != nil {
+		return nil, err
+	}
+	return &Walker{
+		root:     root,
+		realRoot: realRoot,
+		follow:   follow,
+		visited:  make(map[fileID]struct{}),
+	}, nil
+}
+
+// Resolve inspects path and, if it is a symlink, reports where it points.
+// It returns nil for regular files and directories.
+func (w *Walker) Resolve(path string) (*LinkInfo, error) {
+	lst, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if lst.Mode()&os.ModeSymlink == 0 {
+		return nil, nil
+	}
+
+	target, err := os.Readlink(path)
+	if err != nil {
+		return nil, err
+	}
+	link := &LinkInfo{Target: target}
+
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// Missing targets and link loops both leave nothing to read
+		link.Dangling = true
+		return link, nil
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		link.Dangling = true
+		return link, nil
+	}
+	link.Info = info
+	link.IsDir = info.IsDir()
+
+	abs, err := filepath.Abs(real)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(w.realRoot, abs)
+	link.Outside = err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	return link, nil
+}
+
+// Readable reports whether the policy allows reading content through link
+func (w *Walker) Readable(link *LinkInfo) bool {
+	if link == nil {
+		return true
+	}
+	if link.Dangling {
+		return false
+	}
+	return w.follow || !link.Outside
+}
+
+// Enter marks the directory at path as visited. It returns false if the
+// same directory (by inode) was already entered through another path.
+func (w *Walker) Enter(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	id, ok := getFileID(path, info)
+	if !ok {
+		return true, nil
+	}
+	if _, seen := w.visited[id]; seen {
+		return false, nil
+	}
+	w.visited[id] = struct{}{}
+	return true, nil
+}
+
+// Walk calls fn for every entry below the root, in lexical order.
+// Symlinks that the policy permits are presented as their target's type;
+// dangling or disallowed links are reported on stderr and skipped.
+func (w *Walker) Walk(fn fs.WalkDirFunc) error {
+	info, err := os.Stat(w.root)
+	if err != nil {
+		return fn(w.root, nil, err)
+	}
+	if _, err := w.Enter(w.root); err != nil {
+		return fn(w.root, nil, err)
+	}
+
+	err = fn(w.root, fs.FileInfoToDirEntry(info), nil)
+	if err != nil {
+		if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+			return nil
+		}
+		return err
+	}
+
+	err = w.walkDir(w.root, fn)
+	if errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+func (w *Walker) walkDir(dir string, fn fs.WalkDirFunc) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fn(dir, nil, err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		entry := e
+
+		if e.Type()&fs.ModeSymlink != 0 {
+			link, err := w.Resolve(path)
+			if err != nil {
+				return fn(path, e, err)
+			}
+			relPath, _ := filepath.Rel(w.root, path)
+			switch {
+			case link.Dangling:
+				fmt.Fprintf(os.Stderr, "⚠ Skipping dangling symlink: %s -> %s\n", relPath, link.Target)
+				continue
+			case link.Outside && !w.follow:
+				fmt.Fprintf(os.Stderr, "⚠ Skipping symlink outside root: %s -> %s\n", relPath, link.Target)
+				continue
+			case link.IsDir && !w.follow:
+				continue
+			}
+			entry = fs.FileInfoToDirEntry(renamedInfo{link.Info, e.Name()})
+		}
+
+		if entry.IsDir() {
+			first, err := w.Enter(path)
+			if err != nil {
+				return fn(path, entry, err)
+			}
+			if !first {
+				relPath, _ := filepath.Rel(w.root, path)
+				fmt.Fprintf(os.Stderr, "⚠ Skipping already visited directory (symlink cycle): %s\n", relPath)
+				continue
+			}
+		}
+
+		if err := fn(path, entry, nil); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				if entry.IsDir() {
+					continue
+				}
+				return nil
+			}
+			return err
+		}
+
+		if entry.IsDir() {
+			if err := w.walkDir(path, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renamedInfo reports a symlink target's FileInfo under the link's name
+type renamedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (r renamedInfo) Name() string { return r.name }
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nessaee/concat/internal/config"
+	"github.com/nessaee/concat/internal/protocol"
+)
+
+// setupSymlinkFixture creates a project with a loop, a dangling link,
+// a link to a directory and a link escaping the root.
+func setupSymlinkFixture(t *testing.T) string {
+	t.Helper()
+	base := t.TempDir()
+	root := filepath.Join(base, "project")
+	outside := filepath.Join(base, "outside")
+
+	for _, dir := range []string{filepath.Join(root, "pkg"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(root, "main.go"):        "package main",
+		filepath.Join(root, "pkg", "util.go"): "package pkg",
+		filepath.Join(outside, "secret.go"):   "package secret",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		filepath.Join(root, "pkg", "loop"):    "..",
+		filepath.Join(root, "alias"):          "pkg",
+		filepath.Join(root, "broken.go"):      "missing.go",
+		filepath.Join(root, "escape.go"):      filepath.Join(outside, "secret.go"),
+		filepath.Join(root, "linked_main.go"): "main.go",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	return root
+}
+
+func TestConcatenator_Symlinks(t *testing.T) {
+	root := setupSymlinkFixture(t)
+
+	run := func(follow bool) (int, string) {
+		cfg := &config.Config{Extensions: []string{"go"}, FollowSymlinks: follow}
+		concatenator := NewConcatenator(NewFilter(cfg.Extensions, nil, false), cfg, &protocol.MarkdownFormatter{})
+		var buf bytes.Buffer
+		count, _, err := concatenator.Process(root, &buf)
+		if err != nil {
+			t.Fatalf("Process(follow=%v) failed: %v", follow, err)
+		}
+		return count, buf.String()
+	}
+
+	// Default: no directory links, no escaping the root
+	count, output := run(false)
+	if count != 3 {
+		t.Errorf("default: expected 3 files (linked_main.go, main.go, pkg/util.go), got %d", count)
+	}
+	if strings.Contains(output, "package secret") {
+		t.Error("default: read a symlink target outside the root")
+	}
+	if strings.Contains(output, "alias") {
+		t.Error("default: followed a directory symlink")
+	}
+
+	// Follow: the loop back to the root and the alias to pkg are visited once
+	count, output = run(true)
+	if count != 4 {
+		t.Errorf("follow: expected 4 files, got %d\n%s", count, output)
+	}
+	if !strings.Contains(output, "package secret") {
+		t.Error("follow: expected symlink target outside the root to be read")
+	}
+	if strings.Count(output, "package pkg") != 1 {
+		t.Error("follow: expected pkg to be visited exactly once")
+	}
+}
+
+func TestTreeGenerator_Symlinks(t *testing.T) {
+	root := setupSymlinkFixture(t)
+
+	for _, follow := range []bool{false, true} {
+		cfg := &config.Config{Extensions: []string{"go"}, FollowSymlinks: follow}
+		tree, err := NewTreeGenerator(NewFilter(cfg.Extensions, nil, false), cfg).Generate(root)
+		if err != nil {
+			t.Fatalf("Generate(follow=%v) failed: %v", follow, err)
+		}
+
+		for _, want := range []string{"alias -> pkg", "broken.go -> missing.go (dangling)", "loop -> .."} {
+			if !strings.Contains(tree, want) {
+				t.Errorf("follow=%v: tree missing %q\n%s", follow, want, tree)
+			}
+		}
+		if strings.Count(tree, "util.go") != 1 {
+			t.Errorf("follow=%v: expected util.go exactly once\n%s", follow, tree)
+		}
+	}
+}
@@ -1,70 +1,263 @@
 package core
 
 import (
+	"fmt"
+	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/nessaee/concat/internal/config"
+)
+
+// Tree modes select which files the tree shows
+const (
+	// TreeModeIncluded shows only files whose contents are included (default)
+	TreeModeIncluded = "included"
+	// TreeModeFull shows every non-ignored file
+	TreeModeFull = "full"
+	// TreeModeBoth shows every non-ignored file and marks included ones
+	TreeModeBoth = "both"
 )
 
+// includedMarker flags included files in TreeModeBoth
+const includedMarker = " *"
+
 // TreeGenerator generates a directory tree structure
 type TreeGenerator struct {
 	filter *Filter
+	config *config.Config
+	fsys   fs.FS
+	root   string
+	walker *Walker
+	rank   map[string]int
 }
 
 // NewTreeGenerator creates a new TreeGenerator
-func NewTreeGenerator(filter *Filter) *TreeGenerator {
-	return &TreeGenerator{filter: filter}
+func NewTreeGenerator(filter *Filter, cfg *config.Config) *TreeGenerator {
+	return &TreeGenerator{filter: filter, config: cfg}
+}
+
+// SetFS reads directories from fsys instead of the OS file system, like
+// Concatenator.SetFS
+func (t *TreeGenerator) SetFS(fsys fs.FS) {
+	t.fsys = fsys
+}
+
+// SetOrder arranges siblings to follow the given file order (paths relative
+// to the root, as returned by Concatenator.Collect). Directories sort by
+// their earliest file; files not in the list keep lexical order at the end.
+func (t *TreeGenerator) SetOrder(files []string) {
+	t.rank = make(map[string]int, len(files))
+	for i, f := range files {
+		t.rank[filepath.ToSlash(f)] = i
+	}
 }
 
 // Generate returns the tree structure as a string
 func (t *TreeGenerator) Generate(root string) (string, error) {
 	var sb strings.Builder
-	sb.WriteString("### Directory Structure ###\n")
-	sb.WriteString(".\n")
+	switch t.mode() {
+	case TreeModeIncluded, TreeModeFull:
+		sb.WriteString("### Directory Structure ###\n")
+	case TreeModeBoth:
+		sb.WriteString("### Directory Structure (* = contents included) ###\n")
+	default:
+		return "", fmt.Errorf("unknown tree mode %q (expected %s, %s or %s)", t.config.TreeMode, TreeModeFull, TreeModeIncluded, TreeModeBoth)
+	}
 
-	// Use recursive generation
-	treeStr, err := t.generateRecursive(root, "")
+	// Build the whole tree first so directory stats can be aggregated,
+	// then render it with the depth and fan-out limits applied
+	rootNode, err := t.buildRoot(root)
 	if err != nil {
 		return "", err
 	}
-	sb.WriteString(treeStr)
+
+	sb.WriteString(t.annotate(rootNode) + "\n")
+	t.render(&sb, rootNode, "", 1)
 	return sb.String(), nil
 }
 
-func (t *TreeGenerator) generateRecursive(dir string, prefix string) (string, error) {
-	var sb strings.Builder
+// TreeNode is an entry of the built tree, for callers that present it
+// themselves (see Tree)
+type TreeNode struct {
+	Name     string
+	Path     string // relative to the root, slash-separated ("." for the root)
+	IsDir    bool
+	Included bool  // file contents are part of the output
+	Size     int64 // bytes (files: own size, dirs: sum of descendants)
+	Files    int   // files at or below this node
+	Children []*TreeNode
+}
 
-	entries, err := os.ReadDir(dir)
+// Tree builds the tree of root without rendering it. Depth, fan-out and
+// directories-only limits are left to the caller.
+func (t *TreeGenerator) Tree(root string) (*TreeNode, error) {
+	rootNode, err := t.buildRoot(root)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return rootNode.export("."), nil
+}
+
+func (n *treeNode) export(path string) *TreeNode {
+	node := &TreeNode{
+		Name:     n.entry.name,
+		Path:     path,
+		IsDir:    n.entry.isDir,
+		Included: n.included,
+		Size:     n.size,
+		Files:    n.files,
+	}
+	for _, c := range n.children {
+		childPath := c.entry.name
+		if path != "." {
+			childPath = path + "/" + c.entry.name
+		}
+		node.Children = append(node.Children, c.export(childPath))
+	}
+	return node
+}
+
+// buildRoot builds the tree of root with its statistics
+func (t *TreeGenerator) buildRoot(root string) (*treeNode, error) {
+	t.root = root
+	t.walker = nil
+	if t.fsys == nil {
+		walker, err := NewWalker(root, t.config.FollowSymlinks)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := walker.Enter(root); err != nil {
+			return nil, err
+		}
+		t.walker = walker
+	}
+
+	rootNode := &treeNode{entry: treeEntry{name: ".", isDir: true}, rank: math.MaxInt}
+	if err := t.build(root, rootNode); err != nil {
+		return nil, err
+	}
+	return rootNode, nil
+}
+
+// mode returns the configured tree mode, defaulting to TreeModeIncluded
+func (t *TreeGenerator) mode() string {
+	if t.config.TreeMode == "" {
+		return TreeModeIncluded
+	}
+	return t.config.TreeMode
+}
+
+// treeNode is an entry in the built tree with aggregated statistics
+type treeNode struct {
+	entry    treeEntry
+	included bool  // file contents are part of the output
+	rank     int   // position of the earliest file at or below this node
+	size     int64 // bytes (files: own size, dirs: sum of descendants)
+	files    int   // files at or below this node
+	children []*treeNode
+}
+
+func (t *TreeGenerator) build(dir string, node *treeNode) error {
+	entries, err := t.readDir(dir)
+	if err != nil {
+		return err
 	}
 
-	// Filter entries first to know which is last
-	var filtered []os.DirEntry
 	for _, e := range entries {
 		path := filepath.Join(dir, e.Name())
+		entry := treeEntry{name: e.Name(), isDir: e.IsDir()}
+
+		if e.Type()&os.ModeSymlink != 0 && t.walker != nil {
+			link, err := t.walker.Resolve(path)
+			if err != nil {
+				return err
+			}
+			entry.link = link
+			entry.isDir = link.IsDir
+		}
 
-		// Handle relative path for filter if possible, or assume relative execution
-		// If dir is ".", path is "foo".
-		// If dir is "foo", path is "foo/bar".
-		// This works for filter matchers usually.
+		// Filter on the path relative to the root, like Concatenator.Process
+		rel, err := filepath.Rel(t.root, path)
+		if err != nil {
+			return err
+		}
 
-		if t.filter.IsIgnored(path, e.IsDir()) {
+		if t.filter.IsIgnored(rel, entry.isDir) {
 			continue
 		}
-		
-		// NEW: Tree Pruning - Only show files that match requested extensions
-		if !e.IsDir() {
-			if !t.filter.HasValidExtension(e.Name()) {
+
+		child := &treeNode{entry: entry, rank: math.MaxInt}
+
+		// NEW: Tree Pruning - Only show included files unless the full structure was requested
+		if !entry.isDir {
+			child.included = t.filter.ShouldProcess(rel, false)
+			if !child.included && t.mode() == TreeModeIncluded {
 				continue
 			}
 		}
 
-		filtered = append(filtered, e)
+		if entry.isDir {
+			if t.descend(entry, path) {
+				if err := t.build(path, child); err != nil {
+					return err
+				}
+			}
+		} else {
+			child.files = 1
+			if r, ok := t.rank[filepath.ToSlash(rel)]; ok {
+				child.rank = r
+			}
+			if entry.link != nil {
+				if entry.link.Info != nil {
+					child.size = entry.link.Info.Size()
+				}
+			} else if info, err := e.Info(); err == nil {
+				child.size = info.Size()
+			}
+		}
+
+		node.size += child.size
+		node.files += child.files
+		node.rank = min(node.rank, child.rank)
+		node.children = append(node.children, child)
 	}
 
-	for i, e := range filtered {
-		isLast := i == len(filtered)-1
+	if t.rank != nil {
+		sort.SliceStable(node.children, func(i, j int) bool { return node.children[i].rank < node.children[j].rank })
+	}
+	return nil
+}
+
+// readDir lists a directory in the configured file system
+func (t *TreeGenerator) readDir(dir string) ([]fs.DirEntry, error) {
+	if t.fsys != nil {
+		return fs.ReadDir(t.fsys, filepath.ToSlash(dir))
+	}
+	return os.ReadDir(dir)
+}
+
+func (t *TreeGenerator) render(sb *strings.Builder, node *treeNode, prefix string, depth int) {
+	var visible []*treeNode
+	for _, c := range node.children {
+		if t.config.TreeDirsOnly && !c.entry.isDir {
+			continue
+		}
+		visible = append(visible, c)
+	}
+
+	// Collapse wide directories, summarizing the hidden entries
+	var hidden []*treeNode
+	if limit := t.config.TreeFanout; limit > 0 && len(visible) > limit {
+		hidden = visible[limit:]
+		visible = visible[:limit]
+	}
+
+	for i, c := range visible {
+		isLast := i == len(visible)-1 && len(hidden) == 0
 		connector := "├── "
 		newPrefix := prefix + "│   "
 		if isLast {
@@ -72,17 +265,115 @@ func (t *TreeGenerator) generateRecursive(dir string, prefix string) (string, er
 			newPrefix = prefix + "    "
 		}
 
-		sb.WriteString(prefix + connector + e.Name() + "\n")
+		sb.WriteString(prefix + connector + t.annotate(c) + "\n")
 
-		if e.IsDir() {
-			path := filepath.Join(dir, e.Name())
-			subTree, err := t.generateRecursive(path, newPrefix)
-			if err != nil {
-				return "", err
-			}
-			sb.WriteString(subTree)
+		if c.entry.isDir && (t.config.TreeDepth <= 0 || depth < t.config.TreeDepth) {
+			t.render(sb, c, newPrefix, depth+1)
 		}
 	}
 
-	return sb.String(), nil
+	if len(hidden) > 0 {
+		sb.WriteString(prefix + "└── " + summarizeHidden(hidden) + "\n")
+	}
+}
+
+// annotate renders a node's label, adding size and token estimates with --tree-stats
+func (t *TreeGenerator) annotate(n *treeNode) string {
+	label := n.entry.label()
+	if n.included && t.mode() == TreeModeBoth {
+		label += includedMarker
+	}
+	if !t.config.TreeStats {
+		return label
+	}
+	if n.entry.isDir {
+		return fmt.Sprintf("%s (%d %s, %s, ~%s tokens)", label, n.files, Plural(n.files, "file", "files"), formatBytes(n.size), formatCount(n.size/4))
+	}
+	return fmt.Sprintf("%s (%s, ~%s tokens)", label, formatBytes(n.size), formatCount(n.size/4))
+}
+
+// summarizeHidden describes the entries cut off by the fan-out limit
+func summarizeHidden(hidden []*treeNode) string {
+	dirs := 0
+	for _, n := range hidden {
+		if n.entry.isDir {
+			dirs++
+		}
+	}
+	files := len(hidden) - dirs
+
+	var parts []string
+	if files > 0 {
+		parts = append(parts, fmt.Sprintf("%d more %s", files, Plural(files, "file", "files")))
+	}
+	if dirs > 0 {
+		parts = append(parts, fmt.Sprintf("%d more %s", dirs, Plural(dirs, "directory", "directories")))
+	}
+	return "… " + strings.Join(parts, ", ")
+}
+
+// Plural picks the singular or plural form of a noun for a count of n
+func Plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// formatBytes renders a byte count in human readable units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatCount renders large counts compactly (e.g. 12.3k)
+func formatCount(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// treeEntry is a directory entry with its symlink resolved
+type treeEntry struct {
+	name  string
+	isDir bool
+	link  *LinkInfo
+}
+
+// label renders the entry name, showing symlinks as "link -> target"
+func (e treeEntry) label() string {
+	if e.link == nil {
+		return e.name
+	}
+	label := e.name + " -> " + e.link.Target
+	if e.link.Dangling {
+		label += " (dangling)"
+	}
+	return label
+}
+
+// descend reports whether the tree should recurse into a directory entry.
+// Directory symlinks are only followed when enabled, and each directory is
+// entered at most once so link loops terminate.
+func (t *TreeGenerator) descend(e treeEntry, path string) bool {
+	if e.link != nil && !t.config.FollowSymlinks {
+		return false
+	}
+	if t.walker == nil {
+		return true
+	}
+	first, err := t.walker.Enter(path)
+	return err == nil && first
 }
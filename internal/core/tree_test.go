@@ -0,0 +1,131 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nessaee/concat/internal/config"
+)
+
+func setupTreeFixture(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":          strings.Repeat("a", 400),
+		"pkg/a.go":         strings.Repeat("b", 100),
+		"pkg/b.go":         strings.Repeat("c", 100),
+		"pkg/c.go":         strings.Repeat("d", 100),
+		"pkg/deep/deep.go": "package deep",
+		"README.md":        "not included",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestTreeGenerator_Options(t *testing.T) {
+	root := setupTreeFixture(t)
+
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "Plain",
+			want:    []string{"├── main.go\n", "└── pkg\n", "    └── deep\n", "        └── deep.go\n"},
+			notWant: []string{"README.md"},
+		},
+		{
+			name: "Stats",
+			cfg:  config.Config{TreeStats: true},
+			want: []string{
+				". (5 files, 712 B, ~178 tokens)",
+				"main.go (400 B, ~100 tokens)",
+				"pkg (4 files, 312 B, ~78 tokens)",
+			},
+		},
+		{
+			name:    "Depth",
+			cfg:     config.Config{TreeDepth: 1},
+			want:    []string{"└── pkg\n"},
+			notWant: []string{"a.go", "deep"},
+		},
+		{
+			name:    "DirsOnly",
+			cfg:     config.Config{TreeDirsOnly: true},
+			want:    []string{"└── pkg\n", "    └── deep\n"},
+			notWant: []string{".go"},
+		},
+		{
+			name:    "Full",
+			cfg:     config.Config{TreeMode: TreeModeFull},
+			want:    []string{"├── README.md\n", "├── main.go\n"},
+			notWant: []string{"*"},
+		},
+		{
+			name: "Both",
+			cfg:  config.Config{TreeMode: TreeModeBoth},
+			want: []string{"(* = contents included)", "├── README.md\n", "├── main.go *\n", "deep.go *\n"},
+		},
+		{
+			name:    "Fanout",
+			cfg:     config.Config{TreeFanout: 2},
+			want:    []string{"    ├── a.go\n", "    ├── b.go\n", "    └── … 1 more file, 1 more directory\n"},
+			notWant: []string{"c.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt.cfg.Extensions = []string{"go"}
+		filter := NewFilter(tt.cfg.Extensions, nil, false)
+		tree, err := NewTreeGenerator(filter, &tt.cfg).Generate(root)
+		if err != nil {
+			t.Fatalf("%s: Generate failed: %v", tt.name, err)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(tree, w) {
+				t.Errorf("%s: tree missing %q\n%s", tt.name, w, tree)
+			}
+		}
+		for _, w := range tt.notWant {
+			if strings.Contains(tree, w) {
+				t.Errorf("%s: tree unexpectedly contains %q\n%s", tt.name, w, tree)
+			}
+		}
+	}
+}
+
+func TestTreeGenerator_Tree(t *testing.T) {
+	root := setupTreeFixture(t)
+
+	cfg := config.Config{Extensions: []string{"go"}, TreeMode: TreeModeFull}
+	tree, err := NewTreeGenerator(NewFilter(cfg.Extensions, nil, false), &cfg).Tree(root)
+	if err != nil {
+		t.Fatalf("Tree failed: %v", err)
+	}
+	if tree.Path != "." || tree.Files != 6 || len(tree.Children) != 3 {
+		t.Fatalf("Unexpected root: %+v", tree)
+	}
+
+	readme, pkg := tree.Children[0], tree.Children[2]
+	if readme.Name != "README.md" || readme.Included {
+		t.Errorf("Expected README.md to be shown but not included: %+v", readme)
+	}
+	if pkg.Path != "pkg" || !pkg.IsDir || pkg.Files != 4 || pkg.Size != 312 {
+		t.Errorf("Unexpected pkg: %+v", pkg)
+	}
+	if deep := pkg.Children[3].Children[0]; deep.Path != "pkg/deep/deep.go" || !deep.Included {
+		t.Errorf("Unexpected nested file: %+v", deep)
+	}
+}
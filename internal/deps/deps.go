@@ -0,0 +1,152 @@
+package deps
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Resolver finds the project files a source file imports
+type Resolver interface {
+	// Imports returns the root-relative paths of files in the project that
+	// path (root-relative) imports. Only paths present in files are returned.
+	Imports(path string, src []byte, files *FileSet) []string
+}
+
+// FileSet is the set of candidate files, relative to the root of a file system
+type FileSet struct {
+	fsys  fs.FS
+	paths map[string]struct{}
+	dirs  map[string][]string
+
+	// module is the Go module path of the root go.mod. It is read once per
+	// file set, not per resolver: resolvers are shared by every Build.
+	moduleOnce sync.Once
+	module     string
+}
+
+// NewFileSet indexes the given paths, relative to the directory root
+func NewFileSet(root string, paths []string) *FileSet {
+	return NewFileSetFS(os.DirFS(root), paths)
+}
+
+// NewFileSetFS indexes the given paths, relative to the root of fsys
+func NewFileSetFS(fsys fs.FS, paths []string) *FileSet {
+	s := &FileSet{
+		fsys:  fsys,
+		paths: make(map[string]struct{}, len(paths)),
+		dirs:  make(map[string][]string),
+	}
+	for _, p := range paths {
+		p = filepath.ToSlash(p)
+		s.paths[p] = struct{}{}
+		dir := filepath.ToSlash(filepath.Dir(p))
+		s.dirs[dir] = append(s.dirs[dir], p)
+	}
+	for _, d := range s.dirs {
+		sort.Strings(d)
+	}
+	return s
+}
+
+// Has reports whether path (slash-separated) is in the set
+func (s *FileSet) Has(path string) bool {
+	_, ok := s.paths[path]
+	return ok
+}
+
+// InDir returns the files directly inside dir (slash-separated, "." for root)
+func (s *FileSet) InDir(dir string) []string {
+	return s.dirs[dir]
+}
+
+// ReadFile reads a file (slash-separated, relative to the root)
+func (s *FileSet) ReadFile(path string) ([]byte, error) {
+	return fs.ReadFile(s.fsys, path)
+}
+
+// resolvers maps a file extension (without dot) to its Resolver
+var resolvers = map[string]Resolver{}
+
+// Register adds a Resolver for the given extensions
+func Register(r Resolver, extensions ...string) {
+	for _, ext := range extensions {
+		resolvers[strings.TrimPrefix(ext, ".")] = r
+	}
+}
+
+func init() {
+	Register(&GoResolver{}, "go")
+	Register(ScriptResolver{}, "js", "jsx", "mjs", "cjs", "ts", "tsx")
+	Register(PythonResolver{}, "py", "pyi")
+}
+
+// Graph maps each file to the files it imports. All paths are root-relative
+// and slash-separated.
+type Graph struct {
+	Edges map[string][]string
+}
+
+// Build parses every file in paths (relative to root) and resolves its imports
+func Build(root string, paths []string) (*Graph, error) {
+	return BuildFS(os.DirFS(root), paths)
+}
+
+// BuildFS is Build for paths relative to the root of fsys
+func BuildFS(fsys fs.FS, paths []string) (*Graph, error) {
+	files := NewFileSetFS(fsys, paths)
+	g := &Graph{Edges: make(map[string][]string, len(paths))}
+
+	for _, p := range paths {
+		p = filepath.ToSlash(p)
+		r, ok := resolvers[strings.TrimPrefix(filepath.Ext(p), ".")]
+		if !ok {
+			continue
+		}
+		src, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return nil, err
+		}
+
+		seen := make(map[string]bool)
+		var edges []string
+		for _, dep := range r.Imports(p, src, files) {
+			if dep == p || seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			edges = append(edges, dep)
+		}
+		sort.Strings(edges)
+		g.Edges[p] = edges
+	}
+	return g, nil
+}
+
+// Distances returns, for every file reachable from seeds, the number of import
+// hops from the nearest seed (seeds themselves are at distance 0).
+func (g *Graph) Distances(seeds []string) map[string]int {
+	dist := make(map[string]int)
+	var queue []string
+	for _, s := range seeds {
+		s = filepath.ToSlash(s)
+		if _, ok := dist[s]; !ok {
+			dist[s] = 0
+			queue = append(queue, s)
+		}
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, dep := range g.Edges[cur] {
+			if _, ok := dist[dep]; !ok {
+				dist[dep] = dist[cur] + 1
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return dist
+}
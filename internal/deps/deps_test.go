@@ -0,0 +1,86 @@
+package deps
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) []string {
+	t.Helper()
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, name)
+	}
+	return paths
+}
+
+func TestBuild(t *testing.T) {
+	root := t.TempDir()
+	paths := writeFiles(t, root, map[string]string{
+		"go.mod":                 "module example.com/app\n\ngo 1.22\n",
+		"main.go":                "package main\n\nimport (\n\t\"fmt\"\n\t\"example.com/app/store\"\n)\n",
+		"store/store.go":         "package store\n\nimport \"example.com/app/model\"\n",
+		"store/cache.go":         "package store\n",
+		"store/store_test.go":    "package store\n",
+		"model/model.go":         "package model\n",
+		"web/app.ts":             "import { api } from './api';\nimport React from 'react';\nconst u = require('../web/util');\n",
+		"web/api/index.ts":       "export const api = 1;\n",
+		"web/util.js":            "module.exports = {};\n",
+		"py/app.py":              "import os\nfrom py.models import User\nfrom . import helpers\n",
+		"py/models.py":           "class User: pass\n",
+		"py/helpers/__init__.py": "",
+	})
+
+	g, err := Build(root, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"main.go":        {"store/cache.go", "store/store.go"},
+		"store/store.go": {"model/model.go"},
+		"web/app.ts":     {"web/api/index.ts", "web/util.js"},
+		"py/app.py":      {"py/helpers/__init__.py", "py/models.py"},
+	}
+	for file, want := range tests {
+		if got := g.Edges[file]; !reflect.DeepEqual(got, want) {
+			t.Errorf("Edges[%q] = %v; want %v", file, got, want)
+		}
+	}
+
+	dist := g.Distances([]string{"main.go"})
+	if dist["store/store.go"] != 1 || dist["model/model.go"] != 2 {
+		t.Errorf("unexpected distances: %v", dist)
+	}
+	if _, ok := dist["web/app.ts"]; ok {
+		t.Error("unrelated file reachable from main.go")
+	}
+}
+
+func TestBuild_GoModules(t *testing.T) {
+	// Each root resolves imports against its own go.mod
+	for _, module := range []string{"example.com/first", "example.com/second"} {
+		root := t.TempDir()
+		paths := writeFiles(t, root, map[string]string{
+			"go.mod":     "module " + module + "\n\ngo 1.22\n",
+			"main.go":    "package main\n\nimport \"" + module + "/lib\"\n",
+			"lib/lib.go": "package lib\n",
+		})
+		g, err := Build(root, paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := g.Edges["main.go"]; !reflect.DeepEqual(got, []string{"lib/lib.go"}) {
+			t.Errorf("%s: Edges[main.go] = %v; want [lib/lib.go]", module, got)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package deps
+
+import (
+	"bufio"
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// GoResolver resolves Go imports against the module path declared in the
+// root go.mod. Importing a package depends on all of its non-test files.
+type GoResolver struct{}
+
+// Imports implements Resolver
+func (r *GoResolver) Imports(path string, src []byte, files *FileSet) []string {
+	files.moduleOnce.Do(func() { files.module = modulePath(files) })
+	module := files.module
+	if module == "" {
+		return nil
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), path, src, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+
+	var deps []string
+	for _, imp := range f.Imports {
+		ip, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		var dir string
+		switch {
+		case ip == module:
+			dir = "."
+		case strings.HasPrefix(ip, module+"/"):
+			dir = strings.TrimPrefix(ip, module+"/")
+		default:
+			continue
+		}
+		for _, file := range files.InDir(dir) {
+			if strings.HasSuffix(file, ".go") && !strings.HasSuffix(file, "_test.go") {
+				deps = append(deps, file)
+			}
+		}
+	}
+	return deps
+}
+
+// modulePath reads the module directive from the root go.mod of files
+func modulePath(files *FileSet) string {
+	gomod, err := files.ReadFile("go.mod")
+	if err != nil {
+		return ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(gomod))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "module" {
+			mod := fields[1]
+			if unq, err := strconv.Unquote(mod); err == nil {
+				mod = unq
+			}
+			return mod
+		}
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package deps
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	rePyImport = regexp.MustCompile(`(?m)^[ \t]*import[ \t]+([\w.]+(?:[ \t]*,[ \t]*[\w.]+)*)`)
+	rePyFrom   = regexp.MustCompile(`(?m)^[ \t]*from[ \t]+(\.*[\w.]*)[ \t]+import[ \t]+(?:\(([^)]*)\)|([\w \t,]+))`)
+)
+
+// PythonResolver resolves absolute imports against the project root and
+// relative imports ("from .models import User") against the importing package
+type PythonResolver struct{}
+
+// Imports implements Resolver
+func (PythonResolver) Imports(file string, src []byte, files *FileSet) []string {
+	var deps []string
+	for _, m := range rePyImport.FindAllSubmatch(src, -1) {
+		for _, mod := range strings.Split(string(m[1]), ",") {
+			deps = append(deps, resolvePyModule(".", strings.TrimSpace(mod), files)...)
+		}
+	}
+
+	for _, m := range rePyFrom.FindAllSubmatch(src, -1) {
+		mod := string(m[1])
+		base := "."
+		if dots := len(mod) - len(strings.TrimLeft(mod, ".")); dots > 0 {
+			base = path.Dir(file)
+			for i := 1; i < dots; i++ {
+				base = path.Dir(base)
+			}
+			mod = mod[dots:]
+		}
+
+		found := resolvePyModule(base, mod, files)
+		deps = append(deps, found...)
+
+		// "from pkg import mod" may name submodules rather than attributes
+		names := string(m[2]) + string(m[3])
+		for _, name := range strings.Split(names, ",") {
+			name = strings.TrimSpace(name)
+			if i := strings.IndexAny(name, " \t\n"); i >= 0 {
+				name = name[:i] // "x as y"
+			}
+			if name == "" {
+				continue
+			}
+			sub := name
+			if mod != "" {
+				sub = mod + "." + name
+			}
+			deps = append(deps, resolvePyModule(base, sub, files)...)
+		}
+	}
+	return deps
+}
+
+// resolvePyModule maps a dotted module name to a file below base
+func resolvePyModule(base, mod string, files *FileSet) []string {
+	p := base
+	if mod != "" {
+		p = path.Join(base, strings.ReplaceAll(mod, ".", "/"))
+	}
+	for _, candidate := range []string{p + ".py", p + ".pyi", path.Join(p, "__init__.py")} {
+		if files.Has(candidate) {
+			return []string{candidate}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package deps
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	// reScriptImport matches ES module imports/exports, require() and dynamic import()
+	reScriptImport = regexp.MustCompile(`(?m)(?:\bfrom\s*|\bimport\s*\(?\s*|\brequire\s*\(\s*|^\s*import\s+)["']([^"']+)["']`)
+	// scriptSuffixes are tried in order when resolving an extensionless specifier
+	scriptSuffixes = []string{"", ".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs", "/index.ts", "/index.tsx", "/index.js", "/index.jsx"}
+)
+
+// ScriptResolver resolves relative JS/TS imports ("./util", "../lib/index.js")
+type ScriptResolver struct{}
+
+// Imports implements Resolver
+func (ScriptResolver) Imports(file string, src []byte, files *FileSet) []string {
+	var deps []string
+	for _, m := range reScriptImport.FindAllSubmatch(src, -1) {
+		spec := string(m[1])
+		if !strings.HasPrefix(spec, "./") && !strings.HasPrefix(spec, "../") {
+			continue // package import
+		}
+		base := path.Join(path.Dir(file), spec)
+		for _, suffix := range scriptSuffixes {
+			if files.Has(base + suffix) {
+				deps = append(deps, base+suffix)
+				break
+			}
+		}
+	}
+	return deps
+}
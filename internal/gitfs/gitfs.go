@@ -0,0 +1,302 @@
+package gitfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FS is a read-only fs.FS over the tree of a git revision, rooted at the
+// directory it was opened in. The listing comes from git ls-tree; contents
+// are read on demand through a single git cat-file --batch process, which
+// Close stops. All files report the commit time as their modification time.
+type FS struct {
+	dir     string
+	files   map[string]*blob
+	dirs    map[string]map[string]bool // directory -> child names
+	modTime time.Time
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// blob is a file in the revision's tree
+type blob struct {
+	oid  string
+	size int64
+	mode fs.FileMode
+}
+
+// Open lists the tree of rev below dir, which must be inside a git
+// repository. Symlinks and submodules are skipped.
+func Open(dir, rev string) (*FS, error) {
+	if rev == "" || strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision %q", rev)
+	}
+	out, err := git(dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %q: %w", rev, err)
+	}
+	commit := strings.TrimSpace(string(out))
+
+	out, err = git(dir, "show", "-s", "--format=%ct", commit)
+	if err != nil {
+		return nil, err
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected commit time %q", out)
+	}
+
+	// Without --full-tree, paths are listed relative to dir and limited to it
+	out, err = git(dir, "ls-tree", "-r", "-z", "-l", commit)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := &FS{
+		dir:     dir,
+		files:   make(map[string]*blob),
+		dirs:    map[string]map[string]bool{".": {}},
+		modTime: time.Unix(seconds, 0),
+	}
+	for _, record := range strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00") {
+		if record == "" {
+			continue
+		}
+		meta, name, ok := strings.Cut(record, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 4 || !fs.ValidPath(name) {
+			return nil, fmt.Errorf("unexpected git ls-tree output %q", record)
+		}
+		mode, kind, oid := fields[0], fields[1], fields[2]
+		if kind != "blob" {
+			continue // submodules
+		}
+		if mode == "120000" {
+			fmt.Fprintf(os.Stderr, "⚠ Skipping symlink at %s: %s\n", rev, name)
+			continue
+		}
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected git ls-tree output %q", record)
+		}
+		perm := fs.FileMode(0644)
+		if mode == "100755" {
+			perm = 0755
+		}
+		fsys.add(name, &blob{oid: oid, size: size, mode: perm})
+	}
+	return fsys, nil
+}
+
+// add stores a file, creating its parent directories
+func (f *FS) add(name string, b *blob) {
+	f.files[name] = b
+	for name != "." {
+		dir := path.Dir(name)
+		if f.dirs[dir] == nil {
+			f.dirs[dir] = make(map[string]bool)
+		}
+		f.dirs[dir][path.Base(name)] = true
+		name = dir
+	}
+}
+
+// Open implements fs.FS. Files are read in full when opened.
+func (f *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, ok := f.files[name]; ok {
+		data, err := f.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		info, _ := f.Stat(name)
+		return &openFile{Reader: bytes.NewReader(data), info: info}, nil
+	}
+	if _, ok := f.dirs[name]; ok {
+		entries, _ := f.ReadDir(name)
+		info, _ := f.Stat(name)
+		return &openDir{info: info, entries: entries}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Stat implements fs.StatFS without reading the file
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if b, ok := f.files[name]; ok {
+		return &fileInfo{name: path.Base(name), size: b.size, mode: b.mode, modTime: f.modTime}, nil
+	}
+	if _, ok := f.dirs[name]; ok {
+		return &fileInfo{name: path.Base(name), mode: fs.ModeDir | 0755, modTime: f.modTime}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir implements fs.ReadDirFS, listing entries sorted by name
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	children, ok := f.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for child := range children {
+		info, _ := f.Stat(path.Join(name, child))
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// ReadFile implements fs.ReadFileFS
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	b, ok := f.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	data, err := f.catFile(b.oid)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// catFile reads a blob through the git cat-file --batch process, starting
+// it on first use
+func (f *FS) catFile(oid string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cmd == nil {
+		cmd := exec.Command("git", "cat-file", "--batch")
+		cmd.Dir = f.dir
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start git cat-file: %w", err)
+		}
+		f.cmd, f.stdin, f.stdout = cmd, stdin, bufio.NewReader(stdout)
+	}
+
+	if _, err := fmt.Fprintln(f.stdin, oid); err != nil {
+		return nil, err
+	}
+	// Response: "<oid> <type> <size>\n<content>\n", or "<oid> missing\n"
+	header, err := f.stdout.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("git cat-file: %s", strings.TrimSpace(header))
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %s", strings.TrimSpace(header))
+	}
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(f.stdout, data); err != nil {
+		return nil, err
+	}
+	return data[:size], nil
+}
+
+// Close stops the git cat-file process, if it was started
+func (f *FS) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cmd == nil {
+		return nil
+	}
+	f.stdin.Close()
+	err := f.cmd.Wait()
+	f.cmd = nil
+	return err
+}
+
+// git runs a git command in dir and returns its output, reporting git's
+// own message on failure
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() any           { return nil }
+
+type openFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *openFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *openFile) Close() error               { return nil }
+
+type openDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *openDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *openDir) Close() error               { return nil }
+
+func (d *openDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile
+func (d *openDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	rest = rest[:min(n, len(rest))]
+	d.offset += len(rest)
+	return rest, nil
+}
@@ -0,0 +1,172 @@
+package gitfs
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// setupRepo creates a repository with a tagged first commit and a second
+// commit changing it
+func setupRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t", "GIT_CONFIG_GLOBAL=/dev/null")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q")
+	write("main.go", "package main\n")
+	write("pkg/util.go", "package pkg\n")
+	write(".gitignore", "gen/\n")
+	run("add", "-A")
+	run("commit", "-q", "-m", "first")
+	run("tag", "v1")
+
+	write("main.go", "package main // changed\n")
+	write("pkg/new.go", "package pkg\n")
+	run("add", "-A")
+	run("commit", "-q", "-m", "second")
+	return dir
+}
+
+func TestOpen(t *testing.T) {
+	dir := setupRepo(t)
+
+	fsys, err := Open(dir, "v1")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer fsys.Close()
+
+	if err := fstest.TestFS(fsys, "main.go", "pkg/util.go", ".gitignore"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := fs.ReadFile(fsys, "main.go")
+	if err != nil || string(data) != "package main\n" {
+		t.Errorf("Expected the content at v1, got %q: %v", data, err)
+	}
+	if _, err := fs.Stat(fsys, "pkg/new.go"); err == nil {
+		t.Error("Expected a file added later to be absent")
+	}
+	if err := fsys.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestOpen_Subdirectory(t *testing.T) {
+	dir := setupRepo(t)
+
+	fsys, err := Open(filepath.Join(dir, "pkg"), "HEAD")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer fsys.Close()
+
+	if err := fstest.TestFS(fsys, "util.go", "new.go"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(fsys, "main.go"); err == nil {
+		t.Error("Expected files outside the directory to be absent")
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	dir := setupRepo(t)
+
+	for _, rev := range []string{"missing", "--all", ""} {
+		if _, err := Open(dir, rev); err == nil {
+			t.Errorf("Expected an error for revision %q", rev)
+		}
+	}
+	if _, err := Open(t.TempDir(), "HEAD"); err == nil {
+		t.Error("Expected an error outside a repository")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	dir := setupRepo(t)
+	for _, args := range [][]string{{"checkout", "-q", "-b", "work"}, {"remote", "add", "upstream", "https://example.com/repo.git"}} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	info, err := Describe(dir, "")
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	if info.Branch != "work" || len(info.Commit) != 12 || info.Dirty || info.Remote != "upstream" {
+		t.Errorf("Unexpected info for a clean checkout: %+v", info)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main // edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := Describe(dir, ""); err != nil || !info.Dirty {
+		t.Errorf("Expected a dirty working tree, got %+v, %v", info, err)
+	}
+
+	// A tag is not a branch, and revisions ignore the working tree
+	if tagged, err := Describe(dir, "v1"); err != nil || tagged.Branch != "" || tagged.Dirty || tagged.Commit == info.Commit {
+		t.Errorf("Unexpected info for v1: %+v, %v", tagged, err)
+	}
+	if _, err := Describe(t.TempDir(), ""); err == nil {
+		t.Error("Expected an error outside a repository")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir := setupRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "pkg", "util.go"), []byte("package pkg // edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "untracked.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := History(dir, "")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if _, ok := history["pkg/util.go"]; ok {
+		t.Error("Expected files with uncommitted changes to be left out")
+	}
+	if _, ok := history["untracked.go"]; ok {
+		t.Error("Expected untracked files to be left out")
+	}
+	if history["main.go"] == 0 || history["main.go"] < history[".gitignore"] {
+		t.Errorf("Expected main.go to have changed last: %v", history)
+	}
+
+	// Paths are relative to dir; revisions ignore the working tree
+	sub, err := History(filepath.Join(dir, "pkg"), "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sub["util.go"]; !ok || len(sub) != 1 {
+		t.Errorf("Expected only util.go as of v1, got %v", sub)
+	}
+}
@@ -0,0 +1,110 @@
+package gitfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Info describes the state of a repository, for document headers
+type Info struct {
+	// Branch is empty for a detached HEAD or a revision that is not a branch
+	Branch string
+	// Commit is the abbreviated commit hash
+	Commit string
+	// Dirty reports uncommitted changes, including untracked files (working
+	// tree only)
+	Dirty bool
+	// Remote is the remote of the branch's upstream, or the only remote
+	Remote string
+}
+
+// Describe returns the state of the repository containing dir as of rev, or
+// of its working tree if rev is empty. It fails if dir is not inside a
+// repository with commits.
+func Describe(dir, rev string) (*Info, error) {
+	if strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision %q", rev)
+	}
+	target := rev
+	if target == "" {
+		target = "HEAD"
+	}
+	out, err := git(dir, "rev-parse", "--short=12", "--verify", "--quiet", target+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %q: %w", target, err)
+	}
+	info := &Info{Commit: strings.TrimSpace(string(out))}
+
+	if rev == "" {
+		if out, err := git(dir, "symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+			info.Branch = strings.TrimSpace(string(out))
+		}
+		out, err := git(dir, "status", "--porcelain")
+		if err != nil {
+			return nil, err
+		}
+		info.Dirty = len(out) > 0
+	} else if _, err := git(dir, "rev-parse", "--verify", "--quiet", "refs/heads/"+rev); err == nil {
+		info.Branch = rev
+	}
+
+	if info.Branch != "" {
+		if out, err := git(dir, "config", "--get", "branch."+info.Branch+".remote"); err == nil {
+			info.Remote = strings.TrimSpace(string(out))
+		}
+	}
+	if info.Remote == "" {
+		if out, err := git(dir, "remote"); err == nil {
+			if remotes := strings.Fields(string(out)); len(remotes) == 1 {
+				info.Remote = remotes[0]
+			}
+		}
+	}
+	return info, nil
+}
+
+// History returns when each file below dir last changed, as of rev (or HEAD
+// for the working tree): the Unix time of the newest commit touching it,
+// keyed by slash-separated path relative to dir. Files that are untracked or
+// have uncommitted changes in the working tree are left out.
+func History(dir, rev string) (map[string]int64, error) {
+	if strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision %q", rev)
+	}
+	target := rev
+	if target == "" {
+		target = "HEAD"
+	}
+	out, err := git(dir, "-c", "core.quotepath=off", "log", "--format=%x00%ct", "--name-only", "--relative", target, "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]int64)
+	var when int64
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "\x00"):
+			if when, err = strconv.ParseInt(line[1:], 10, 64); err != nil {
+				return nil, fmt.Errorf("unexpected commit time %q", line[1:])
+			}
+		case line != "":
+			// Commits are listed newest first
+			if _, seen := changed[line]; !seen {
+				changed[line] = when
+			}
+		}
+	}
+
+	if rev == "" {
+		out, err := git(dir, "-c", "core.quotepath=off", "diff", "--name-only", "--relative", "HEAD", "--", ".")
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range strings.Split(string(out), "\n") {
+			delete(changed, name)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,100 @@
+package infra
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteFileAtomic replaces path with data by writing a temporary file in the
+// same directory and renaming it over the target, so readers never observe a
+// partially written file
+func WriteFileAtomic(path string, data []byte) error {
+	f, err := CreateAtomic(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// AtomicFile is a file written through a temporary file in the target's
+// directory. Commit renames it over the target; Abort discards it.
+type AtomicFile struct {
+	*os.File
+	path string
+	done bool
+}
+
+// CreateAtomic starts writing path atomically
+func CreateAtomic(path string) (*AtomicFile, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPrefix(path)+"*")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: tmp, path: path}, nil
+}
+
+// IsAtomicTemp reports whether name is one of the temporary files through
+// which path is written
+func IsAtomicTemp(name, path string) bool {
+	return filepath.Dir(name) == filepath.Dir(path) && strings.HasPrefix(filepath.Base(name), tempPrefix(path))
+}
+
+func tempPrefix(path string) string {
+	return "." + filepath.Base(path) + ".tmp"
+}
+
+// Commit syncs the temporary file and renames it over the target
+func (f *AtomicFile) Commit() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	tmpName := f.Name()
+
+	// Clean up the temp file on any failure
+	ok := false
+	defer func() {
+		if !ok {
+			f.File.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+
+	// Keep the permissions of an existing target (CreateTemp uses 0600)
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(f.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpName, f.path); err != nil {
+		return err
+	}
+	ok = true
+	return nil
+}
+
+// Abort removes the temporary file, leaving the target untouched. It does
+// nothing after Commit.
+func (f *AtomicFile) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.File.Close()
+	os.Remove(f.Name())
+}
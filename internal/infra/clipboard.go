@@ -1,18 +1,244 @@
 package infra
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
 	"github.com/atotto/clipboard"
 )
 
-// Clipboard handles clipboard operations
-type Clipboard struct{}
+// Clipboard backend names for --clipboard-backend
+const (
+	BackendAuto    = "auto"
+	BackendOSC52   = "osc52"
+	BackendWlCopy  = "wl-copy"
+	BackendXclip   = "xclip"
+	BackendXsel    = "xsel"
+	BackendPbcopy  = "pbcopy"
+	BackendWindows = "windows"
+	BackendFile    = "file"
+)
+
+// Backends lists the accepted --clipboard-backend values
+var Backends = []string{BackendAuto, BackendOSC52, BackendWlCopy, BackendXclip, BackendXsel, BackendPbcopy, BackendWindows, BackendFile}
+
+// OSC52Limit is the size of an OSC 52 sequence above which some terminals
+// (e.g. hterm, older tmux and xterm setups) truncate or drop it
+const OSC52Limit = 100_000
+
+// Backend copies text to a clipboard
+type Backend interface {
+	Name() string
+	// Available reports whether the backend can work in this environment
+	Available() bool
+	Copy(text string) error
+}
+
+// Clipboard copies text with the first of its backends that is available and
+// succeeds, falling back to a temporary file so the text is never lost
+type Clipboard struct {
+	Backends []Backend
+	// Fallback receives the text when every backend fails
+	Fallback *FileBackend
+	// Warn receives warnings about failed backends and oversized sequences
+	Warn io.Writer
+}
+
+// NewClipboard returns the clipboard for a --clipboard-backend value: auto
+// detection (OSC 52 first over SSH, then the native tools, then OSC 52) or a
+// single named backend
+func NewClipboard(name string) (*Clipboard, error) {
+	warn := io.Writer(os.Stderr)
+	osc52 := &OSC52{Tmux: os.Getenv("TMUX") != "", Warn: warn}
+	native := []Backend{
+		&CommandBackend{name: BackendWlCopy, args: []string{"wl-copy"}, env: "WAYLAND_DISPLAY"},
+		&CommandBackend{name: BackendXclip, args: []string{"xclip", "-selection", "clipboard"}, env: "DISPLAY"},
+		&CommandBackend{name: BackendXsel, args: []string{"xsel", "--clipboard", "--input"}, env: "DISPLAY"},
+		&CommandBackend{name: BackendPbcopy, args: []string{"pbcopy"}},
+		windowsBackend{},
+	}
+	c := &Clipboard{Fallback: &FileBackend{}, Warn: warn}
+
+	switch name {
+	case "", BackendAuto:
+		if os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != "" {
+			// The user's clipboard is on the other end of the connection
+			c.Backends = append([]Backend{osc52}, native...)
+		} else {
+			c.Backends = append(native, osc52)
+		}
+	case BackendFile:
+	default:
+		for _, b := range append(native, osc52) {
+			if b.Name() == name {
+				c.Backends = []Backend{b}
+			}
+		}
+		if c.Backends == nil {
+			return nil, fmt.Errorf("unknown clipboard backend %q (expected one of %s)", name, strings.Join(Backends, ", "))
+		}
+	}
+	return c, nil
+}
+
+// WriteAll copies text and returns the backend that received it: one of
+// Backends, or Fallback if none was available or succeeded
+func (c *Clipboard) WriteAll(text string) (Backend, error) {
+	for _, b := range c.Backends {
+		if !b.Available() {
+			continue
+		}
+		err := b.Copy(text)
+		if err == nil {
+			return b, nil
+		}
+		fmt.Fprintf(c.Warn, "⚠ Clipboard backend %s failed: %v\n", b.Name(), err)
+	}
+	if c.Fallback == nil {
+		return nil, errors.New("no clipboard backend available")
+	}
+	if err := c.Fallback.Copy(text); err != nil {
+		return nil, err
+	}
+	return c.Fallback, nil
+}
+
+// CommandBackend pipes text into a clipboard tool
+type CommandBackend struct {
+	name string
+	args []string
+	// env must be set (e.g. DISPLAY) for the tool to reach a clipboard
+	env string
+}
+
+// Name implements Backend
+func (b *CommandBackend) Name() string { return b.name }
+
+// Available implements Backend
+func (b *CommandBackend) Available() bool {
+	if b.env != "" && os.Getenv(b.env) == "" {
+		return false
+	}
+	_, err := exec.LookPath(b.args[0])
+	return err == nil
+}
+
+// Copy implements Backend. The tool's output is not captured: xclip and
+// wl-copy keep serving the selection in a child that inherits it.
+func (b *CommandBackend) Copy(text string) error {
+	cmd := exec.Command(b.args[0], b.args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+// OSC52 copies through the terminal with the OSC 52 escape sequence, which
+// works over SSH when the local terminal supports it
+type OSC52 struct {
+	// Out receives the sequence; nil writes to /dev/tty
+	Out io.Writer
+	// Tmux wraps the sequence in tmux's passthrough escape
+	Tmux bool
+	// Warn receives a warning when the sequence exceeds OSC52Limit
+	Warn io.Writer
+}
+
+// Name implements Backend
+func (o *OSC52) Name() string { return BackendOSC52 }
+
+// Available implements Backend
+func (o *OSC52) Available() bool {
+	if o.Out != nil {
+		return true
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return false
+	}
+	tty.Close()
+	return true
+}
+
+// Copy implements Backend. The terminal does not acknowledge the sequence,
+// so success means it was written.
+func (o *OSC52) Copy(text string) error {
+	seq := o.Sequence(text)
+	if len(seq) > OSC52Limit && o.Warn != nil {
+		fmt.Fprintf(o.Warn, "⚠ The OSC 52 sequence is %d bytes; terminals limiting it to about %d bytes may truncate or ignore it\n", len(seq), OSC52Limit)
+	}
+
+	out := o.Out
+	if out == nil {
+		tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer tty.Close()
+		out = tty
+	}
+	_, err := io.WriteString(out, seq)
+	return err
+}
 
-// NewClipboard creates a new Clipboard instance
-func NewClipboard() *Clipboard {
-	return &Clipboard{}
+// Sequence returns the escape sequence setting the clipboard to text
+func (o *OSC52) Sequence(text string) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if o.Tmux {
+		// tmux forwards DCS tmux; ... ST to the outer terminal, with
+		// inner escapes doubled
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	return seq
 }
 
-// WriteAll writes the string to the system clipboard
-func (c *Clipboard) WriteAll(text string) error {
-	return clipboard.WriteAll(text)
+// windowsBackend uses the Windows clipboard API
+type windowsBackend struct{}
+
+// Name implements Backend
+func (windowsBackend) Name() string { return BackendWindows }
+
+// Available implements Backend
+func (windowsBackend) Available() bool { return runtime.GOOS == "windows" }
+
+// Copy implements Backend
+func (windowsBackend) Copy(text string) error { return clipboard.WriteAll(text) }
+
+// FileBackend writes text to a new temporary file instead of a clipboard
+type FileBackend struct {
+	// Dir holds the file; empty uses os.TempDir
+	Dir string
+	// Path is the file written by the last Copy
+	Path string
+}
+
+// Name implements Backend
+func (f *FileBackend) Name() string { return BackendFile }
+
+// Available implements Backend
+func (f *FileBackend) Available() bool { return true }
+
+// Copy implements Backend
+func (f *FileBackend) Copy(text string) error {
+	file, err := os.CreateTemp(f.Dir, "concat-*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to save the output: %w", err)
+	}
+	if _, err := io.WriteString(file, text); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to save the output: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to save the output: %w", err)
+	}
+	f.Path = file.Name()
+	return nil
 }
@@ -0,0 +1,115 @@
+package infra
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeBackend records what it was asked to copy
+type fakeBackend struct {
+	name      string
+	available bool
+	err       error
+	copied    []string
+}
+
+func (f *fakeBackend) Name() string    { return f.name }
+func (f *fakeBackend) Available() bool { return f.available }
+func (f *fakeBackend) Copy(text string) error {
+	f.copied = append(f.copied, text)
+	return f.err
+}
+
+func TestClipboard_WriteAll(t *testing.T) {
+	missing := &fakeBackend{name: "missing"}
+	broken := &fakeBackend{name: "broken", available: true, err: errors.New("no display")}
+	working := &fakeBackend{name: "working", available: true}
+	var warn bytes.Buffer
+	c := &Clipboard{Backends: []Backend{missing, broken, working}, Fallback: &FileBackend{Dir: t.TempDir()}, Warn: &warn}
+
+	used, err := c.WriteAll("context")
+	if err != nil || used != working {
+		t.Fatalf("Expected the working backend, got %v, %v", used, err)
+	}
+	if len(missing.copied) != 0 || len(broken.copied) != 1 || len(working.copied) != 1 {
+		t.Errorf("Unexpected calls: missing %v, broken %v, working %v", missing.copied, broken.copied, working.copied)
+	}
+	if !strings.Contains(warn.String(), "broken failed: no display") {
+		t.Errorf("Expected a warning for the failed backend, got %q", warn.String())
+	}
+
+	// Nothing works: the text lands in a temporary file
+	c.Backends = []Backend{missing, broken}
+	used, err = c.WriteAll("context")
+	if err != nil || used != c.Fallback {
+		t.Fatalf("Expected the file fallback, got %v, %v", used, err)
+	}
+	if data, err := os.ReadFile(c.Fallback.Path); err != nil || string(data) != "context" {
+		t.Errorf("Unexpected fallback file %s: %q, %v", c.Fallback.Path, data, err)
+	}
+}
+
+func TestOSC52(t *testing.T) {
+	var out, warn bytes.Buffer
+	o := &OSC52{Out: &out, Warn: &warn}
+	if err := o.Copy("hi"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte("hi")) + "\a"; out.String() != want {
+		t.Errorf("got %q; want %q", out.String(), want)
+	}
+
+	o.Tmux = true
+	if seq := o.Sequence("hi"); seq != "\x1bPtmux;\x1b\x1b]52;c;aGk=\a\x1b\\" {
+		t.Errorf("Unexpected tmux passthrough %q", seq)
+	}
+
+	if warn.Len() != 0 {
+		t.Errorf("Unexpected warning %q", warn.String())
+	}
+	if err := o.Copy(strings.Repeat("x", OSC52Limit)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(warn.String(), "OSC 52 sequence is") {
+		t.Errorf("Expected a size warning, got %q", warn.String())
+	}
+}
+
+func TestNewClipboard(t *testing.T) {
+	t.Setenv("SSH_TTY", "")
+	t.Setenv("SSH_CONNECTION", "")
+	names := func(c *Clipboard) []string {
+		var names []string
+		for _, b := range c.Backends {
+			names = append(names, b.Name())
+		}
+		return names
+	}
+
+	c, err := NewClipboard(BackendAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(c); got[0] != BackendWlCopy || got[len(got)-1] != BackendOSC52 {
+		t.Errorf("Expected native tools before OSC 52 locally, got %v", got)
+	}
+
+	t.Setenv("SSH_TTY", "/dev/pts/1")
+	if c, _ := NewClipboard(BackendAuto); names(c)[0] != BackendOSC52 {
+		t.Errorf("Expected OSC 52 first over SSH, got %v", names(c))
+	}
+
+	if c, err := NewClipboard(BackendXsel); err != nil || strings.Join(names(c), ",") != BackendXsel {
+		t.Errorf("Expected only xsel, got %v, %v", c, err)
+	}
+	if c, err := NewClipboard(BackendFile); err != nil || len(c.Backends) != 0 || c.Fallback == nil {
+		t.Errorf("Expected only the file fallback, got %+v, %v", c, err)
+	}
+	if _, err := NewClipboard("clipper"); err == nil {
+		t.Error("Expected an unknown backend to be rejected")
+	}
+}
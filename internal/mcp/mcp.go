@@ -0,0 +1,245 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ProtocolVersion is the MCP revision this server implements
+const ProtocolVersion = "2024-11-05"
+
+// maxMessageSize bounds a single JSON-RPC message read from the client
+const maxMessageSize = 64 << 20
+
+// JSON-RPC error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// ToolHandler runs a tool with its raw JSON arguments and returns its text output.
+// Errors are reported to the client as tool results with isError set.
+type ToolHandler func(ctx context.Context, args json.RawMessage) (string, error)
+
+// Tool is a callable tool advertised by tools/list
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+	Handler     ToolHandler     `json:"-"`
+}
+
+// Resource is a readable item advertised by resources/list
+type Resource struct {
+	URI      string `json:"uri"`
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents is the text of a resource returned by resources/read
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+// ResourceProvider lists and reads the server's resources
+type ResourceProvider interface {
+	ListResources(ctx context.Context) ([]Resource, error)
+	ReadResource(ctx context.Context, uri string) (*ResourceContents, error)
+}
+
+// Server implements the Model Context Protocol over newline-delimited
+// JSON-RPC 2.0 messages (the stdio transport). Requests are handled in order.
+type Server struct {
+	name      string
+	version   string
+	tools     []Tool
+	byName    map[string]int
+	resources ResourceProvider
+	enc       *json.Encoder
+}
+
+// NewServer creates a Server reporting the given name and version
+func NewServer(name, version string) *Server {
+	return &Server{name: name, version: version, byName: make(map[string]int)}
+}
+
+// AddTool registers a tool
+func (s *Server) AddTool(t Tool) {
+	s.byName[t.Name] = len(s.tools)
+	s.tools = append(s.tools, t)
+}
+
+// SetResources registers the resource provider
+func (s *Server) SetResources(p ResourceProvider) {
+	s.resources = p
+}
+
+// message is an incoming JSON-RPC request or notification
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// Serve reads messages from r and writes responses to w until r is
+// exhausted or ctx is cancelled
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.enc = json.NewEncoder(w)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			s.send(response{ID: json.RawMessage("null"), Error: &rpcError{CodeParseError, "parse error: " + err.Error()}})
+			continue
+		}
+		if msg.JSONRPC != "2.0" || msg.Method == "" {
+			if msg.ID != nil {
+				s.send(response{ID: msg.ID, Error: &rpcError{CodeInvalidRequest, "invalid request"}})
+			}
+			continue
+		}
+
+		result, err := s.handle(ctx, &msg)
+		if msg.ID == nil {
+			continue // notifications get no response
+		}
+		if err != nil {
+			rpcErr, ok := err.(*rpcError)
+			if !ok {
+				rpcErr = &rpcError{CodeInternalError, err.Error()}
+			}
+			s.send(response{ID: msg.ID, Error: rpcErr})
+			continue
+		}
+		s.send(response{ID: msg.ID, Result: result})
+	}
+	return scanner.Err()
+}
+
+func (s *Server) send(resp response) {
+	resp.JSONRPC = "2.0"
+	s.enc.Encode(resp)
+}
+
+func (s *Server) handle(ctx context.Context, msg *message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		capabilities := map[string]any{"tools": map[string]any{}}
+		if s.resources != nil {
+			capabilities["resources"] = map[string]any{}
+		}
+		return map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    capabilities,
+			"serverInfo":      map[string]string{"name": s.name, "version": s.version},
+		}, nil
+
+	case "ping":
+		return map[string]any{}, nil
+
+	case "tools/list":
+		tools := s.tools
+		if tools == nil {
+			tools = []Tool{}
+		}
+		return map[string]any{"tools": tools}, nil
+
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{CodeInvalidParams, "invalid params: " + err.Error()}
+		}
+		i, ok := s.byName[params.Name]
+		if !ok {
+			return nil, &rpcError{CodeInvalidParams, fmt.Sprintf("unknown tool %q", params.Name)}
+		}
+		if len(params.Arguments) == 0 {
+			params.Arguments = json.RawMessage("{}")
+		}
+		text, err := s.tools[i].Handler(ctx, params.Arguments)
+		if err != nil {
+			return toolResult(err.Error(), true), nil
+		}
+		return toolResult(text, false), nil
+
+	case "resources/list":
+		if s.resources == nil {
+			return nil, &rpcError{CodeMethodNotFound, "method not found: " + msg.Method}
+		}
+		resources, err := s.resources.ListResources(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if resources == nil {
+			resources = []Resource{}
+		}
+		return map[string]any{"resources": resources}, nil
+
+	case "resources/read":
+		if s.resources == nil {
+			return nil, &rpcError{CodeMethodNotFound, "method not found: " + msg.Method}
+		}
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil || params.URI == "" {
+			return nil, &rpcError{CodeInvalidParams, "invalid params: uri is required"}
+		}
+		contents, err := s.resources.ReadResource(ctx, params.URI)
+		if err != nil {
+			return nil, &rpcError{CodeInvalidParams, err.Error()}
+		}
+		return map[string]any{"contents": []*ResourceContents{contents}}, nil
+	}
+
+	if msg.ID == nil {
+		return nil, nil // unknown notifications (e.g. notifications/initialized) are ignored
+	}
+	return nil, &rpcError{CodeMethodNotFound, "method not found: " + msg.Method}
+}
+
+func toolResult(text string, isError bool) map[string]any {
+	result := map[string]any{
+		"content": []map[string]string{{"type": "text", "text": text}},
+	}
+	if isError {
+		result["isError"] = true
+	}
+	return result
+}
@@ -0,0 +1,128 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type fakeResources struct{}
+
+func (fakeResources) ListResources(ctx context.Context) ([]Resource, error) {
+	return []Resource{{URI: "file:///a.go", Name: "a.go"}}, nil
+}
+
+func (fakeResources) ReadResource(ctx context.Context, uri string) (*ResourceContents, error) {
+	if uri != "file:///a.go" {
+		return nil, fmt.Errorf("not found: %s", uri)
+	}
+	return &ResourceContents{URI: uri, Text: "package a"}, nil
+}
+
+// run feeds script (one message per line) to a server and returns the
+// decoded responses in order
+func run(t *testing.T, srv *Server, script ...string) []map[string]any {
+	t.Helper()
+	var out bytes.Buffer
+	if err := srv.Serve(context.Background(), strings.NewReader(strings.Join(script, "\n")+"\n"), &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+
+	var responses []map[string]any
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var resp map[string]any
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("Invalid response: %v", err)
+		}
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+func newTestServer() *Server {
+	srv := NewServer("test", "1.0")
+	srv.AddTool(Tool{
+		Name:        "echo",
+		InputSchema: json.RawMessage(`{"type":"object"}`),
+		Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+			var a struct{ Text string }
+			json.Unmarshal(args, &a)
+			if a.Text == "" {
+				return "", errors.New("text is required")
+			}
+			return a.Text, nil
+		},
+	})
+	srv.SetResources(fakeResources{})
+	return srv
+}
+
+func TestServer_Session(t *testing.T) {
+	responses := run(t, newTestServer(),
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"t","version":"0"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"echo","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":"r","method":"resources/read","params":{"uri":"file:///a.go"}}`,
+	)
+	if len(responses) != 5 {
+		t.Fatalf("Expected 5 responses (none for the notification), got %d: %v", len(responses), responses)
+	}
+
+	init := responses[0]["result"].(map[string]any)
+	if init["protocolVersion"] != ProtocolVersion || init["serverInfo"].(map[string]any)["name"] != "test" {
+		t.Errorf("Unexpected initialize result: %v", init)
+	}
+	if _, ok := init["capabilities"].(map[string]any)["resources"]; !ok {
+		t.Errorf("Expected the resources capability: %v", init)
+	}
+
+	tools := responses[1]["result"].(map[string]any)["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["name"] != "echo" {
+		t.Errorf("Unexpected tools: %v", tools)
+	}
+
+	call := responses[2]["result"].(map[string]any)
+	if text := call["content"].([]any)[0].(map[string]any)["text"]; text != "hi" || call["isError"] != nil {
+		t.Errorf("Unexpected tool result: %v", call)
+	}
+	failed := responses[3]["result"].(map[string]any)
+	if failed["isError"] != true {
+		t.Errorf("Expected a tool error result: %v", failed)
+	}
+
+	if responses[4]["id"] != "r" {
+		t.Errorf("Expected the string id to be echoed: %v", responses[4])
+	}
+	contents := responses[4]["result"].(map[string]any)["contents"].([]any)
+	if contents[0].(map[string]any)["text"] != "package a" {
+		t.Errorf("Unexpected resource contents: %v", contents)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	responses := run(t, newTestServer(),
+		`not json`,
+		`{"jsonrpc":"2.0","id":1,"method":"nope"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"missing"}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"file:///b.go"}}`,
+		`{"id":4,"method":"ping"}`,
+	)
+
+	want := []float64{CodeParseError, CodeMethodNotFound, CodeInvalidParams, CodeInvalidParams, CodeInvalidRequest}
+	if len(responses) != len(want) {
+		t.Fatalf("Expected %d responses, got %d: %v", len(want), len(responses), responses)
+	}
+	for i, code := range want {
+		rpcErr, ok := responses[i]["error"].(map[string]any)
+		if !ok || rpcErr["code"] != code {
+			t.Errorf("Response %d: expected error code %v, got %v", i, code, responses[i])
+		}
+	}
+}
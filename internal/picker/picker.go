@@ -0,0 +1,461 @@
+package picker
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nessaee/concat/internal/core"
+)
+
+// Keys understood by HandleKey besides printable characters
+const (
+	KeyUp        = "up"
+	KeyDown      = "down"
+	KeyLeft      = "left"
+	KeyRight     = "right"
+	KeyPageUp    = "pgup"
+	KeyPageDown  = "pgdown"
+	KeyHome      = "home"
+	KeyEnd       = "end"
+	KeyEnter     = "enter"
+	KeyEsc       = "esc"
+	KeyBackspace = "backspace"
+	KeyTab       = "tab"
+	KeySpace     = "space"
+	KeyCtrlC     = "ctrl+c"
+)
+
+// help lists the key bindings on the first line of the view
+const help = "space select · ←/→ collapse/expand · / search · a all · s save · enter done · q quit"
+
+// pageSize is how far page up and page down move when the view height is
+// not yet known
+const pageSize = 10
+
+// SaveFunc persists the selection, returning where it was written
+type SaveFunc func(selected []string) (string, error)
+
+// Picker is the state of the file picker: a tree of the included files with
+// checkboxes, expanded directories, a search filter and the cursor. It is
+// driven by HandleKey and drawn by View, independently of the terminal.
+type Picker struct {
+	root      *core.TreeNode
+	checked   map[string]bool // file paths
+	expanded  map[string]bool // directory paths
+	rows      []row
+	cursor    int
+	offset    int
+	height    int // rows in the last view
+	query     string
+	searching bool
+	status    string
+	save      SaveFunc
+	done      bool
+	cancelled bool
+}
+
+// row is a visible line of the tree
+type row struct {
+	node  *core.TreeNode
+	depth int
+}
+
+// New creates a picker over the included files of root (as built by
+// core.TreeGenerator.Tree), with the given files already selected. The
+// directories leading to them start expanded; others start collapsed.
+func New(root *core.TreeNode, selected []string) *Picker {
+	p := &Picker{
+		root:     root,
+		checked:  make(map[string]bool),
+		expanded: map[string]bool{root.Path: true},
+	}
+	want := make(map[string]bool, len(selected))
+	for _, path := range selected {
+		want[path] = true
+	}
+	walkFiles(root, func(f *core.TreeNode, parents []*core.TreeNode) {
+		if want[f.Path] {
+			p.checked[f.Path] = true
+			for _, dir := range parents {
+				p.expanded[dir.Path] = true
+			}
+		}
+	})
+	p.refresh()
+	return p
+}
+
+// SetSave enables saving the selection with the s key
+func (p *Picker) SetSave(save SaveFunc) {
+	p.save = save
+}
+
+// Done reports whether the picker was confirmed or cancelled
+func (p *Picker) Done() bool {
+	return p.done
+}
+
+// Cancelled reports whether the picker was closed without confirming
+func (p *Picker) Cancelled() bool {
+	return p.cancelled
+}
+
+// Selected returns the selected files in tree order
+func (p *Picker) Selected() []string {
+	var selected []string
+	walkFiles(p.root, func(f *core.TreeNode, _ []*core.TreeNode) {
+		if p.checked[f.Path] {
+			selected = append(selected, f.Path)
+		}
+	})
+	return selected
+}
+
+// HandleKey applies a key press: one of the Key constants or a printable
+// character
+func (p *Picker) HandleKey(key string) {
+	p.status = ""
+	if key == KeyCtrlC {
+		p.done, p.cancelled = true, true
+		return
+	}
+	if p.searching {
+		p.handleSearchKey(key)
+		return
+	}
+
+	switch key {
+	case KeyUp, "k":
+		p.move(-1)
+	case KeyDown, "j":
+		p.move(1)
+	case KeyPageUp:
+		p.move(-p.page())
+	case KeyPageDown:
+		p.move(p.page())
+	case KeyHome, "g":
+		p.move(-len(p.rows))
+	case KeyEnd, "G":
+		p.move(len(p.rows))
+	case KeyRight, "l":
+		p.expand()
+	case KeyLeft, "h":
+		p.collapse()
+	case KeyTab:
+		if n := p.current(); n != nil && n.IsDir && p.query == "" {
+			p.expanded[n.Path] = !p.expanded[n.Path]
+			p.refresh()
+		}
+	case KeySpace, "x":
+		if n := p.current(); n != nil {
+			p.toggle(n)
+		}
+	case "a":
+		p.toggle(p.root)
+	case "/":
+		p.searching = true
+	case "s":
+		p.saveSelection()
+	case KeyEnter:
+		p.done = true
+	case KeyEsc:
+		if p.query != "" {
+			p.setQuery("")
+			return
+		}
+		p.done, p.cancelled = true, true
+	case "q":
+		p.done, p.cancelled = true, true
+	}
+}
+
+func (p *Picker) handleSearchKey(key string) {
+	switch key {
+	case KeyEnter:
+		p.searching = false
+	case KeyEsc:
+		p.searching = false
+		p.setQuery("")
+	case KeyBackspace:
+		if p.query != "" {
+			_, size := utf8.DecodeLastRuneInString(p.query)
+			p.setQuery(p.query[:len(p.query)-size])
+		}
+	case KeyUp:
+		p.move(-1)
+	case KeyDown:
+		p.move(1)
+	case KeySpace:
+		p.setQuery(p.query + " ")
+	default:
+		if utf8.RuneCountInString(key) == 1 {
+			p.setQuery(p.query + key)
+		}
+	}
+}
+
+// View draws the picker in at most height lines of width columns
+func (p *Picker) View(width, height int) string {
+	var sb strings.Builder
+	line := func(s string) {
+		sb.WriteString(truncate(s, width) + "\n")
+	}
+
+	line(help)
+	selected, tokens := p.totals(p.root)
+	summary := fmt.Sprintf("%d of %d files selected, ~%s tokens", selected, p.root.Files, formatCount(tokens))
+	switch {
+	case p.searching:
+		summary += " · search: " + p.query + "█"
+	case p.query != "":
+		summary += fmt.Sprintf(" · filter: %s (esc to clear)", p.query)
+	}
+	line(summary)
+	if p.status != "" {
+		line(p.status)
+	}
+
+	p.height = max(1, height-strings.Count(sb.String(), "\n"))
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	} else if p.cursor >= p.offset+p.height {
+		p.offset = p.cursor - p.height + 1
+	}
+	p.offset = max(0, min(p.offset, len(p.rows)-p.height))
+
+	if len(p.rows) == 0 {
+		line("  (no matching files)")
+	}
+	for i := p.offset; i < len(p.rows) && i < p.offset+p.height; i++ {
+		line(p.renderRow(i))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (p *Picker) renderRow(i int) string {
+	r := p.rows[i]
+	cursor := "  "
+	if i == p.cursor {
+		cursor = "> "
+	}
+	indent := strings.Repeat("  ", r.depth)
+
+	if !r.node.IsDir {
+		box := "[ ]"
+		if p.checked[r.node.Path] {
+			box = "[x]"
+		}
+		return fmt.Sprintf("%s%s  %s %s  ~%s tokens", cursor, indent, box, r.node.Name, formatCount(r.node.Size/4))
+	}
+
+	arrow := "▸"
+	if p.expanded[r.node.Path] || p.query != "" {
+		arrow = "▾"
+	}
+	files := p.files(r.node)
+	checked := 0
+	for _, f := range files {
+		if p.checked[f.Path] {
+			checked++
+		}
+	}
+	box := "[ ]"
+	switch {
+	case checked == len(files):
+		box = "[x]"
+	case checked > 0:
+		box = "[-]"
+	}
+	_, tokens := p.totals(r.node)
+	return fmt.Sprintf("%s%s%s %s %s/  %d/%d files, ~%s of %s tokens", cursor, indent, arrow, box, r.node.Name,
+		checked, len(files), formatCount(tokens), formatCount(r.node.Size/4))
+}
+
+// refresh rebuilds the visible rows, keeping the cursor on the same entry
+// when it is still shown
+func (p *Picker) refresh() {
+	var current string
+	if n := p.current(); n != nil {
+		current = n.Path
+	}
+
+	p.rows = p.rows[:0]
+	var add func(n *core.TreeNode, depth int)
+	add = func(n *core.TreeNode, depth int) {
+		for _, c := range n.Children {
+			if c.IsDir && len(p.files(c)) == 0 || !c.IsDir && !p.matches(c) {
+				continue
+			}
+			p.rows = append(p.rows, row{node: c, depth: depth})
+			if c.IsDir && (p.expanded[c.Path] || p.query != "") {
+				add(c, depth+1)
+			}
+		}
+	}
+	add(p.root, 0)
+
+	p.cursor = min(p.cursor, max(0, len(p.rows)-1))
+	for i, r := range p.rows {
+		if r.node.Path == current {
+			p.cursor = i
+			break
+		}
+	}
+}
+
+func (p *Picker) current() *core.TreeNode {
+	if p.cursor >= len(p.rows) {
+		return nil
+	}
+	return p.rows[p.cursor].node
+}
+
+func (p *Picker) move(delta int) {
+	p.cursor = max(0, min(p.cursor+delta, len(p.rows)-1))
+}
+
+func (p *Picker) page() int {
+	if p.height > 0 {
+		return p.height
+	}
+	return pageSize
+}
+
+// expand opens the directory under the cursor, or enters it if already open
+func (p *Picker) expand() {
+	n := p.current()
+	if n == nil || !n.IsDir {
+		return
+	}
+	if p.expanded[n.Path] || p.query != "" {
+		p.move(1)
+		return
+	}
+	p.expanded[n.Path] = true
+	p.refresh()
+}
+
+// collapse closes the directory under the cursor, or moves to the parent
+func (p *Picker) collapse() {
+	n := p.current()
+	if n == nil {
+		return
+	}
+	if n.IsDir && p.expanded[n.Path] && p.query == "" {
+		p.expanded[n.Path] = false
+		p.refresh()
+		return
+	}
+	depth := p.rows[p.cursor].depth
+	for i := p.cursor - 1; i >= 0; i-- {
+		if p.rows[i].depth < depth {
+			p.cursor = i
+			return
+		}
+	}
+}
+
+// toggle selects the matching files at or below n, or clears them if they
+// are all selected already
+func (p *Picker) toggle(n *core.TreeNode) {
+	files := p.files(n)
+	all := true
+	for _, f := range files {
+		all = all && p.checked[f.Path]
+	}
+	for _, f := range files {
+		if all {
+			delete(p.checked, f.Path)
+		} else {
+			p.checked[f.Path] = true
+		}
+	}
+}
+
+func (p *Picker) setQuery(query string) {
+	p.query = query
+	p.refresh()
+}
+
+func (p *Picker) saveSelection() {
+	if p.save == nil {
+		return
+	}
+	selected := p.Selected()
+	path, err := p.save(selected)
+	if err != nil {
+		p.status = fmt.Sprintf("⚠ Failed to save the selection: %v", err)
+		return
+	}
+	p.status = fmt.Sprintf("✓ Saved %d %s to %s", len(selected), core.Plural(len(selected), "file", "files"), path)
+}
+
+// files returns the included files at or below n that match the search
+func (p *Picker) files(n *core.TreeNode) []*core.TreeNode {
+	var files []*core.TreeNode
+	walkFiles(n, func(f *core.TreeNode, _ []*core.TreeNode) {
+		if p.matches(f) {
+			files = append(files, f)
+		}
+	})
+	return files
+}
+
+// matches reports whether a file matches the search (case-insensitive
+// substring of its path)
+func (p *Picker) matches(f *core.TreeNode) bool {
+	return f.Included && strings.Contains(strings.ToLower(f.Path), strings.ToLower(p.query))
+}
+
+// totals counts the selected files at or below n and their estimated tokens,
+// regardless of the search
+func (p *Picker) totals(n *core.TreeNode) (int, int64) {
+	count, tokens := 0, int64(0)
+	walkFiles(n, func(f *core.TreeNode, _ []*core.TreeNode) {
+		if p.checked[f.Path] {
+			count++
+			tokens += f.Size / 4
+		}
+	})
+	return count, tokens
+}
+
+// walkFiles calls fn for each included file at or below n, in tree order,
+// with the directories leading to it
+func walkFiles(n *core.TreeNode, fn func(f *core.TreeNode, parents []*core.TreeNode)) {
+	var walk func(n *core.TreeNode, parents []*core.TreeNode)
+	walk = func(n *core.TreeNode, parents []*core.TreeNode) {
+		if !n.IsDir {
+			if n.Included {
+				fn(n, parents)
+			}
+			return
+		}
+		parents = append(parents, n)
+		for _, c := range n.Children {
+			walk(c, parents)
+		}
+	}
+	walk(n, nil)
+}
+
+// truncate shortens s to width runes, marking the cut with an ellipsis
+func truncate(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
+
+// formatCount renders large counts compactly (e.g. 12.3k)
+func formatCount(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	}
+	return fmt.Sprintf("%d", n)
+}
@@ -0,0 +1,173 @@
+package picker
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nessaee/concat/internal/core"
+)
+
+// testTree builds a tree of files (with their sizes) like TreeGenerator.Tree
+func testTree(files map[string]int64) *core.TreeNode {
+	root := &core.TreeNode{Name: ".", Path: ".", IsDir: true}
+	for path, size := range files {
+		node := root
+		parts := strings.Split(path, "/")
+		for i, name := range parts {
+			node.Size += size
+			node.Files++
+			var child *core.TreeNode
+			for _, c := range node.Children {
+				if c.Name == name {
+					child = c
+				}
+			}
+			if child == nil {
+				child = &core.TreeNode{Name: name, Path: strings.Join(parts[:i+1], "/"), IsDir: i < len(parts)-1}
+				node.Children = append(node.Children, child)
+			}
+			node = child
+		}
+		node.Included, node.Size, node.Files = true, size, 1
+	}
+	var sortTree func(n *core.TreeNode)
+	sortTree = func(n *core.TreeNode) {
+		for i := 1; i < len(n.Children); i++ {
+			for j := i; j > 0 && n.Children[j].Name < n.Children[j-1].Name; j-- {
+				n.Children[j], n.Children[j-1] = n.Children[j-1], n.Children[j]
+			}
+		}
+		for _, c := range n.Children {
+			sortTree(c)
+		}
+	}
+	sortTree(root)
+	return root
+}
+
+func press(p *Picker, keys ...string) {
+	for _, k := range keys {
+		p.HandleKey(k)
+	}
+}
+
+func TestPicker_Select(t *testing.T) {
+	tree := testTree(map[string]int64{
+		"main.go":          400,
+		"pkg/util.go":      800,
+		"pkg/util_test.go": 400,
+		"web/app.js":       4000,
+	})
+	p := New(tree, nil)
+
+	// Rows: main.go, pkg/, web/ (directories start collapsed)
+	press(p, KeySpace, KeyDown, KeyRight)
+	if view := p.View(120, 20); !strings.Contains(view, "1 of 4 files selected, ~100 tokens") || !strings.Contains(view, "> ▾ [ ] pkg/") {
+		t.Errorf("Unexpected view:\n%s", view)
+	}
+
+	// Selecting a directory selects all of its files
+	press(p, KeySpace)
+	if got, want := p.Selected(), []string{"main.go", "pkg/util.go", "pkg/util_test.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	press(p, KeyDown, KeySpace, KeyUp)
+	if view := p.View(120, 20); !strings.Contains(view, "[-] pkg/  1/2 files, ~100 of 300 tokens") {
+		t.Errorf("Expected a partially selected directory:\n%s", view)
+	}
+
+	press(p, "a")
+	if got := len(p.Selected()); got != 4 {
+		t.Errorf("Expected all files after a, got %d", got)
+	}
+	press(p, KeyEnter)
+	if !p.Done() || p.Cancelled() {
+		t.Error("Expected enter to confirm")
+	}
+}
+
+func TestPicker_Search(t *testing.T) {
+	tree := testTree(map[string]int64{
+		"main.go":          400,
+		"pkg/util.go":      800,
+		"pkg/util_test.go": 400,
+		"web/app.js":       4000,
+	})
+	p := New(tree, nil)
+
+	// Matching files are shown inside their (expanded) directories, and
+	// selecting applies only to them
+	press(p, "/", "t", "e", "s", "t", KeyEnter, "a")
+	if got, want := p.Selected(), []string{"pkg/util_test.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	view := p.View(120, 20)
+	if !strings.Contains(view, "filter: test") || !strings.Contains(view, "util_test.go") || strings.Contains(view, "main.go") {
+		t.Errorf("Unexpected filtered view:\n%s", view)
+	}
+
+	// Escape clears the filter before it quits
+	press(p, KeyEsc)
+	if p.Done() || !strings.Contains(p.View(120, 20), "main.go") {
+		t.Error("Expected escape to clear the filter")
+	}
+	press(p, "q")
+	if !p.Cancelled() {
+		t.Error("Expected q to cancel")
+	}
+}
+
+func TestPicker_Saved(t *testing.T) {
+	tree := testTree(map[string]int64{"main.go": 4, "pkg/util.go": 4})
+	p := New(tree, []string{"pkg/util.go", "removed.go"})
+
+	// The saved file's directory starts expanded
+	if view := p.View(120, 20); !strings.Contains(view, "[x] util.go") {
+		t.Errorf("Expected the saved file to be selected and shown:\n%s", view)
+	}
+
+	var saved []string
+	p.SetSave(func(selected []string) (string, error) {
+		saved = selected
+		return ".concat/config.json", nil
+	})
+	press(p, KeySpace, "s")
+	if want := []string{"main.go", "pkg/util.go"}; !reflect.DeepEqual(saved, want) {
+		t.Errorf("Expected %v to be saved, got %v", want, saved)
+	}
+	if view := p.View(120, 20); !strings.Contains(view, "✓ Saved 2 files to .concat/config.json") {
+		t.Errorf("Expected a confirmation:\n%s", view)
+	}
+}
+
+func TestPicker_Scroll(t *testing.T) {
+	files := make(map[string]int64)
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		files[name+".go"] = 4
+	}
+	p := New(testTree(files), nil)
+
+	press(p, KeyEnd)
+	view := p.View(40, 5)
+	if lines := strings.Split(view, "\n"); len(lines) != 5 || lines[4] != ">   [ ] h.go  ~1 tokens" || strings.Contains(view, "a.go") {
+		t.Errorf("Expected the view to follow the cursor:\n%s", view)
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\x1b[A\x1b[6~j é\r\x7f\x03"))
+	var keys []string
+	for range 8 {
+		key, err := readKey(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	want := []string{KeyUp, KeyPageDown, "j", KeySpace, "é", KeyEnter, KeyBackspace, KeyCtrlC}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("Expected %q, got %q", want, keys)
+	}
+}
@@ -0,0 +1,138 @@
+package picker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Terminal control sequences
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l" // alternate screen, hidden cursor
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	clearScreen = "\x1b[H\x1b[2J"
+)
+
+// Run shows p on the terminal until the selection is confirmed or cancelled,
+// reading keys from in and drawing to out. It reports whether the selection
+// was confirmed.
+func Run(p *Picker, in *os.File, out io.Writer) (bool, error) {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return false, errors.New("the picker needs an interactive terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return false, fmt.Errorf("failed to configure the terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	fmt.Fprint(out, enterScreen)
+	defer fmt.Fprint(out, leaveScreen)
+
+	r := bufio.NewReader(in)
+	for !p.Done() {
+		width, height, err := term.GetSize(fd)
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		// Raw mode disables newline translation
+		fmt.Fprint(out, clearScreen+strings.ReplaceAll(p.View(width, height), "\n", "\r\n"))
+
+		key, err := readKey(r)
+		if err != nil {
+			return false, err
+		}
+		p.HandleKey(key)
+	}
+	return !p.Cancelled(), nil
+}
+
+// readKey reads one key press from a terminal in raw mode, returning a Key
+// constant, a printable character, or "" for keys the picker ignores
+func readKey(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	switch b {
+	case 3:
+		return KeyCtrlC, nil
+	case '\r', '\n':
+		return KeyEnter, nil
+	case '\t':
+		return KeyTab, nil
+	case 8, 127:
+		return KeyBackspace, nil
+	case ' ':
+		return KeySpace, nil
+	case 0x1b:
+		return readEscape(r)
+	}
+	if b < 0x20 {
+		return "", nil
+	}
+
+	if err := r.UnreadByte(); err != nil {
+		return "", err
+	}
+	ch, _, err := r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	return string(ch), nil
+}
+
+// readEscape decodes the rest of an escape sequence. A lone escape (nothing
+// else buffered) is the escape key itself.
+func readEscape(r *bufio.Reader) (string, error) {
+	if r.Buffered() == 0 {
+		return KeyEsc, nil
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if b != '[' && b != 'O' {
+		return "", nil
+	}
+
+	// CSI parameters end with a byte in 0x40-0x7e
+	var seq []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		seq = append(seq, b)
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+	}
+
+	switch string(seq) {
+	case "A":
+		return KeyUp, nil
+	case "B":
+		return KeyDown, nil
+	case "C":
+		return KeyRight, nil
+	case "D":
+		return KeyLeft, nil
+	case "H", "1~", "7~":
+		return KeyHome, nil
+	case "F", "4~", "8~":
+		return KeyEnd, nil
+	case "5~":
+		return KeyPageUp, nil
+	case "6~":
+		return KeyPageDown, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,158 @@
+package pricing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultModel prices estimates when no model is selected
+const DefaultModel = "gemini-2.5-flash"
+
+// Model is the pricing (USD per million tokens) and context window of an LLM
+type Model struct {
+	Name          string  `json:"name"`
+	InputPerMTok  float64 `json:"inputPerMTok"`
+	OutputPerMTok float64 `json:"outputPerMTok"`
+	ContextWindow int64   `json:"contextWindow"`
+}
+
+// builtin is the default table. Prices change; override them with a
+// pricing file rather than relying on these.
+var builtin = []Model{
+	{Name: "gemini-2.5-flash", InputPerMTok: 0.30, OutputPerMTok: 2.50, ContextWindow: 1_048_576},
+	{Name: "gemini-2.5-pro", InputPerMTok: 1.25, OutputPerMTok: 10, ContextWindow: 1_048_576},
+	{Name: "claude-haiku-3.5", InputPerMTok: 0.80, OutputPerMTok: 4, ContextWindow: 200_000},
+	{Name: "claude-sonnet-4", InputPerMTok: 3, OutputPerMTok: 15, ContextWindow: 200_000},
+	{Name: "claude-opus-4", InputPerMTok: 15, OutputPerMTok: 75, ContextWindow: 200_000},
+	{Name: "gpt-4o-mini", InputPerMTok: 0.15, OutputPerMTok: 0.60, ContextWindow: 128_000},
+	{Name: "gpt-4o", InputPerMTok: 2.50, OutputPerMTok: 10, ContextWindow: 128_000},
+	{Name: "gpt-4.1-mini", InputPerMTok: 0.40, OutputPerMTok: 1.60, ContextWindow: 1_047_576},
+	{Name: "gpt-4.1", InputPerMTok: 2, OutputPerMTok: 8, ContextWindow: 1_047_576},
+}
+
+// Table is an ordered set of models
+type Table struct {
+	models []Model
+}
+
+// Default returns the built-in table
+func Default() *Table {
+	return &Table{models: append([]Model(nil), builtin...)}
+}
+
+// UserFile returns the location of the user's pricing file
+// (e.g. ~/.config/concat/pricing.json)
+func UserFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "concat", "pricing.json"), nil
+}
+
+// Load returns the built-in table overridden by the pricing file at path.
+// With an empty path, the user's pricing file is used if it exists.
+func Load(path string) (*Table, error) {
+	t := Default()
+	if path == "" {
+		userFile, err := UserFile()
+		if err != nil {
+			return t, nil
+		}
+		if _, err := os.Stat(userFile); errors.Is(err, fs.ErrNotExist) {
+			return t, nil
+		}
+		path = userFile
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var models []Model
+	if err := json.Unmarshal(data, &models); err != nil {
+		return nil, fmt.Errorf("invalid pricing file %s: %w", path, err)
+	}
+	for _, m := range models {
+		if m.Name == "" || m.InputPerMTok < 0 || m.OutputPerMTok < 0 || m.ContextWindow < 0 {
+			return nil, fmt.Errorf("invalid pricing file %s: each model needs a name and non-negative prices", path)
+		}
+	}
+	t.Merge(models)
+	return t, nil
+}
+
+// Merge replaces models with the same name and appends new ones
+func (t *Table) Merge(models []Model) {
+	for _, m := range models {
+		if i := t.index(m.Name); i >= 0 {
+			t.models[i] = m
+		} else {
+			t.models = append(t.models, m)
+		}
+	}
+}
+
+// Lookup finds a model by name (case-insensitive)
+func (t *Table) Lookup(name string) (Model, error) {
+	if i := t.index(name); i >= 0 {
+		return t.models[i], nil
+	}
+	return Model{}, fmt.Errorf("unknown model %q (expected one of %s)", name, strings.Join(t.Names(), ", "))
+}
+
+// Models returns the models in table order
+func (t *Table) Models() []Model {
+	return t.models
+}
+
+// Names returns the model names in table order
+func (t *Table) Names() []string {
+	names := make([]string, len(t.models))
+	for i, m := range t.models {
+		names[i] = m.Name
+	}
+	return names
+}
+
+func (t *Table) index(name string) int {
+	for i, m := range t.models {
+		if strings.EqualFold(m.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Estimate is the cost of sending a bundle to one model
+type Estimate struct {
+	Model         string  `json:"model"`
+	Tokens        int64   `json:"tokens"`
+	InputCost     float64 `json:"inputCost"`
+	OutputPerMTok float64 `json:"outputPerMTok"`
+	ContextWindow int64   `json:"contextWindow"`
+	ContextUsed   float64 `json:"contextUsed"` // percent of the window
+	Fits          bool    `json:"fits"`
+}
+
+// Estimate prices tokens of input. A zero context window is unlimited.
+func (m Model) Estimate(tokens int64) Estimate {
+	e := Estimate{
+		Model:         m.Name,
+		Tokens:        tokens,
+		InputCost:     float64(tokens) * m.InputPerMTok / 1_000_000,
+		OutputPerMTok: m.OutputPerMTok,
+		ContextWindow: m.ContextWindow,
+		Fits:          true,
+	}
+	if m.ContextWindow > 0 {
+		e.ContextUsed = float64(tokens) * 100 / float64(m.ContextWindow)
+		e.Fits = tokens <= m.ContextWindow
+	}
+	return e
+}
@@ -0,0 +1,62 @@
+package pricing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModel_Estimate(t *testing.T) {
+	m := Model{Name: "m", InputPerMTok: 2, OutputPerMTok: 8, ContextWindow: 100_000}
+
+	e := m.Estimate(50_000)
+	if e.InputCost != 0.1 || e.ContextUsed != 50 || !e.Fits {
+		t.Errorf("Unexpected estimate: %+v", e)
+	}
+	if e := m.Estimate(100_001); e.Fits {
+		t.Errorf("Expected an oversized bundle not to fit: %+v", e)
+	}
+	if e := (Model{Name: "unlimited"}).Estimate(1 << 40); !e.Fits || e.ContextUsed != 0 {
+		t.Errorf("Expected no context limit: %+v", e)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.json")
+	override := `[
+		{"name": "GPT-4o", "inputPerMTok": 1, "outputPerMTok": 2, "contextWindow": 1000},
+		{"name": "local", "inputPerMTok": 0, "outputPerMTok": 0, "contextWindow": 32000}
+	]`
+	if err := os.WriteFile(path, []byte(override), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(table.Models()) != len(builtin)+1 {
+		t.Errorf("Expected one model to be replaced and one added, got %v", table.Names())
+	}
+	if m, err := table.Lookup("gpt-4o"); err != nil || m.InputPerMTok != 1 || m.ContextWindow != 1000 {
+		t.Errorf("Expected the override to replace gpt-4o, got %+v: %v", m, err)
+	}
+	if _, err := table.Lookup("local"); err != nil {
+		t.Errorf("Expected the added model: %v", err)
+	}
+	if _, err := table.Lookup(DefaultModel); err != nil {
+		t.Errorf("Expected the default model to remain: %v", err)
+	}
+	if _, err := table.Lookup("missing"); err == nil {
+		t.Error("Expected an error for an unknown model")
+	}
+
+	for _, bad := range []string{`{"name": "x"}`, `[{"inputPerMTok": 1}]`, `[{"name": "x", "inputPerMTok": -1}]`} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("Expected %s to be rejected", bad)
+		}
+	}
+}
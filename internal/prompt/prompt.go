@@ -0,0 +1,114 @@
+package prompt
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/nessaee/concat/internal/config"
+)
+
+// Dir holds the project's prompt templates, relative to the project root
+// (slash-separated). They take precedence over the built-in templates of the
+// same name.
+var Dir = path.Join(config.ProjectDir, "prompts")
+
+// Ext is the extension of prompt templates
+const Ext = ".md"
+
+//go:embed prompts/*.md
+var builtin embed.FS
+
+// validName restricts template names to file names within Dir
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// body is substituted for {{.Body}} to find where the files go
+const body = "\x00body\x00"
+
+// Vars are the variables available to templates
+type Vars struct {
+	// Project is the project name from the header
+	Project string
+	// Files lists the files emitted, relative to the project root
+	Files []string
+	// DiffBase is the revision a change is reviewed against (--diff-base)
+	DiffBase string
+}
+
+// data is what templates execute against: Vars plus the body placeholder
+type data struct {
+	Vars
+	Body string
+}
+
+// Load returns the text of the named template, from the project in fsys or
+// the built-in library
+func Load(fsys fs.FS, name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("invalid prompt name %q", name)
+	}
+	text, err := fs.ReadFile(fsys, path.Join(Dir, name+Ext))
+	if errors.Is(err, fs.ErrNotExist) {
+		text, err = builtin.ReadFile("prompts/" + name + Ext)
+		if errors.Is(err, fs.ErrNotExist) {
+			names, _ := Names(fsys)
+			return "", fmt.Errorf("prompt %q not found (expected one of %s)", name, strings.Join(names, ", "))
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
+// Names returns the names of the built-in and project templates, sorted
+func Names(fsys fs.FS) ([]string, error) {
+	seen := make(map[string]bool)
+	entries, err := builtin.ReadDir("prompts")
+	if err != nil {
+		return nil, err
+	}
+	project, err := fs.ReadDir(fsys, Dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	var names []string
+	for _, e := range append(entries, project...) {
+		if name, ok := strings.CutSuffix(e.Name(), Ext); ok && !e.IsDir() && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Render executes a template (text/template syntax) and splits its output
+// at {{.Body}} into the text before and after the files. Without {{.Body}},
+// all of it precedes the files.
+func Render(text string, vars Vars) (prefix, suffix string, err error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data{Vars: vars, Body: body}); err != nil {
+		return "", "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+
+	out := sb.String()
+	switch strings.Count(out, body) {
+	case 0:
+		return strings.TrimSpace(out), "", nil
+	case 1:
+		prefix, suffix, _ = strings.Cut(out, body)
+		return strings.TrimSpace(prefix), strings.TrimSpace(suffix), nil
+	}
+	return "", "", errors.New("invalid prompt template: {{.Body}} appears more than once")
+}
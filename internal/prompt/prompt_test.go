@@ -0,0 +1,76 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	vars := Vars{Project: "demo", Files: []string{"a.go", "b/c.go"}, DiffBase: "main"}
+
+	prefix, suffix, err := Render("Review {{.Project}} since {{.DiffBase}}:\n{{range .Files}}{{.}} {{end}}\n\n{{.Body}}\n\nAnything wrong?\n", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != "Review demo since main:\na.go b/c.go" || suffix != "Anything wrong?" {
+		t.Errorf("Unexpected split %q / %q", prefix, suffix)
+	}
+
+	if prefix, suffix, err := Render("Just instructions.\n", vars); err != nil || prefix != "Just instructions." || suffix != "" {
+		t.Errorf("Expected the whole text before the files, got %q / %q, %v", prefix, suffix, err)
+	}
+	for _, bad := range []string{"{{.Body}}{{.Body}}", "{{.Missing}}", "{{if}}"} {
+		if _, _, err := Render(bad, vars); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	names, err := Names(os.DirFS(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"explain", "refactor", "review", "write-tests"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Expected the built-in templates %v, got %v", want, names)
+	}
+	for _, name := range names {
+		text, err := Load(os.DirFS(t.TempDir()), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prefix, suffix, err := Render(text, Vars{Project: "demo", Files: []string{"a.go"}})
+		if err != nil || !strings.Contains(prefix, "demo") || suffix == "" {
+			t.Errorf("%s: unexpected rendering %q / %q, %v", name, prefix, suffix, err)
+		}
+	}
+}
+
+func TestLoad_Project(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, Dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{"review": "Our review checklist.", "triage": "Triage this."} {
+		if err := os.WriteFile(filepath.Join(dir, name+Ext), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if text, err := Load(os.DirFS(root), "review"); err != nil || text != "Our review checklist." {
+		t.Errorf("Expected the project template to override the built-in one, got %q, %v", text, err)
+	}
+	if names, _ := Names(os.DirFS(root)); !reflect.DeepEqual(names, []string{"explain", "refactor", "review", "triage", "write-tests"}) {
+		t.Errorf("Unexpected names %v", names)
+	}
+	if _, err := Load(os.DirFS(root), "other"); err == nil || !strings.Contains(err.Error(), "triage") {
+		t.Errorf("Expected the available prompts to be listed, got %v", err)
+	}
+	if _, err := Load(os.DirFS(root), "../escape"); err == nil {
+		t.Error("Expected names with path separators to be rejected")
+	}
+}
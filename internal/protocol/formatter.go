@@ -8,7 +8,14 @@ import (
 // Formatter defines the interface for output formatting
 type Formatter interface {
 	WriteHeader(w io.Writer, path string)
+	// WritePartialHeader opens a block holding lines start to end of a file
+	WritePartialHeader(w io.Writer, path string, start, end int)
 	WriteFooter(w io.Writer)
+	// WriteSection writes a named non-file block (e.g. the repo map)
+	WriteSection(w io.Writer, name string, content string)
+	// WriteBreakpoint marks the end of a prefix that prompt caches can reuse.
+	// Formats without such a marker write nothing.
+	WriteBreakpoint(w io.Writer)
 }
 
 // MarkdownFormatter implements Formatter for Markdown output
@@ -18,10 +25,20 @@ func (f *MarkdownFormatter) WriteHeader(w io.Writer, path string) {
 	fmt.Fprintf(w, MarkerMD+"\n", path)
 }
 
+func (f *MarkdownFormatter) WritePartialHeader(w io.Writer, path string, start, end int) {
+	fmt.Fprintf(w, MarkerPartialMD+"\n", path, start, end)
+}
+
 func (f *MarkdownFormatter) WriteFooter(w io.Writer) {
 	fmt.Fprint(w, "\n\n---\n\n")
 }
 
+func (f *MarkdownFormatter) WriteSection(w io.Writer, name string, content string) {
+	fmt.Fprintf(w, MarkerSectionMD+"\n%s\n---\n\n", name, content)
+}
+
+func (f *MarkdownFormatter) WriteBreakpoint(w io.Writer) {}
+
 // XMLFormatter implements Formatter for XML output
 type XMLFormatter struct{}
 
@@ -29,6 +46,18 @@ func (f *XMLFormatter) WriteHeader(w io.Writer, path string) {
 	fmt.Fprintf(w, MarkerXMLStart+"\n", path)
 }
 
+func (f *XMLFormatter) WritePartialHeader(w io.Writer, path string, start, end int) {
+	fmt.Fprintf(w, MarkerPartialXMLStart+"\n", path, start, end)
+}
+
 func (f *XMLFormatter) WriteFooter(w io.Writer) {
 	fmt.Fprintf(w, "\n"+MarkerXMLEnd+"\n")
 }
+
+func (f *XMLFormatter) WriteSection(w io.Writer, name string, content string) {
+	fmt.Fprintf(w, MarkerSectionXMLStart+"\n%s"+MarkerSectionXMLEnd+"\n", name, content)
+}
+
+func (f *XMLFormatter) WriteBreakpoint(w io.Writer) {
+	fmt.Fprint(w, MarkerBreakpointXML+"\n")
+}
@@ -8,6 +8,21 @@ const (
 	// MarkerXML is the XML header format (simplified for regex matching logic)
 	MarkerXMLStart = `<file path="%s">`
 	MarkerXMLEnd   = `</file>`
+
+	// MarkerPartialMD heads a block holding only some lines of a file
+	MarkerPartialMD = "### File: %s (lines %d-%d) ###"
+	// MarkerPartialXMLStart opens a block holding only some lines of a file
+	MarkerPartialXMLStart = `<file path="%s" lines="%d-%d">`
+
+	// MarkerSectionMD heads non-file blocks such as the repo map
+	MarkerSectionMD = "### %s ###"
+	// MarkerSectionXMLStart opens non-file blocks such as the repo map
+	MarkerSectionXMLStart = `<section name="%s">`
+	MarkerSectionXMLEnd   = `</section>`
+
+	// MarkerBreakpointXML separates the stable prefix of a document, which
+	// prompt caches can reuse, from the content that changes between runs
+	MarkerBreakpointXML = `<cache_breakpoint/>`
 )
 
 // FormatHeaderMD returns the formatted markdown header
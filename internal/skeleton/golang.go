@@ -0,0 +1,48 @@
+package skeleton
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+)
+
+// GoOutliner outlines Go source using go/parser. Package clauses, imports,
+// type, const and var declarations and doc comments are kept verbatim;
+// function and method bodies are replaced with Elided.
+type GoOutliner struct{}
+
+// Outline implements Outliner
+func (GoOutliner) Outline(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect body spans, then splice them out back to front
+	type span struct{ start, end int }
+	var bodies []span
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		bodies = append(bodies, span{
+			start: fset.Position(fn.Body.Lbrace).Offset,
+			end:   fset.Position(fn.Body.Rbrace).Offset + 1,
+		})
+	}
+	sort.Slice(bodies, func(i, j int) bool { return bodies[i].start < bodies[j].start })
+
+	var out bytes.Buffer
+	last := 0
+	for _, b := range bodies {
+		out.Write(src[last:b.start])
+		out.WriteString(Elided)
+		last = b.end
+	}
+	out.Write(src[last:])
+	return out.Bytes(), nil
+}
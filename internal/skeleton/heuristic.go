@@ -0,0 +1,273 @@
+package skeleton
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+var (
+	// reContainer matches block headers whose bodies hold declarations
+	reContainer = regexp.MustCompile(`\b(class|interface|struct|enum|union|namespace|impl|trait|module|mod|object|extension|record|protocol)\b`)
+	// reControl matches block headers of control flow statements
+	reControl = regexp.MustCompile(`^(\}\s*)?(if|else|for|foreach|while|do|switch|match|catch|try|finally|synchronized|with|loop|unsafe|using|lock)\b`)
+	// reFunction matches block headers that introduce a function body
+	reFunction = regexp.MustCompile(`\)|=>|\b(fn|func|function|get|set|init|deinit|constructor)\b`)
+	// reComments strips comments from a block header before classification
+	reComments = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/`)
+)
+
+// BraceOutliner outlines C-family languages (JS/TS, Java, Rust, C/C++, C#, ...)
+// by scanning braces. Blocks that look like function bodies are replaced with
+// Elided; class-like and other blocks are kept and scanned for nested functions.
+// String literals and comments are skipped so their braces don't confuse the scan.
+type BraceOutliner struct{}
+
+// Outline implements Outliner
+func (BraceOutliner) Outline(src []byte) ([]byte, error) {
+	var out bytes.Buffer
+	s := newScanner(src)
+	last := 0        // start of the not-yet-written input
+	headerStart := 0 // start of the statement preceding the next '{'
+
+	for s.pos < len(src) {
+		c, code := s.next()
+		if !code {
+			continue
+		}
+		switch c {
+		case '(':
+			s.parens++
+		case ')':
+			if s.parens > 0 {
+				s.parens--
+			}
+		case ';', '}':
+			if s.parens == 0 {
+				headerStart = s.pos
+			}
+		case '{':
+			if s.parens > 0 {
+				continue
+			}
+			open := s.pos - 1
+			if !isFunctionHeader(string(src[headerStart:open])) {
+				headerStart = s.pos
+				continue
+			}
+			end := s.skipBlock()
+			out.Write(src[last:open])
+			out.WriteString(Elided)
+			last = end
+			headerStart = end
+		}
+	}
+	out.Write(src[last:])
+	return out.Bytes(), nil
+}
+
+// isFunctionHeader classifies the text preceding a '{'
+func isFunctionHeader(header string) bool {
+	header = strings.TrimSpace(reComments.ReplaceAllString(header, ""))
+	// Only the last line-joined statement matters (e.g. after a decorator)
+	header = strings.Join(strings.Fields(header), " ")
+	if header == "" || reControl.MatchString(header) {
+		return false
+	}
+	// "class Foo(Base) {" or "impl Foo {": descend rather than elide
+	if loc := reContainer.FindStringIndex(header); loc != nil && !strings.Contains(header[:loc[0]], "(") {
+		return false
+	}
+	return reFunction.MatchString(header)
+}
+
+// scanner walks source text, reporting whether each byte is code
+// (as opposed to a string literal or comment)
+type scanner struct {
+	src    []byte
+	pos    int
+	parens int
+}
+
+func newScanner(src []byte) *scanner {
+	return &scanner{src: src}
+}
+
+// next returns the next byte and whether it is code. Strings and comments
+// are consumed whole and reported as a single non-code step.
+func (s *scanner) next() (byte, bool) {
+	src := s.src
+	c := src[s.pos]
+	s.pos++
+
+	switch {
+	case c == '/' && s.pos < len(src) && src[s.pos] == '/':
+		for s.pos < len(src) && src[s.pos] != '\n' {
+			s.pos++
+		}
+		return c, false
+	case c == '/' && s.pos < len(src) && src[s.pos] == '*':
+		end := bytes.Index(src[s.pos+1:], []byte("*/"))
+		if end < 0 {
+			s.pos = len(src)
+		} else {
+			s.pos += end + 3
+		}
+		return c, false
+	case c == '"' || c == '`':
+		s.skipString(c)
+		return c, false
+	case c == '\'':
+		// Rust lifetimes and generics ('a) have no closing quote on the line
+		eol := bytes.IndexByte(src[s.pos:], '\n')
+		if eol < 0 {
+			eol = len(src) - s.pos
+		}
+		if bytes.IndexByte(src[s.pos:s.pos+eol], '\'') < 0 {
+			return c, true
+		}
+		s.skipString(c)
+		return c, false
+	}
+	return c, true
+}
+
+func (s *scanner) skipString(quote byte) {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		s.pos++
+		if c == '\\' {
+			s.pos++
+			continue
+		}
+		if c == quote || (c == '\n' && quote != '`') {
+			return
+		}
+	}
+}
+
+// skipBlock consumes input up to and including the '}' matching an already
+// consumed '{', returning the position after it
+func (s *scanner) skipBlock() int {
+	depth := 1
+	for s.pos < len(s.src) {
+		c, code := s.next()
+		if !code {
+			continue
+		}
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return s.pos
+			}
+		}
+	}
+	return len(s.src)
+}
+
+var (
+	reDef       = regexp.MustCompile(`^(async\s+)?def\s`)
+	reDocstring = regexp.MustCompile(`^[rRbBuU]?("""|''')`)
+)
+
+// IndentOutliner outlines indentation-scoped languages (Python). Function
+// bodies are replaced with "...", keeping signatures, decorators, classes,
+// docstrings and module-level statements.
+type IndentOutliner struct{}
+
+// Outline implements Outliner
+func (IndentOutliner) Outline(src []byte) ([]byte, error) {
+	lines := strings.SplitAfter(string(src), "\n")
+	var out strings.Builder
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if !reDef.MatchString(trimmed) {
+			out.WriteString(line)
+			continue
+		}
+
+		indent := indentOf(line)
+
+		// Emit the signature, which may span lines
+		depth := 0
+		for ; i < len(lines); i++ {
+			out.WriteString(lines[i])
+			code := lines[i]
+			if idx := strings.Index(code, "#"); idx >= 0 {
+				code = code[:idx]
+			}
+			depth += strings.Count(code, "(") + strings.Count(code, "[") - strings.Count(code, ")") - strings.Count(code, "]")
+			if depth <= 0 && strings.HasSuffix(strings.TrimSpace(code), ":") {
+				break
+			}
+		}
+
+		// Find the body; one-line definitions ("def f(): pass") have none
+		j := i + 1
+		for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+			j++
+		}
+		if j >= len(lines) || indentOf(lines[j]) <= indent {
+			continue
+		}
+		bodyIndent := lines[j][:len(lines[j])-len(strings.TrimLeft(lines[j], " \t"))]
+
+		// Keep the docstring
+		if m := reDocstring.FindStringSubmatch(strings.TrimSpace(lines[j])); m != nil {
+			quote := m[1]
+			rest := strings.TrimSpace(lines[j])[len(m[0]):]
+			out.WriteString(lines[j])
+			if !strings.Contains(rest, quote) {
+				for j++; j < len(lines); j++ {
+					out.WriteString(lines[j])
+					if strings.Contains(lines[j], quote) {
+						break
+					}
+				}
+			}
+			j++
+		}
+		out.WriteString(bodyIndent + "...\n")
+
+		// Skip the rest of the body, preserving one separating blank line
+		blank := false
+		for j < len(lines) {
+			if strings.TrimSpace(lines[j]) == "" {
+				blank = true
+				j++
+				continue
+			}
+			if indentOf(lines[j]) <= indent {
+				break
+			}
+			blank = false
+			j++
+		}
+		if blank {
+			out.WriteString("\n")
+		}
+		i = j - 1
+	}
+	return []byte(out.String()), nil
+}
+
+// indentOf returns the width of a line's leading whitespace (tabs count as 4)
+func indentOf(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
@@ -0,0 +1,52 @@
+package skeleton
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Elided replaces a function body in an outline
+const Elided = "{ ... }"
+
+// Outliner reduces source code to its API surface: declarations, signatures
+// and doc comments, with implementation bodies elided
+type Outliner interface {
+	Outline(src []byte) ([]byte, error)
+}
+
+// registry maps a lowercase file extension (without dot) to its Outliner
+var registry = map[string]Outliner{}
+
+// Register adds an Outliner for the given extensions, replacing any existing one
+func Register(o Outliner, extensions ...string) {
+	for _, ext := range extensions {
+		registry[strings.ToLower(strings.TrimPrefix(ext, "."))] = o
+	}
+}
+
+// For returns the Outliner registered for path's extension
+func For(path string) (Outliner, bool) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	o, ok := registry[ext]
+	return o, ok
+}
+
+// Outline returns the skeleton of src, choosing the Outliner by path.
+// If no Outliner is registered, src is returned unchanged and ok is false.
+func Outline(path string, src []byte) (out []byte, ok bool, err error) {
+	o, found := For(path)
+	if !found {
+		return src, false, nil
+	}
+	out, err = o.Outline(src)
+	if err != nil {
+		return src, false, err
+	}
+	return out, true, nil
+}
+
+func init() {
+	Register(GoOutliner{}, "go")
+	Register(BraceOutliner{}, "js", "jsx", "mjs", "cjs", "ts", "tsx", "java", "kt", "kts", "scala", "c", "h", "cc", "cpp", "hpp", "cs", "rs", "swift", "php", "dart")
+	Register(IndentOutliner{}, "py", "pyi")
+}
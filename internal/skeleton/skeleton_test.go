@@ -0,0 +1,145 @@
+package skeleton
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGoOutliner(t *testing.T) {
+	src := `// Package demo does things.
+package demo
+
+import "fmt"
+
+// Greeter greets.
+type Greeter struct {
+	Name string
+}
+
+// Greet prints a greeting.
+func (g *Greeter) Greet() string {
+	msg := fmt.Sprintf("hi %s", g.Name)
+	return msg
+}
+
+func helper(a, b int) (int, error) {
+	if a > b {
+		return a, nil
+	}
+	return b, nil
+}
+`
+	out, ok, err := Outline("demo.go", []byte(src))
+	if err != nil || !ok {
+		t.Fatalf("Outline failed: ok=%v err=%v", ok, err)
+	}
+	got := string(out)
+
+	for _, want := range []string{
+		"// Package demo does things.\npackage demo",
+		`import "fmt"`,
+		"type Greeter struct {\n\tName string\n}",
+		"// Greet prints a greeting.\nfunc (g *Greeter) Greet() string { ... }",
+		"func helper(a, b int) (int, error) { ... }",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("outline missing %q\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Sprintf") || strings.Contains(got, "return") {
+		t.Errorf("outline still contains function bodies\n%s", got)
+	}
+}
+
+func TestBraceOutliner(t *testing.T) {
+	src := `import { a } from "./a";
+
+/** Adds numbers. */
+export function add(x: number, y: number): number {
+  const s = "}{";
+  return x + y;
+}
+
+export class Calc extends Base {
+  total = 0;
+
+  @Log({ level: "debug" })
+  push(n: number) {
+    if (n > 0) { this.total += n; }
+  }
+}
+
+const double = (n) => {
+  return n * 2;
+};
+`
+	out, ok, err := Outline("calc.ts", []byte(src))
+	if err != nil || !ok {
+		t.Fatalf("Outline failed: ok=%v err=%v", ok, err)
+	}
+	got := string(out)
+
+	for _, want := range []string{
+		`import { a } from "./a";`,
+		"/** Adds numbers. */\nexport function add(x: number, y: number): number { ... }",
+		"export class Calc extends Base {\n  total = 0;",
+		"@Log({ level: \"debug\" })\n  push(n: number) { ... }\n}",
+		"const double = (n) => { ... };",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("outline missing %q\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "return") || strings.Contains(got, "this.total") {
+		t.Errorf("outline still contains function bodies\n%s", got)
+	}
+}
+
+func TestIndentOutliner(t *testing.T) {
+	src := `import os
+
+
+class Store:
+    """A store."""
+
+    @property
+    def size(self) -> int:
+        """Number of items."""
+        return len(self.items)
+
+    def add(self,
+            item):
+        self.items.append(item)
+
+
+def main(): pass
+`
+	out, ok, err := Outline("store.py", []byte(src))
+	if err != nil || !ok {
+		t.Fatalf("Outline failed: ok=%v err=%v", ok, err)
+	}
+	got := string(out)
+
+	for _, want := range []string{
+		"import os",
+		"class Store:\n    \"\"\"A store.\"\"\"",
+		"    @property\n    def size(self) -> int:\n        \"\"\"Number of items.\"\"\"\n        ...\n\n",
+		"    def add(self,\n            item):\n        ...\n",
+		"def main(): pass",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("outline missing %q\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "return") || strings.Contains(got, "append") {
+		t.Errorf("outline still contains function bodies\n%s", got)
+	}
+}
+
+func TestOutline_Unsupported(t *testing.T) {
+	src := []byte("just text")
+	out, ok, err := Outline("notes.txt", src)
+	if err != nil || ok || string(out) != "just text" {
+		t.Errorf("expected unsupported file to pass through, got ok=%v err=%v out=%q", ok, err, out)
+	}
+}
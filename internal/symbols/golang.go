@@ -0,0 +1,85 @@
+package symbols
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// GoExtractor extracts types, functions, methods and exported constants and
+// variables using go/parser
+type GoExtractor struct{}
+
+// Extract implements Extractor
+func (GoExtractor) Extract(src []byte) ([]Symbol, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	var syms []Symbol
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			sym := Symbol{
+				Kind:     "func",
+				Name:     d.Name.Name,
+				Line:     fset.Position(d.Pos()).Line,
+				Exported: d.Name.IsExported(),
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				sym.Kind = "method"
+				sym.Name = "(" + receiver(d.Recv.List[0].Type) + ") " + d.Name.Name
+			}
+			syms = append(syms, sym)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					kind := "type"
+					switch s.Type.(type) {
+					case *ast.StructType:
+						kind = "struct"
+					case *ast.InterfaceType:
+						kind = "interface"
+					}
+					syms = append(syms, Symbol{Kind: kind, Name: s.Name.Name, Line: fset.Position(s.Pos()).Line, Exported: s.Name.IsExported()})
+				case *ast.ValueSpec:
+					kind := "var"
+					if d.Tok == token.CONST {
+						kind = "const"
+					}
+					for _, n := range s.Names {
+						if n.IsExported() {
+							syms = append(syms, Symbol{Kind: kind, Name: n.Name, Line: fset.Position(n.Pos()).Line, Exported: true})
+						}
+					}
+				}
+			}
+		}
+	}
+	return syms, nil
+}
+
+// receiver renders a method receiver type (e.g. "*Filter", "List[T]")
+func receiver(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + receiver(t.X)
+	case *ast.Ident:
+		return t.Name
+	case *ast.IndexExpr:
+		return receiver(t.X) + "[" + receiver(t.Index) + "]"
+	case *ast.IndexListExpr:
+		s := receiver(t.X) + "["
+		for i, idx := range t.Indices {
+			if i > 0 {
+				s += ", "
+			}
+			s += receiver(idx)
+		}
+		return s + "]"
+	}
+	return "?"
+}
@@ -0,0 +1,116 @@
+package symbols
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// rule maps a line pattern to a symbol kind. The pattern's "name" group is
+// the symbol name; an "export" group, when present, marks exported symbols.
+type rule struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// RegexExtractor extracts symbols line by line with regular expressions,
+// a lightweight stand-in for a real parser
+type RegexExtractor struct {
+	rules []rule
+	// exported decides visibility when no rule group does
+	exported func(name string) bool
+	// block is how declarations end, for Locate
+	block block
+}
+
+// Extract implements Extractor
+func (e *RegexExtractor) Extract(src []byte) ([]Symbol, error) {
+	var syms []Symbol
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		for _, r := range e.rules {
+			m := r.re.FindStringSubmatch(text)
+			if m == nil {
+				continue
+			}
+			sym := Symbol{Kind: r.kind, Line: line}
+			hasExport := false
+			for i, group := range r.re.SubexpNames() {
+				switch group {
+				case "name":
+					sym.Name = m[i]
+				case "export":
+					hasExport = true
+					sym.Exported = strings.TrimSpace(m[i]) != ""
+				}
+			}
+			if !hasExport && e.exported != nil {
+				sym.Exported = e.exported(sym.Name)
+			}
+			syms = append(syms, sym)
+			break
+		}
+	}
+	return syms, scanner.Err()
+}
+
+func rules(pairs ...string) []rule {
+	var rs []rule
+	for i := 0; i < len(pairs); i += 2 {
+		rs = append(rs, rule{kind: pairs[i], re: regexp.MustCompile(pairs[i+1])})
+	}
+	return rs
+}
+
+var scriptExtractor = &RegexExtractor{rules: rules(
+	"class", `^(?P<export>export\s+(?:default\s+)?)?(?:abstract\s+)?class\s+(?P<name>[\w$]+)`,
+	"function", `^(?P<export>export\s+(?:default\s+)?)?(?:async\s+)?function\*?\s+(?P<name>[\w$]+)`,
+	"interface", `^(?P<export>export\s+)?(?:declare\s+)?interface\s+(?P<name>[\w$]+)`,
+	"type", `^(?P<export>export\s+)?(?:declare\s+)?type\s+(?P<name>[\w$]+)\s*(?:<[^=]*>)?\s*=`,
+	"enum", `^(?P<export>export\s+)?(?:const\s+)?enum\s+(?P<name>[\w$]+)`,
+	"const", `^(?P<export>export)\s+(?:const|let|var)\s+(?P<name>[\w$]+)`,
+)}
+
+var pythonExtractor = &RegexExtractor{
+	rules: rules(
+		"class", `^class\s+(?P<name>\w+)`,
+		"def", `^(?:async\s+)?def\s+(?P<name>\w+)`,
+		"method", `^\s+(?:async\s+)?def\s+(?P<name>\w+)`,
+		"const", `^(?P<name>[A-Z][A-Z0-9_]*)\s*(?::[^=]+)?=`,
+	),
+	exported: func(name string) bool { return !strings.HasPrefix(name, "_") },
+	block:    blockIndent,
+}
+
+var rustExtractor = &RegexExtractor{rules: rules(
+	"fn", `^\s*(?P<export>pub(?:\([\w:]+\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?fn\s+(?P<name>\w+)`,
+	"struct", `^(?P<export>pub(?:\([\w:]+\))?\s+)?struct\s+(?P<name>\w+)`,
+	"enum", `^(?P<export>pub(?:\([\w:]+\))?\s+)?enum\s+(?P<name>\w+)`,
+	"trait", `^(?P<export>pub(?:\([\w:]+\))?\s+)?trait\s+(?P<name>\w+)`,
+	"type", `^(?P<export>pub(?:\([\w:]+\))?\s+)?type\s+(?P<name>\w+)`,
+	"const", `^(?P<export>pub(?:\([\w:]+\))?\s+)?(?:const|static)\s+(?P<name>\w+)`,
+	"impl", `^impl(?:<[^>]*>)?\s+(?P<name>[\w:<>, ]+?)\s*(?:\{|where|$)`,
+)}
+
+var javaExtractor = &RegexExtractor{rules: rules(
+	"class", `^\s*(?P<export>public\s+)?(?:(?:private|protected|internal|static|final|abstract|sealed|data|open)\s+)*class\s+(?P<name>\w+)`,
+	"interface", `^\s*(?P<export>public\s+)?(?:(?:private|protected|internal|static|sealed)\s+)*interface\s+(?P<name>\w+)`,
+	"enum", `^\s*(?P<export>public\s+)?(?:(?:private|protected|internal|static)\s+)*enum\s+(?:class\s+)?(?P<name>\w+)`,
+	"record", `^\s*(?P<export>public\s+)?(?:(?:private|protected|static|final)\s+)*record\s+(?P<name>\w+)`,
+	"method", `^\s+(?P<export>public\s+)(?:(?:static|final|abstract|synchronized|override|async|virtual)\s+)*[\w<>\[\],.? ]+\s+(?P<name>\w+)\s*\([^;]*$`,
+)}
+
+var rubyExtractor = &RegexExtractor{
+	rules: rules(
+		"class", `^\s*class\s+(?P<name>[\w:]+)`,
+		"module", `^\s*module\s+(?P<name>[\w:]+)`,
+		"def", `^\s*def\s+(?P<name>(?:self\.)?[\w?!=]+)`,
+	),
+	exported: func(name string) bool { return !strings.HasPrefix(name, "_") },
+	block:    blockKeyword,
+}
@@ -0,0 +1,236 @@
+package symbols
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// spanner finds the last line of the declaration starting at a line
+type spanner interface {
+	end(src []byte, lines []string, line int) int
+}
+
+// Locate returns the first and last lines of the declaration of the named
+// symbol in src, including the comments and attributes directly above it.
+// name is a symbol name ("HandleLogin", "Server") or a method qualified by
+// its type ("Server.HandleLogin").
+func Locate(path string, src []byte, name string) (start, end int, err error) {
+	e, ok := registry[strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))]
+	if !ok {
+		return 0, 0, fmt.Errorf("cannot locate symbols in %s: unsupported file type", path)
+	}
+	syms, err := e.Extract(src)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot locate symbols in %s: %w", path, err)
+	}
+	lines := strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n")
+	s, _ := e.(spanner)
+	span := func(sym Symbol) int {
+		if s == nil {
+			return sym.Line
+		}
+		return s.end(src, lines, sym.Line)
+	}
+
+	var matches []Symbol
+	for _, sym := range syms {
+		if matchesName(sym.Name, name) {
+			matches = append(matches, sym)
+		}
+	}
+	if len(matches) == 0 {
+		// "Outer.inner": a member declared within its type's span
+		if outer, inner, ok := cutLast(name, "."); ok {
+			for _, o := range syms {
+				if o.Name != outer {
+					continue
+				}
+				last := span(o)
+				for _, sym := range syms {
+					if sym.Line > o.Line && sym.Line <= last && sym.Name == inner {
+						matches = append(matches, sym)
+					}
+				}
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return 0, 0, fmt.Errorf("symbol %s not found in %s", name, path)
+	case 1:
+	default:
+		at := make([]string, len(matches))
+		for i, m := range matches {
+			at[i] = fmt.Sprintf("%s (line %d)", m.Name, m.Line)
+		}
+		return 0, 0, fmt.Errorf("symbol %s is ambiguous in %s: %s", name, path, strings.Join(at, ", "))
+	}
+	sym := matches[0]
+	return leadingComments(lines, sym.Line), span(sym), nil
+}
+
+// matchesName reports whether a symbol name matches the name asked for. Go
+// methods ("(*Server) Handle") match "Handle" and "Server.Handle".
+func matchesName(symName, name string) bool {
+	if symName == name {
+		return true
+	}
+	recv, method, ok := strings.Cut(symName, ") ")
+	if !ok || !strings.HasPrefix(recv, "(") {
+		return false
+	}
+	typ, _, _ := strings.Cut(strings.TrimPrefix(recv[1:], "*"), "[")
+	return method == name || typ+"."+method == name
+}
+
+// leadingComments returns the first line of the comments, decorators and
+// attributes directly above line
+func leadingComments(lines []string, line int) int {
+	start := line
+	for i := line - 2; i >= 0; i-- {
+		text := strings.TrimSpace(lines[i])
+		if text == "" || !isCommentLine(text) {
+			break
+		}
+		start = i + 1
+	}
+	return start
+}
+
+func isCommentLine(text string) bool {
+	for _, prefix := range []string{"//", "#", "/*", "*", "@", "--"} {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// end implements spanner with the positions go/parser records
+func (GoExtractor) end(src []byte, _ []string, line int) int {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return line
+	}
+	lineOf := func(p token.Pos) int { return fset.Position(p).Line }
+	for _, decl := range file.Decls {
+		if lineOf(decl.Pos()) > line || lineOf(decl.End()) < line {
+			continue
+		}
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || !d.Lparen.IsValid() {
+			return lineOf(decl.End())
+		}
+		// A spec within a group: "type ( ... )"
+		for _, spec := range d.Specs {
+			if lineOf(spec.Pos()) <= line && lineOf(spec.End()) >= line {
+				return lineOf(spec.End())
+			}
+		}
+		return lineOf(decl.End())
+	}
+	return line
+}
+
+// block is how a RegexExtractor's language delimits declarations
+type block int
+
+const (
+	blockBraces  block = iota // { ... }
+	blockIndent               // an indented body (Python)
+	blockKeyword              // a closing "end" (Ruby)
+)
+
+// end implements spanner with the extractor's block style
+func (e *RegexExtractor) end(_ []byte, lines []string, line int) int {
+	switch e.block {
+	case blockIndent:
+		return indentEnd(lines, line)
+	case blockKeyword:
+		return keywordEnd(lines, line)
+	}
+	return braceEnd(lines, line)
+}
+
+// braceEnd returns the line closing the first brace opened at or after line,
+// or line itself for declarations ending with ";" before any brace
+func braceEnd(lines []string, line int) int {
+	depth, opened := 0, false
+	for i := line - 1; i < len(lines); i++ {
+		quote := byte(0)
+		text := lines[i]
+		for j := 0; j < len(text); j++ {
+			c := text[j]
+			switch {
+			case quote != 0:
+				if c == '\\' {
+					j++
+				} else if c == quote {
+					quote = 0
+				}
+			case c == '"' || c == '\'' || c == '`':
+				quote = c
+			case c == '/' && j+1 < len(text) && text[j+1] == '/':
+				j = len(text)
+			case c == '{':
+				depth++
+				opened = true
+			case c == '}':
+				depth--
+				if opened && depth == 0 {
+					return i + 1
+				}
+			case c == ';' && !opened:
+				return i + 1
+			}
+		}
+	}
+	return len(lines)
+}
+
+// indentEnd returns the last non-blank line indented deeper than line, not
+// counting brackets that close a multi-line signature
+func indentEnd(lines []string, line int) int {
+	base := indentation(lines[line-1])
+	end := line
+	for i := line; i < len(lines); i++ {
+		text := strings.TrimSpace(lines[i])
+		if text == "" {
+			continue
+		}
+		if indentation(lines[i]) <= base && !strings.HasPrefix(text, ")") && !strings.HasPrefix(text, "]") {
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+// keywordEnd returns the line of the "end" at the indentation of line
+func keywordEnd(lines []string, line int) int {
+	base := indentation(lines[line-1])
+	for i := line; i < len(lines); i++ {
+		text := strings.TrimSpace(lines[i])
+		if indentation(lines[i]) == base && (text == "end" || strings.HasPrefix(text, "end ")) {
+			return i + 1
+		}
+	}
+	return len(lines)
+}
+
+func indentation(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " \t"))
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,109 @@
+package symbols
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileSymbols pairs a file with its symbols
+type fileSymbols struct {
+	path    string
+	symbols []Symbol
+}
+
+// detail levels, from most to least verbose
+var levels = []func(Symbol) bool{
+	func(s Symbol) bool { return true },
+	func(s Symbol) bool { return s.Exported },
+	func(s Symbol) bool { return s.Exported && s.Kind != "method" },
+	func(s Symbol) bool {
+		return s.Exported && s.Kind != "method" && s.Kind != "const" && s.Kind != "var"
+	},
+}
+
+// RepoMap renders a compact index of the top-level symbols of files (relative
+// to root). Detail is reduced until the map fits maxTokens (estimated as
+// bytes/4; 0 = unlimited), dropping private symbols, then methods, then
+// constants; if still too large, trailing files are cut.
+func RepoMap(root string, files []string, maxTokens int) (string, error) {
+	return RepoMapFS(os.DirFS(root), files, maxTokens)
+}
+
+// RepoMapFS is RepoMap for files relative to the root of fsys
+func RepoMapFS(fsys fs.FS, files []string, maxTokens int) (string, error) {
+	var all []fileSymbols
+	for _, f := range files {
+		src, err := fs.ReadFile(fsys, filepath.ToSlash(f))
+		if err != nil {
+			return "", err
+		}
+		syms, err := Extract(f, src)
+		if err != nil {
+			// Unparseable files are simply left out of the map
+			continue
+		}
+		if len(syms) > 0 {
+			all = append(all, fileSymbols{path: filepath.ToSlash(f), symbols: syms})
+		}
+	}
+
+	var lines []string
+	for _, keep := range levels {
+		lines = renderLevel(all, keep)
+		if fits(lines, maxTokens) {
+			return strings.Join(lines, ""), nil
+		}
+	}
+
+	// Still too large: cut whole files from the end
+	budget := int64(maxTokens) * 4
+	var sb strings.Builder
+	var used int64
+	for i, line := range lines {
+		if !strings.HasPrefix(line, " ") && used+int64(len(line)) > budget {
+			remaining := 0
+			for _, l := range lines[i:] {
+				if !strings.HasPrefix(l, " ") {
+					remaining++
+				}
+			}
+			fmt.Fprintf(&sb, "… %d more files\n", remaining)
+			break
+		}
+		sb.WriteString(line)
+		used += int64(len(line))
+	}
+	return sb.String(), nil
+}
+
+func renderLevel(all []fileSymbols, keep func(Symbol) bool) []string {
+	var lines []string
+	for _, fs := range all {
+		var syms []string
+		for _, s := range fs.symbols {
+			if keep(s) {
+				syms = append(syms, fmt.Sprintf("  %d: %s %s\n", s.Line, s.Kind, s.Name))
+			}
+		}
+		if len(syms) == 0 {
+			continue
+		}
+		lines = append(lines, fs.path+"\n")
+		lines = append(lines, syms...)
+	}
+	return lines
+}
+
+func fits(lines []string, maxTokens int) bool {
+	if maxTokens <= 0 {
+		return true
+	}
+	var size int
+	for _, l := range lines {
+		size += len(l)
+	}
+	return size/4 <= maxTokens
+}
@@ -0,0 +1,48 @@
+package symbols
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Symbol is a top-level declaration in a source file
+type Symbol struct {
+	Kind     string // e.g. "type", "func", "method", "class", "const"
+	Name     string // e.g. "Filter", "(*Filter) IsIgnored"
+	Line     int
+	Exported bool
+}
+
+// Extractor finds the top-level symbols of a source file
+type Extractor interface {
+	Extract(src []byte) ([]Symbol, error)
+}
+
+// registry maps a lowercase file extension (without dot) to its Extractor
+var registry = map[string]Extractor{}
+
+// Register adds an Extractor for the given extensions
+func Register(e Extractor, extensions ...string) {
+	for _, ext := range extensions {
+		registry[strings.ToLower(strings.TrimPrefix(ext, "."))] = e
+	}
+}
+
+// Extract returns the symbols of src, choosing the Extractor by path.
+// Files without a registered Extractor have no symbols.
+func Extract(path string, src []byte) ([]Symbol, error) {
+	e, ok := registry[strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))]
+	if !ok {
+		return nil, nil
+	}
+	return e.Extract(src)
+}
+
+func init() {
+	Register(GoExtractor{}, "go")
+	Register(scriptExtractor, "js", "jsx", "mjs", "cjs", "ts", "tsx")
+	Register(pythonExtractor, "py", "pyi")
+	Register(rustExtractor, "rs")
+	Register(javaExtractor, "java", "kt", "cs", "scala")
+	Register(rubyExtractor, "rb")
+}
@@ -0,0 +1,185 @@
+package symbols
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtract_Go(t *testing.T) {
+	src := `package store
+
+const MaxItems = 10
+
+type Store struct{}
+
+type Reader interface{}
+
+func New() *Store { return nil }
+
+func (s *Store) Get(key string) string { return "" }
+
+func helper() {}
+`
+	syms, err := Extract("store.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Symbol{
+		{"const", "MaxItems", 3, true},
+		{"struct", "Store", 5, true},
+		{"interface", "Reader", 7, true},
+		{"func", "New", 9, true},
+		{"method", "(*Store) Get", 11, true},
+		{"func", "helper", 13, false},
+	}
+	if len(syms) != len(want) {
+		t.Fatalf("got %d symbols, want %d: %v", len(syms), len(want), syms)
+	}
+	for i := range want {
+		if syms[i] != want[i] {
+			t.Errorf("symbol %d = %+v; want %+v", i, syms[i], want[i])
+		}
+	}
+}
+
+func TestExtract_Heuristics(t *testing.T) {
+	tests := []struct {
+		path string
+		src  string
+		want []string
+	}{
+		{
+			path: "api.ts",
+			src:  "export class Client {}\nfunction local() {}\nexport interface Opts {}\nexport const VERSION = 1;\n",
+			want: []string{"1: class Client", "2: function local", "3: interface Opts", "4: const VERSION"},
+		},
+		{
+			path: "app.py",
+			src:  "TIMEOUT = 5\n\nclass App:\n    def run(self):\n        pass\n\ndef _private():\n    pass\n",
+			want: []string{"1: const TIMEOUT", "3: class App", "4: method run", "7: def _private"},
+		},
+		{
+			path: "lib.rs",
+			src:  "pub struct Parser {}\n\nimpl Parser {\n    pub fn parse(&self) {}\n}\n",
+			want: []string{"1: struct Parser", "3: impl Parser", "4: fn parse"},
+		},
+	}
+
+	for _, tt := range tests {
+		syms, err := Extract(tt.path, []byte(tt.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, s := range syms {
+			got = append(got, fmt.Sprintf("%d: %s %s", s.Line, s.Kind, s.Name))
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: got %v; want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRepoMap_Budget(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"a.go": "package a\n\ntype A struct{}\n\nfunc (a A) Method() {}\n\nfunc private() {}\n",
+		"b.go": "package a\n\nfunc B() {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	full, err := RepoMap(root, []string{"a.go", "b.go"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"a.go\n  3: struct A\n", "method (A) Method", "func private", "b.go\n  3: func B\n"} {
+		if !strings.Contains(full, want) {
+			t.Errorf("full map missing %q:\n%s", want, full)
+		}
+	}
+
+	small, err := RepoMap(root, []string{"a.go", "b.go"}, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(small, "private") || strings.Contains(small, "Method") {
+		t.Errorf("budgeted map should drop private symbols and methods:\n%s", small)
+	}
+	if !strings.Contains(small, "struct A") {
+		t.Errorf("budgeted map should keep exported types:\n%s", small)
+	}
+}
+
+func TestLocate(t *testing.T) {
+	goSrc := `package server
+
+// Server handles requests.
+type Server struct {
+	addr string
+}
+
+// HandleLogin checks credentials.
+func (s *Server) HandleLogin() {
+	if s.addr == "}" {
+		return
+	}
+}
+
+type (
+	ID   string
+	Opts struct {
+		Debug bool
+	}
+)
+
+func (c *Client) Close() {}
+
+func (s *Server) Close() {}
+`
+	tests := []struct {
+		path, src, name string
+		start, end      int
+		err             string
+	}{
+		{path: "server.go", src: goSrc, name: "Server", start: 3, end: 6},
+		{path: "server.go", src: goSrc, name: "HandleLogin", start: 8, end: 13},
+		{path: "server.go", src: goSrc, name: "Server.HandleLogin", start: 8, end: 13},
+		{path: "server.go", src: goSrc, name: "Opts", start: 17, end: 19},
+		{path: "server.go", src: goSrc, name: "Server.Close", start: 24, end: 24},
+		{path: "server.go", src: goSrc, name: "Close", err: "ambiguous"},
+		{path: "server.go", src: goSrc, name: "Missing", err: "not found"},
+		{
+			path: "app.ts", name: "login",
+			src:   "import x from 'y';\n\n/** Logs in. */\nexport function login(user: string) {\n  const s = \"{\";\n  return { user };\n}\n\nexport type ID = string;\n",
+			start: 3, end: 7,
+		},
+		{path: "app.ts", src: "export type ID = string;\nexport const A = 1;\n", name: "ID", start: 1, end: 1},
+		{
+			path: "app.py", name: "App.run",
+			src:   "class App:\n    @cached\n    def run(\n        self,\n    ):\n        pass\n\n    def stop(self):\n        pass\n",
+			start: 2, end: 6,
+		},
+		{path: "app.rb", src: "class App\n  def run\n    go\n  end\nend\n", name: "App", start: 1, end: 5},
+		{path: "notes.txt", src: "x", name: "x", err: "unsupported"},
+	}
+	for _, tt := range tests {
+		start, end, err := Locate(tt.path, []byte(tt.src), tt.name)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s#%s: expected a %q error, got %v", tt.path, tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || start != tt.start || end != tt.end {
+			t.Errorf("%s#%s = %d-%d, %v; want %d-%d", tt.path, tt.name, start, end, err, tt.start, tt.end)
+		}
+	}
+}
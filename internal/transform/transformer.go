@@ -1,14 +1,20 @@
 package transform
 
 import (
+	"fmt"
+	"os"
 	"regexp"
 	"strings"
+
+	"github.com/nessaee/concat/internal/cache"
+	"github.com/nessaee/concat/internal/skeleton"
 )
 
 // Options holds the transformation settings
 type Options struct {
 	Compact      bool
 	StripHeaders bool
+	Skeleton     bool
 }
 
 // Transformer handles text processing
@@ -20,6 +26,7 @@ type Transformer struct {
 	headerHash   *regexp.Regexp
 	reMd         *regexp.Regexp
 	reXml        *regexp.Regexp
+	cache        *cache.Cache
 }
 
 // NewTransformer creates a new Transformer instance
@@ -32,22 +39,40 @@ func NewTransformer(opts Options) *Transformer {
 		headerLine:   regexp.MustCompile(`(?s)^(//.*(Copyright|License).*\n)+`),
 		headerHash:   regexp.MustCompile(`(?s)^(#.*(Copyright|License).*\n)+`),
 		// Protocol-aware Regexes
-		// Protocol: ### File: %s ###
-		reMd: regexp.MustCompile(`### File: (.*?) ###\s*\r?\n`),
-		// Protocol: <file path="%s">
-		reXml: regexp.MustCompile(`<file path="(.*?)">\s*\r?\n`),
+		// Protocol: ### File: %s ### or ### File: %s (lines %d-%d) ###
+		reMd: regexp.MustCompile(`### File: (.*?)(?: \(lines \d+-\d+\))? ###\s*\r?\n`),
+		// Protocol: <file path="%s"> or <file path="%s" lines="%d-%d">
+		reXml: regexp.MustCompile(`<file path="(.*?)"(?: lines="\d+-\d+")?>\s*\r?\n`),
 	}
 }
 
+// SetCache enables reuse of outlined file sections across runs, so only
+// files whose content changed are re-outlined. The other transformations are
+// cheap regular expressions and not cached.
+func (t *Transformer) SetCache(c *cache.Cache) {
+	t.cache = c
+}
+
 // Process applies all configured transformations to the content
 func (t *Transformer) Process(content string) string {
 	// Normalize line endings for consistent processing (optional, but recommended)
 	content = strings.ReplaceAll(content, "\r\n", "\n")
 
+	if t.cache != nil && t.options.Skeleton {
+		return t.processCached(content)
+	}
+	return t.apply(content)
+}
+
+func (t *Transformer) apply(content string) string {
 	if t.options.StripHeaders {
 		content = t.stripLicense(content)
 	}
 
+	if t.options.Skeleton {
+		content = t.outline(content)
+	}
+
 	if t.options.Compact {
 		content = t.removeExcessWhitespace(content)
 	}
@@ -55,6 +80,48 @@ func (t *Transformer) Process(content string) string {
 	return content
 }
 
+// processCached transforms each file section on its own, memoized by its
+// content and the options. Every transformation is local to a section, so
+// the result matches transforming the whole stream at once.
+func (t *Transformer) processCached(content string) string {
+	key := fmt.Sprintf("opt|%+v", t.options)
+	memo := func(section string) string {
+		return string(t.cache.Memo(func() []byte { return []byte(t.apply(section)) }, key, section))
+	}
+
+	re := t.reMd
+	if !re.MatchString(content) {
+		re = t.reXml
+		if !re.MatchString(content) {
+			return memo(content)
+		}
+	}
+
+	matches := re.FindAllStringIndex(content, -1)
+
+	var res strings.Builder
+	res.WriteString(t.ProcessPreamble(content[:matches[0][0]]))
+	for i, m := range matches {
+		end := len(content)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		res.WriteString(memo(content[m[0]:end]))
+	}
+	return res.String()
+}
+
+// ProcessPreamble transforms the text preceding the first file section
+// (document header, tree, repo map), which is never stripped or outlined.
+// Together with Process on each file section, it allows streaming.
+func (t *Transformer) ProcessPreamble(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if t.options.Compact {
+		content = t.removeExcessWhitespace(content)
+	}
+	return content
+}
+
 func (t *Transformer) removeExcessWhitespace(content string) string {
 	return t.multiNewline.ReplaceAllString(content, "\n\n")
 }
@@ -99,3 +166,44 @@ func (t *Transformer) stripLicenseSingle(content string) string {
 	c = t.headerHash.ReplaceAllString(c, "")
 	return strings.TrimSpace(c)
 }
+
+// outline replaces each file section with its skeleton, choosing the
+// outliner by the path in the section's protocol marker
+func (t *Transformer) outline(content string) string {
+	re, footer := t.reMd, "\n\n---\n"
+	if !re.MatchString(content) {
+		re, footer = t.reXml, "\n</file>"
+		if !re.MatchString(content) {
+			return content
+		}
+	}
+
+	matches := re.FindAllStringSubmatchIndex(content, -1)
+
+	var res strings.Builder
+	res.WriteString(content[:matches[0][0]]) // Preamble
+	for i, m := range matches {
+		path := content[m[2]:m[3]]
+		res.WriteString(content[m[0]:m[1]])
+
+		end := len(content)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		section := content[m[1]:end]
+
+		// Only the body is outlined; the footer and anything after it are kept
+		body, rest := section, ""
+		if idx := strings.LastIndex(section, footer); idx >= 0 {
+			body, rest = section[:idx], section[idx:]
+		}
+
+		out, _, err := skeleton.Outline(path, []byte(body))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ Could not outline %s, keeping full content: %v\n", path, err)
+		}
+		res.Write(out)
+		res.WriteString(rest)
+	}
+	return res.String()
+}
@@ -1,7 +1,10 @@
 package transform
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/nessaee/concat/internal/cache"
 )
 
 func TestTransformer_RemoveExcessWhitespace(t *testing.T) {
@@ -61,3 +64,63 @@ func main() {}`,
 		}
 	}
 }
+
+func TestTransformer_Skeleton(t *testing.T) {
+	transformer := NewTransformer(Options{Skeleton: true})
+	input := "---\nProject: demo\n---\n\n" +
+		"### File: main.go ###\npackage main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n\n\n---\n\n" +
+		"### File: notes.txt ###\nfunc main() {\n\tkeep me\n}\n\n\n---\n\n"
+
+	got := transformer.Process(input)
+
+	if !strings.Contains(got, "### File: main.go ###\npackage main\n\nfunc main() { ... }\n\n\n---\n\n") {
+		t.Errorf("Go section was not outlined:\n%s", got)
+	}
+	if !strings.Contains(got, "keep me") {
+		t.Errorf("Unsupported section should be unchanged:\n%s", got)
+	}
+	if !strings.HasPrefix(got, "---\nProject: demo\n---\n\n") {
+		t.Errorf("Preamble was not preserved:\n%s", got)
+	}
+}
+
+func TestTransformer_PartialMarkers(t *testing.T) {
+	transformer := NewTransformer(Options{Skeleton: true})
+	for _, input := range []string{
+		"### File: main.go (lines 1-5) ###\npackage main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n\n\n---\n\n",
+		"<file path=\"main.go\" lines=\"1-5\">\npackage main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n\n</file>\n",
+	} {
+		// The path is parsed without the range, so the Go outliner applies
+		if got := transformer.Process(input); !strings.Contains(got, "func main() { ... }") || !strings.Contains(got, "1-5") {
+			t.Errorf("Partial section was not outlined:\n%s", got)
+		}
+	}
+}
+
+func TestTransformer_Cache(t *testing.T) {
+	c, err := cache.OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Compact: true, StripHeaders: true, Skeleton: true}
+	input := "---\nProject: demo\n---\n\n\n\n" +
+		"### File: main.go ###\n// Copyright 2024\npackage main\n\n\n\nfunc main() {\n\tprintln(\"hi\")\n}\n\n\n---\n\n" +
+		"### File: notes.txt ###\nkeep me\n\n\n---\n\n"
+
+	want := NewTransformer(opts).Process(input)
+
+	cached := NewTransformer(opts)
+	cached.SetCache(c)
+	for i := 0; i < 2; i++ {
+		if got := cached.Process(input); got != want {
+			t.Errorf("run %d: cached result differs:\n%q\nwant:\n%q", i, got, want)
+		}
+	}
+
+	// Different options must not reuse the cached sections
+	plain := NewTransformer(Options{})
+	plain.SetCache(c)
+	if got := plain.Process(input); got != input {
+		t.Errorf("Expected untransformed input, got:\n%q", got)
+	}
+}
@@ -1,13 +1,19 @@
 package e2e
 
 import (
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 var (
@@ -21,22 +27,33 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		panic(err)
 	}
-	defer os.RemoveAll(tmpBin)
 
 	concatBin = filepath.Join(tmpBin, "concat")
 	optBin = filepath.Join(tmpBin, "opt")
 
 	if err := buildBin("../cmd/concat", concatBin); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to build concat: %v\n", err)
+		os.RemoveAll(tmpBin)
 		os.Exit(1)
 	}
 	if err := buildBin("../cmd/opt", optBin); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to build opt: %v\n", err)
+		os.RemoveAll(tmpBin)
 		os.Exit(1)
 	}
 
-	// 2. Run Tests
-	os.Exit(m.Run())
+	// Keep the content cache out of the user's cache directory
+	tmpCache, err := os.MkdirTemp("", "concat_e2e_cache")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CACHE_HOME", tmpCache)
+
+	// 2. Run Tests, cleaning up explicitly as os.Exit skips deferred calls
+	code := m.Run()
+	os.RemoveAll(tmpCache)
+	os.RemoveAll(tmpBin)
+	os.Exit(code)
 }
 
 func buildBin(src, dst string) error {
@@ -131,6 +148,883 @@ oCmd := exec.Command(optBin, "-c") // Compact flag
 	})
 }
 
+func TestOptCostCompare(t *testing.T) {
+	pricingFile := filepath.Join(t.TempDir(), "pricing.json")
+	createFile(t, filepath.Dir(pricingFile), "pricing.json", `[{"name": "tiny", "inputPerMTok": 1000000, "contextWindow": 10}]`)
+
+	cmd := exec.Command(optBin, "--compare", "--cost-format", "json", "--pricing", pricingFile, "--model", "tiny", "-s")
+	cmd.Stdin = strings.NewReader(strings.Repeat("x", 400))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Run failed: %v\nStderr: %s", err, stderr.String())
+	}
+	if string(out) != strings.Repeat("x", 400) {
+		t.Errorf("Expected the content on stdout, got %q", out)
+	}
+
+	var report struct {
+		Tokens    int64
+		Model     string
+		Estimates []struct {
+			Model     string
+			InputCost float64
+			Fits      bool
+		}
+	}
+	if err := json.Unmarshal(stderr.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON report: %v\n%s", err, stderr.String())
+	}
+	if report.Tokens != 100 || report.Model != "tiny" || len(report.Estimates) < 2 {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+	last := report.Estimates[len(report.Estimates)-1]
+	if last.Model != "tiny" || last.InputCost != 100 || last.Fits {
+		t.Errorf("Unexpected estimate for the override: %+v", last)
+	}
+
+	// Text mode warns when the selected model's context window is too small
+	cmd = exec.Command(optBin, "--cost", "--pricing", pricingFile, "--model", "tiny", "-s")
+	cmd.Stdin = strings.NewReader(strings.Repeat("x", 400))
+	combined, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, combined)
+	}
+	if !strings.Contains(string(combined), "Cost:   ~$100.0000 input (tiny, 1000.0% of 10 context)") || !strings.Contains(string(combined), "⚠ ~100 tokens exceed the 10-token context window of tiny") {
+		t.Errorf("Unexpected cost report:\n%s", combined)
+	}
+}
+
+func TestConcatStats(t *testing.T) {
+	fixtureDir := t.TempDir()
+	createFile(t, fixtureDir, "main.go", "package main\n\nfunc main() {}")
+	createFile(t, fixtureDir, "main_test.go", "package main")
+	if err := os.MkdirAll(filepath.Join(fixtureDir, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	createFile(t, fixtureDir, "pkg/util.go", "package pkg")
+	createFile(t, fixtureDir, "pkg/huge.go", strings.Repeat("x", 2000))
+	statsFile := filepath.Join(t.TempDir(), "stats.json")
+
+	cmd := exec.Command(concatBin, "-p", "go", "--stdout", "--no-tests", "--max-file-size", "1000", "--stats", "--stats-format", "json", "--stats-file", statsFile)
+	cmd.Dir = fixtureDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Run failed: %v\nOutput: %s", err, out)
+	}
+	data, err := os.ReadFile(statsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report struct {
+		Files       int
+		Largest     []struct{ Path string }
+		Directories []struct {
+			Name  string
+			Files int
+		}
+		Skipped []struct {
+			Reason string
+			Paths  []string
+		}
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Invalid JSON report: %v\n%s", err, data)
+	}
+	if report.Files != 2 || report.Largest[0].Path != "main.go" || len(report.Directories) != 2 {
+		t.Errorf("Unexpected totals: %s", data)
+	}
+	skipped := make(map[string][]string)
+	for _, g := range report.Skipped {
+		skipped[g.Reason] = g.Paths
+	}
+	if fmt.Sprint(skipped["test"]) != "[main_test.go]" || fmt.Sprint(skipped["too large"]) != "[pkg/huge.go]" {
+		t.Errorf("Unexpected skipped files: %v", skipped)
+	}
+
+	cmd = exec.Command(optBin, "-c", "--stats", "-s")
+	cmd.Stdin = strings.NewReader("a\n\n\n\n\n\n\n\nb\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "Savings: ~2 -> ~1 tokens (saved ~1, 54.5%)") {
+		t.Errorf("Unexpected savings report:\n%s", out)
+	}
+}
+
+func TestConcatPickSaved(t *testing.T) {
+	fixtureDir := t.TempDir()
+	createFile(t, fixtureDir, "main.go", "package main")
+	if err := os.MkdirAll(filepath.Join(fixtureDir, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	createFile(t, fixtureDir, "pkg/util.go", "package pkg")
+
+	run := func() (string, string, error) {
+		cmd := exec.Command(concatBin, "pick", "--saved", "-p", "go", "--stdout")
+		cmd.Dir = fixtureDir
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
+	}
+
+	if _, stderr, err := run(); err == nil || !strings.Contains(stderr, "no saved selection") {
+		t.Fatalf("Expected an error without a saved selection, got %v: %s", err, stderr)
+	}
+
+	if err := os.MkdirAll(filepath.Join(fixtureDir, ".concat"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	createFile(t, fixtureDir, ".concat/config.json", `{"selection": ["pkg/util.go", "gone.go"]}`)
+	stdout, stderr, err := run()
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, stderr)
+	}
+	if !strings.Contains(stdout, "### File: pkg/util.go ###") || strings.Contains(stdout, "main.go") {
+		t.Errorf("Expected only the saved file:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "⚠ Saved file gone.go is no longer included") {
+		t.Errorf("Expected a warning for the missing file:\n%s", stderr)
+	}
+}
+
+func TestConcatBundle(t *testing.T) {
+	fixtureDir := t.TempDir()
+	for _, dir := range []string{"internal/auth", "web", ".concat/bundles"} {
+		if err := os.MkdirAll(filepath.Join(fixtureDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createFile(t, fixtureDir, "internal/auth/login.go", "package auth\n\nfunc Login() error {\n\treturn nil\n}\n")
+	createFile(t, fixtureDir, "web/app.js", "login()\n")
+	createFile(t, fixtureDir, "notes.txt", "one\ntwo\nthree\n")
+	createFile(t, fixtureDir, "unrelated.go", "package main\n")
+	createFile(t, fixtureDir, ".concat/bundles/auth-flow.yaml", `description: |
+  Login and session handling.
+files:
+  - web/app.js
+  - path: internal/auth/
+    skeleton: true
+  - path: notes.txt
+    lines: 2-3
+  - removed/
+`)
+
+	cmd := exec.Command(concatBin, "bundle", "auth-flow", "--stdout")
+	cmd.Dir = fixtureDir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, stderr.String())
+	}
+	output := stdout.String()
+
+	// Manifest order, with the description first
+	var last int
+	for _, want := range []string{
+		"### Bundle: auth-flow ###\nLogin and session handling.\n",
+		"### File: web/app.js ###",
+		"### File: internal/auth/login.go ###\npackage auth\n\nfunc Login() error { ... }",
+		"### File: notes.txt (lines 2-3) ###\ntwo\nthree\n",
+	} {
+		i := strings.Index(output, want)
+		if i < last {
+			t.Errorf("Expected %q after the previous sections:\n%s", want, output)
+		}
+		last = i
+	}
+	if strings.Contains(output, "unrelated.go") {
+		t.Errorf("Expected only the bundle's files:\n%s", output)
+	}
+	if !strings.Contains(stderr.String(), "⚠ Bundle entry removed/ matches no files") {
+		t.Errorf("Expected a warning for the stale entry:\n%s", stderr.String())
+	}
+
+	cmd = exec.Command(concatBin, "bundle")
+	cmd.Dir = fixtureDir
+	if out, err := cmd.Output(); err != nil || string(out) != "auth-flow  Login and session handling.\n" {
+		t.Errorf("Unexpected bundle list %q: %v", out, err)
+	}
+}
+
+func TestConcatPaths(t *testing.T) {
+	fixtureDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(fixtureDir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	createFile(t, fixtureDir, "server.go", `package server
+
+type Server struct{}
+
+// HandleLogin checks credentials.
+func (s *Server) HandleLogin() {
+	println("login")
+}
+
+func other() {}
+`)
+	createFile(t, fixtureDir, "notes.txt", "one\ntwo\nthree\nfour\n")
+	createFile(t, fixtureDir, "docs/guide.md", "# Guide\n")
+	createFile(t, fixtureDir, "unrelated.go", "package server\n")
+
+	cmd := exec.Command(concatBin, "server.go#HandleLogin", "notes.txt:1,3-", "docs", "--stdout")
+	cmd.Dir = fixtureDir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, stderr.String())
+	}
+	output := stdout.String()
+
+	for _, want := range []string{
+		"### File: docs/guide.md ###\n# Guide\n",
+		"### File: notes.txt (lines 1-1) ###\none\n",
+		"### File: notes.txt (lines 3-4) ###\nthree\nfour\n",
+		"### File: server.go (lines 5-8) ###\n// HandleLogin checks credentials.\nfunc (s *Server) HandleLogin() {\n\tprintln(\"login\")\n}\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "unrelated.go") || strings.Contains(output, "func other") {
+		t.Errorf("Expected only the named paths:\n%s", output)
+	}
+
+	for _, bad := range []string{"server.go#Missing", "missing.go", "docs:1-2"} {
+		cmd = exec.Command(concatBin, bad, "--stdout")
+		cmd.Dir = fixtureDir
+		if out, err := cmd.CombinedOutput(); err == nil {
+			t.Errorf("Expected %s to fail:\n%s", bad, out)
+		}
+	}
+}
+
+func TestConcatPrompt(t *testing.T) {
+	fixtureDir := t.TempDir()
+	createFile(t, fixtureDir, "main.go", "package main\n")
+	createFile(t, fixtureDir, "task.md", "Files in {{.Project}}: {{range .Files}}{{.}}{{end}}\n{{.Body}}\nWhat does main do?\n")
+
+	cmd := exec.Command(concatBin, "-p", "go", "--stdout", "--prefix", "You are terse.", "--prompt-file", "task.md", "--suffix", "Answer in one line.")
+	cmd.Dir = fixtureDir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	output := string(out)
+
+	// Prefix and template text after the header, the rest after the files
+	var last int
+	for _, want := range []string{
+		"---\n\nYou are terse.\n\nFiles in " + filepath.Base(fixtureDir) + ": main.go\n\n",
+		"### File: main.go ###",
+		"What does main do?\n\nAnswer in one line.\n",
+	} {
+		i := strings.Index(output, want)
+		if i < last {
+			t.Errorf("Expected %q after the previous sections:\n%s", want, output)
+		}
+		last = i
+	}
+
+	cmd = exec.Command(concatBin, "-p", "go", "--stdout", "--prompt", "nope")
+	cmd.Dir = fixtureDir
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "review") {
+		t.Errorf("Expected an unknown prompt to list the templates, got %v: %s", err, out)
+	}
+}
+
+func TestConcatHeader(t *testing.T) {
+	fixtureDir := t.TempDir()
+	createFile(t, fixtureDir, "main.go", "package main\n")
+	createFile(t, fixtureDir, "util.go", "package main\n\nfunc helper() {}\n")
+	run := func(env []string, args ...string) (string, string, error) {
+		t.Helper()
+		cmd := exec.Command(concatBin, append([]string{"-p", "go", "--stdout"}, args...)...)
+		cmd.Dir = fixtureDir
+		cmd.Env = append(os.Environ(), env...)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
+	}
+
+	if out, _, err := run(nil, "--header", "none"); err != nil || !strings.HasPrefix(out, "### File: main.go ###") {
+		t.Errorf("Expected no header, got %v:\n%s", err, out)
+	}
+
+	first, _, err := run(nil, "--deterministic")
+	if err != nil || !strings.HasPrefix(first, "---\nProject: "+filepath.Base(fixtureDir)+"\n---\n\n") {
+		t.Errorf("Expected a header without a timestamp, got %v:\n%s", err, first)
+	}
+	if second, _, _ := run(nil, "--deterministic"); second != first {
+		t.Errorf("Deterministic runs differ:\n%s\nwant:\n%s", second, first)
+	}
+
+	epoch := []string{"SOURCE_DATE_EPOCH=86400"}
+	stamped, _, err := run(epoch, "--deterministic")
+	if err != nil || !strings.Contains(stamped, "Generated: Fri, 02 Jan 1970 00:00:00 UTC\n") {
+		t.Errorf("Expected SOURCE_DATE_EPOCH as the generation time, got %v:\n%s", err, stamped)
+	}
+	if again, _, _ := run(epoch, "--deterministic"); again != stamped {
+		t.Errorf("Deterministic runs with SOURCE_DATE_EPOCH differ:\n%s\nwant:\n%s", again, stamped)
+	}
+
+	// Not a repository: the git fields are left out
+	if out, stderr, err := run(nil, "--header", "full"); err != nil || strings.Contains(out, "Commit:") || !strings.Contains(stderr, "No git metadata") {
+		t.Errorf("Expected a warning without git metadata, got %v:\n%s\n%s", err, out, stderr)
+	}
+
+	for _, args := range [][]string{{"--header", "verbose"}, {"--deterministic", "--order", "mtime"}} {
+		if _, _, err := run(nil, args...); err == nil {
+			t.Errorf("Expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestConcatCacheFriendly(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	fixtureDir := t.TempDir()
+	git := func(date string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = fixtureDir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t", "GIT_CONFIG_GLOBAL=/dev/null",
+			"GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	git("", "init", "-q")
+	createFile(t, fixtureDir, "b_stable.go", "package main // stable")
+	createFile(t, fixtureDir, "c_edited.go", "package main // v1")
+	createFile(t, fixtureDir, "a_recent.go", "package main // v1")
+	git("", "add", "-A")
+	git("2020-01-01T00:00:00Z", "commit", "-q", "-m", "first")
+	createFile(t, fixtureDir, "a_recent.go", "package main // v2")
+	git("", "add", "-A")
+	git("2021-01-01T00:00:00Z", "commit", "-q", "-m", "second")
+	createFile(t, fixtureDir, "c_edited.go", "package main // uncommitted")
+
+	cmd := exec.Command(concatBin, "-p", "go", "--stdout", "--xml", "--cache-friendly", "--cache-breakpoints", "--header", "full")
+	cmd.Dir = fixtureDir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, stderr.String())
+	}
+	output := stdout.String()
+
+	// Least recently changed first, uncommitted changes after the breakpoint
+	var last int
+	for _, want := range []string{
+		`<file path="b_stable.go">`,
+		`<file path="a_recent.go">`,
+		"</file>\n<cache_breakpoint/>\n",
+		`<file path="c_edited.go">`,
+	} {
+		i := strings.Index(output, want)
+		if i < last {
+			t.Errorf("Expected %q after the previous sections:\n%s", want, output)
+		}
+		last = i
+	}
+	for _, volatile := range []string{"Generated:", "Commit:"} {
+		if strings.Contains(output, volatile) {
+			t.Errorf("Expected no %s line in the header:\n%s", volatile, output)
+		}
+	}
+
+	// The place of uncommitted files is not reproducible
+	cmd = exec.Command(concatBin, "-p", "go", "--stdout", "--deterministic", "--order", "history")
+	cmd.Dir = fixtureDir
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "uncommitted") {
+		t.Errorf("Expected history order on a dirty tree to be rejected, got %v: %s", err, out)
+	}
+	cmd = exec.Command(concatBin, "-p", "go", "--stdout", "--deterministic", "--order", "history", "--rev", "HEAD")
+	cmd.Dir = fixtureDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("Expected history order of a revision to be accepted, got %v: %s", err, out)
+	}
+
+	cmd = exec.Command(concatBin, "-p", "go", "--stdout", "--cache-breakpoints")
+	cmd.Dir = fixtureDir
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "--xml") {
+		t.Errorf("Expected breakpoints to require XML, got %v: %s", err, out)
+	}
+}
+
+func TestConcatOutputSinks(t *testing.T) {
+	fixtureDir := t.TempDir()
+	createFile(t, fixtureDir, "main.go", "package main\n")
+	run := func(args ...string) (string, string, error) {
+		t.Helper()
+		cmd := exec.Command(concatBin, append([]string{"-p", "go", "--deterministic"}, args...)...)
+		cmd.Dir = fixtureDir
+		cmd.Env = append(os.Environ(), "TMPDIR="+fixtureDir)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(fixtureDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// Piped stdout is only the default: -o alone writes just the file
+	stdout, stderr, err := run("-o", "ctx.md")
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, stderr)
+	}
+	doc := read("ctx.md")
+	if stdout != "" || !strings.Contains(doc, "### File: main.go ###") {
+		t.Errorf("Expected only the file to be written, got stdout %q and file %q", stdout, doc)
+	}
+
+	// Sinks combine
+	stdout, stderr, err = run("-o", "both.md", "--stdout")
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, stderr)
+	}
+	if stdout != doc || read("both.md") != doc {
+		t.Errorf("Expected the same document on stdout and in the file, got %q", stdout)
+	}
+
+	if _, stderr, err = run("-o", "ctx.md", "--append"); err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, stderr)
+	}
+	if got := read("ctx.md"); got != doc+doc {
+		t.Errorf("Expected the document to be appended, got %q", got)
+	}
+
+	if _, stderr, err = run("-o", "ctx.md.gz"); err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, stderr)
+	}
+	zr, err := gzip.NewReader(strings.NewReader(read("ctx.md.gz")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(zr); err != nil || string(got) != doc {
+		t.Errorf("Expected the gzip file to hold the document, got %v: %q", err, got)
+	}
+
+	stdout, stderr, err = run("--clipboard", "--clipboard-backend", "file", "--stdout")
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, stderr)
+	}
+	matches, _ := filepath.Glob(filepath.Join(fixtureDir, "concat-*.txt"))
+	if stdout != doc || len(matches) != 1 || !strings.Contains(stderr, matches[0]) {
+		t.Errorf("Expected the document on stdout and saved to a reported temp file, got %v:\n%s", matches, stderr)
+	}
+
+	if _, stderr, err = run("--append"); err == nil || !strings.Contains(stderr, "--append requires -o") {
+		t.Errorf("Expected --append without -o to fail, got %v: %s", err, stderr)
+	}
+
+	// An earlier run's output is never read back, even when of a selected type
+	createFile(t, fixtureDir, "notes.md", "# Notes")
+	for _, args := range [][]string{{"-p", "md", "-o", "out.md"}, {"-p", "md", "-o", "out.md"}, {"-p", "md", "-o", filepath.Join(fixtureDir, "out.md"), "--append"}} {
+		if _, stderr, err := run(args...); err != nil {
+			t.Fatalf("Run failed: %v\n%s", err, stderr)
+		}
+	}
+	if got := read("out.md"); strings.Contains(got, "### File: out.md ###") || strings.Count(got, "### File: notes.md ###") != 2 {
+		t.Errorf("Expected two documents without the output itself, got:\n%s", got)
+	}
+}
+
+func TestConcatTreeCommand(t *testing.T) {
+	fixtureDir := t.TempDir()
+	createFile(t, fixtureDir, "main.go", "package main\n\nfunc main() {}")
+	if err := os.MkdirAll(filepath.Join(fixtureDir, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	createFile(t, fixtureDir, "pkg/util.go", "package pkg")
+
+	cmd := exec.Command(concatBin, "tree", "-p", "go", "--tree-stats")
+	cmd.Dir = fixtureDir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Run failed: %v\nOutput: %s", err, out)
+	}
+	output := string(out)
+
+	if !strings.Contains(output, "### Directory Structure ###") {
+		t.Error("Output missing tree header")
+	}
+	if !strings.Contains(output, "pkg (1 file, 11 B, ~2 tokens)") {
+		t.Errorf("Output missing directory stats:\n%s", output)
+	}
+	if strings.Contains(output, "package main") || strings.Contains(output, "Project:") {
+		t.Error("Tree command should not emit file contents or the document header")
+	}
+}
+
+func TestConcatArchive(t *testing.T) {
+	workDir := t.TempDir()
+	archivePath := filepath.Join(workDir, "release.zip")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"release-1.0/main.go":          "package main\n\nfunc main() {}",
+		"release-1.0/pkg/util.go":      "package pkg",
+		"release-1.0/gen/generated.go": "package gen",
+		"release-1.0/.gitignore":       "gen/",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	cmd := exec.Command(concatBin, "-p", "go", "-t", "--stdout", "release.zip")
+	cmd.Dir = workDir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Run failed: %v\nOutput: %s", err, out)
+	}
+	output := string(out)
+
+	for _, want := range []string{"Project: release\n", "└── pkg\n    └── util.go", "### File: main.go ###", "### File: pkg/util.go ###"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Output missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "generated.go") || strings.Contains(output, "release-1.0") {
+		t.Errorf("Output should honor the archive's .gitignore and strip its top-level directory:\n%s", output)
+	}
+
+	cmd = exec.Command(concatBin, "-p", "go", "--stdout", "main.go")
+	cmd.Dir = workDir
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "matches no included files") {
+		t.Errorf("Expected a missing path to be rejected, got %v: %s", err, out)
+	}
+}
+
+func TestConcatRev(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	fixtureDir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = fixtureDir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t", "GIT_CONFIG_GLOBAL=/dev/null")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	git("init", "-q")
+	createFile(t, fixtureDir, "main.go", "package main // v1")
+	createFile(t, fixtureDir, "extra.go", "package main // extra")
+	git("add", "-A")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1")
+
+	// The current .gitignore must not apply to the old revision
+	createFile(t, fixtureDir, "main.go", "package main // v2")
+	createFile(t, fixtureDir, ".gitignore", "extra.go")
+	git("add", "-A")
+	git("commit", "-q", "-m", "v2")
+	createFile(t, fixtureDir, "main.go", "package main // uncommitted")
+
+	cmd := exec.Command(concatBin, "-p", "go", "--stdout", "--rev", "v1")
+	cmd.Dir = fixtureDir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Run failed: %v\nOutput: %s", err, out)
+	}
+	output := string(out)
+
+	for _, want := range []string{"Project: ", " (v1)\n", "package main // v1", "package main // extra"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Output missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "v2") || strings.Contains(output, "uncommitted") {
+		t.Errorf("Output contains content from after v1:\n%s", output)
+	}
+
+	cmd = exec.Command(concatBin, "-p", "go", "--stdout", "--rev", "v3")
+	cmd.Dir = fixtureDir
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "unknown revision") {
+		t.Errorf("Expected an unknown revision to fail, got %v: %s", err, out)
+	}
+}
+
+func TestConcatCacheCommands(t *testing.T) {
+	fixtureDir := t.TempDir()
+	createFile(t, fixtureDir, "main.go", "package main\n\nfunc main() {}")
+	env := append(os.Environ(), "XDG_CACHE_HOME="+t.TempDir())
+
+	run := func(args ...string) string {
+		cmd := exec.Command(concatBin, args...)
+		cmd.Dir = fixtureDir
+		cmd.Env = env
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("concat %v failed: %v\nOutput: %s", args, err, out)
+		}
+		return string(out)
+	}
+
+	// Only outlines are cached
+	run("-p", "go", "--stdout")
+	if stats := run("cache", "stats"); !strings.Contains(stats, "Files:    0") {
+		t.Errorf("Expected full files not to be cached:\n%s", stats)
+	}
+
+	first := run("-p", "go", "--stdout", "--skeleton")
+	if second := run("-p", "go", "--stdout", "--skeleton"); stripGenerated(second) != stripGenerated(first) {
+		t.Errorf("Cached run differs:\n%s\nwant:\n%s", second, first)
+	}
+
+	if stats := run("cache", "stats"); !strings.Contains(stats, "Files:    1") {
+		t.Errorf("Expected one cached file:\n%s", stats)
+	}
+	if out := run("cache", "clear"); !strings.Contains(out, "Cleared cache (1 files") {
+		t.Errorf("Unexpected clear output:\n%s", out)
+	}
+	if stats := run("cache", "stats"); !strings.Contains(stats, "Files:    0") {
+		t.Errorf("Expected an empty cache:\n%s", stats)
+	}
+}
+
+// stripGenerated removes the timestamp line from the document header
+func stripGenerated(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if !strings.HasPrefix(line, "Generated: ") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestConcatMCP(t *testing.T) {
+	fixtureDir := t.TempDir()
+	createFile(t, fixtureDir, "main.go", "package main\n\nfunc main() {}")
+	createFile(t, fixtureDir, "README.md", "# Demo")
+
+	responses := runMCP(t, fixtureDir, []string{"-p", "go", "--no-cache"},
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"e2e","version":"0"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"list_files","arguments":{"extensions":["go","md"]}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"bundle","arguments":{"extensions":["go"],"includeTree":true,"transform":{"compact":true}}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"read_files","arguments":{"paths":["README.md"],"useXML":true}}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"read_files","arguments":{"paths":["../outside.go"]}}}`,
+		`{"jsonrpc":"2.0","id":7,"method":"resources/list"}`,
+	)
+	if len(responses) != 7 {
+		t.Fatalf("Expected 7 responses, got %d: %v", len(responses), responses)
+	}
+
+	var tools []string
+	for _, tool := range responses[1]["result"].(map[string]any)["tools"].([]any) {
+		tools = append(tools, tool.(map[string]any)["name"].(string))
+	}
+	if got := strings.Join(tools, ","); got != "list_files,read_files,get_tree,bundle,optimize" {
+		t.Errorf("Unexpected tools: %s", got)
+	}
+
+	if text := toolText(t, responses[2]); !strings.Contains(text, `"path": "README.md"`) || !strings.Contains(text, `"path": "main.go"`) {
+		t.Errorf("Unexpected list_files output:\n%s", text)
+	}
+	if text := toolText(t, responses[3]); !strings.Contains(text, "### Directory Structure ###") || !strings.Contains(text, "### File: main.go ###") {
+		t.Errorf("Unexpected bundle output:\n%s", text)
+	}
+	if text := toolText(t, responses[4]); !strings.Contains(text, "<file path=\"README.md\">\n# Demo") {
+		t.Errorf("Unexpected read_files output:\n%s", text)
+	}
+	if result := responses[5]["result"].(map[string]any); result["isError"] != true {
+		t.Errorf("Expected reading outside the root to fail: %v", result)
+	}
+
+	resources := responses[6]["result"].(map[string]any)["resources"].([]any)
+	if len(resources) != 1 || resources[0].(map[string]any)["name"] != "main.go" {
+		t.Errorf("Expected main.go as the only resource (selected by -p go): %v", resources)
+	}
+
+	// Resources are readable by URI
+	uri := resources[0].(map[string]any)["uri"].(string)
+	read := runMCP(t, fixtureDir, nil, `{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":"`+uri+`"}}`)
+	contents := read[0]["result"].(map[string]any)["contents"].([]any)
+	if contents[0].(map[string]any)["text"] != "package main\n\nfunc main() {}" {
+		t.Errorf("Unexpected resource contents: %v", contents)
+	}
+
+	// Requests cannot read files outside the root through a prompt file
+	secretDir := t.TempDir()
+	createFile(t, secretDir, "secret.md", "top secret")
+	secret := filepath.ToSlash(filepath.Join(secretDir, "secret.md"))
+	bundle := runMCP(t, fixtureDir, nil, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"bundle","arguments":{"extensions":["go"],"promptFile":"`+secret+`"}}}`)
+	if result := bundle[0]["result"].(map[string]any); result["isError"] != true || strings.Contains(fmt.Sprint(result), "top secret") {
+		t.Errorf("Expected a prompt file to be rejected: %v", result)
+	}
+}
+
+// runMCP runs `concat mcp` in dir with a scripted session on stdin (one
+// JSON-RPC message per line) and returns the decoded responses in order
+func runMCP(t *testing.T, dir string, args []string, script ...string) []map[string]any {
+	t.Helper()
+	cmd := exec.Command(concatBin, append([]string{"mcp"}, args...)...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(strings.Join(script, "\n") + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("concat mcp failed: %v\nStderr: %s", err, stderr.String())
+	}
+
+	var responses []map[string]any
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var resp map[string]any
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("Invalid response: %v\nOutput: %s", err, out)
+		}
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+// toolText returns the text of a successful tools/call response
+func toolText(t *testing.T, resp map[string]any) string {
+	t.Helper()
+	result, ok := resp["result"].(map[string]any)
+	if !ok || result["isError"] == true {
+		t.Fatalf("Tool call failed: %v", resp)
+	}
+	return result["content"].([]any)[0].(map[string]any)["text"].(string)
+}
+
+func TestConcatWatch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupt signals are not supported on windows")
+	}
+
+	fixtureDir := t.TempDir()
+	createFile(t, fixtureDir, "main.go", "package main // v1")
+
+	cmd := exec.Command(concatBin, "-p", "go", "--watch", "-o", "ctx.md")
+	cmd.Dir = fixtureDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start watch: %v", err)
+	}
+	defer cmd.Process.Kill()
+
+	// waitFor polls the output until cond holds
+	waitFor := func(desc string, cond func(string) bool) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			data, _ := os.ReadFile(filepath.Join(fixtureDir, "ctx.md"))
+			if cond(string(data)) {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for %s\nStderr: %s", desc, stderr.String())
+	}
+
+	waitFor("initial output", func(s string) bool { return strings.Contains(s, "// v1") })
+
+	createFile(t, fixtureDir, "main.go", "package main // v2")
+	waitFor("modified file", func(s string) bool { return strings.Contains(s, "// v2") })
+
+	createFile(t, fixtureDir, "extra.go", "package main // extra")
+	waitFor("new file", func(s string) bool { return strings.Contains(s, "// extra") })
+
+	if err := os.Mkdir(filepath.Join(fixtureDir, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	createFile(t, fixtureDir, "pkg/lib.go", "package pkg // lib")
+	waitFor("file in a new directory", func(s string) bool { return strings.Contains(s, "// lib") })
+	createFile(t, fixtureDir, "pkg/lib.go", "package pkg // lib v2")
+	waitFor("modified file in a new directory", func(s string) bool { return strings.Contains(s, "// lib v2") })
+
+	createFile(t, fixtureDir, ".gitignore", "extra.go\n")
+	waitFor(".gitignore rebuild", func(s string) bool { return !strings.Contains(s, "// extra") })
+
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Watch exited with error: %v\nStderr: %s", err, stderr.String())
+	}
+}
+
+func TestConcatWatch_AbsoluteOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupt signals are not supported on windows")
+	}
+
+	fixtureDir := t.TempDir()
+	createFile(t, fixtureDir, "notes.md", "# Notes")
+	if err := os.Mkdir(filepath.Join(fixtureDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(fixtureDir, "sub", "..", "ctx.md")
+
+	// The output is of a selected type, so it must be recognized as itself
+	cmd := exec.Command(concatBin, "-p", "md", "--watch", "-o", output)
+	cmd.Dir = fixtureDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start watch: %v", err)
+	}
+	defer cmd.Process.Kill()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, _ := os.ReadFile(filepath.Join(fixtureDir, "ctx.md")); strings.Contains(string(data), "# Notes") {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// Rewrites of the output must not trigger further regenerations
+	time.Sleep(2 * time.Second)
+
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Watch exited with error: %v\nStderr: %s", err, stderr.String())
+	}
+	data, _ := os.ReadFile(filepath.Join(fixtureDir, "ctx.md"))
+	if strings.Contains(string(data), "### File: ctx.md ###") {
+		t.Errorf("Expected the output to exclude itself:\n%s", data)
+	}
+	if n := strings.Count(stderr.String(), "✓ Wrote"); n != 1 {
+		t.Errorf("Expected one write, got %d:\n%s", n, stderr.String())
+	}
+}
+
 func createFile(t *testing.T, dir, name, content string) {
 	path := filepath.Join(dir, name)
 	if err := os.WriteFile(path, []byte(content), 0644); err != nil {